@@ -6,13 +6,42 @@ import (
 	"strings"
 )
 
-// GertAddress is a 3 byte address used as a GERTe/i Address
+const (
+	// Network is the network name reported by GertAddress and GERTc when used as net.Addr
+	Network = "gert"
+	// MaxAddressPart is the largest value of the Upper and Lower half of a GertAddress, both are sent as 12 bits
+	MaxAddressPart = 0xFFF
+)
+
+// GertAddress is a 3 byte address used as a GERTe/i Address.
+// It implements encoding.TextMarshaler and encoding.TextUnmarshaler with the "XXXX.YYYY" format,
+// so it is stored as a string in JSON and other text based formats.
 type GertAddress struct {
 	Upper int
 	Lower int
 }
 
-// ToBytes converts a GERT Address to bytes for sending
+// NewAddress is the validating constructor for GertAddress.
+// It returns the GertAddress and an error wrapping ErrAddressRange if upper or lower don't fit into 12 bits.
+func NewAddress(upper, lower int) (GertAddress, error) {
+	addr := GertAddress{Upper: upper, Lower: lower}
+	if err := addr.Validate(); err != nil {
+		return GertAddress{}, err
+	}
+	return addr, nil
+}
+
+// Validate checks that both halves of the GertAddress fit into 12 bits.
+// It returns an error wrapping ErrAddressRange otherwise.
+func (addr GertAddress) Validate() error {
+	if addr.Upper < 0 || addr.Upper > MaxAddressPart || addr.Lower < 0 || addr.Lower > MaxAddressPart {
+		return fmt.Errorf("%w: %v.%v, the halves have to be between 0 and %v", ErrAddressRange, addr.Upper, addr.Lower, MaxAddressPart)
+	}
+	return nil
+}
+
+// ToBytes converts a GERT Address to bytes for sending.
+// Halves that don't fit into 12 bits are truncated, see Validate.
 func (addr GertAddress) ToBytes() []byte {
 	var b strings.Builder
 	b.WriteByte(byte(addr.Upper >> 4))
@@ -21,12 +50,21 @@ func (addr GertAddress) ToBytes() []byte {
 	return []byte(b.String())
 }
 
-// AddressFromBytes parses bytes to a GERT Address
-func AddressFromBytes(data []byte) GertAddress {
+// AddressFromBytes parses bytes to a GERT Address.
+// It returns the GertAddress and any encountered errors, data has to hold at least 3 bytes.
+func AddressFromBytes(data []byte) (GertAddress, error) {
+	if err := checkLength(data, 3, "address"); err != nil {
+		return GertAddress{}, err
+	}
 	return GertAddress{
 		Upper: (int(data[0]) << 4) | (int(data[1]) >> 4),
 		Lower: ((int(data[1]) & 0x0F) << 8) | int(data[2]),
-	}
+	}, nil
+}
+
+// Network returns the name of the network for net.Addr
+func (addr GertAddress) Network() string {
+	return Network
 }
 
 // String prints a GertAddress as a string
@@ -41,19 +79,51 @@ func (addr GertAddress) GoString() string {
 
 // AddressFromString converts a string with an address in the format "XXXX.YYYY" into the corresponding GertAddress.
 // It returns the GertAddress and any encountered errors.
+// Both halves are decimal numbers, strings in another format wrap ErrInvalidAddress and halves above MaxAddressPart wrap ErrAddressRange.
 func AddressFromString(addr string) (GertAddress, error) {
 	parts := strings.Split(addr, ".")
-
-	upper, err := strconv.ParseInt(parts[0], 10, 0)
+	if len(parts) != 2 {
+		return GertAddress{}, fmt.Errorf("%w: %q is not in the format XXXX.YYYY", ErrInvalidAddress, addr)
+	}
+	upper, err := parseAddressPart(parts[0])
 	if err != nil {
 		return GertAddress{}, fmt.Errorf("error on parse upper String: %w", err)
 	}
-	lower, err := strconv.ParseInt(parts[1], 10, 0)
+	lower, err := parseAddressPart(parts[1])
 	if err != nil {
 		return GertAddress{}, fmt.Errorf("error on parse lower String: %w", err)
 	}
-	return GertAddress{
-		Upper: int(upper),
-		Lower: int(lower),
-	}, nil
+	return NewAddress(upper, lower)
+}
+
+// MarshalText encodes the GertAddress in the format "XXXX.YYYY".
+// It returns the text and an error wrapping ErrAddressRange if the address is invalid.
+func (addr GertAddress) MarshalText() ([]byte, error) {
+	if err := addr.Validate(); err != nil {
+		return nil, err
+	}
+	return []byte(addr.String()), nil
+}
+
+// UnmarshalText decodes a GertAddress in the format "XXXX.YYYY" like AddressFromString.
+// It returns any encountered errors.
+func (addr *GertAddress) UnmarshalText(text []byte) error {
+	parsed, err := AddressFromString(string(text))
+	if err != nil {
+		return err
+	}
+	*addr = parsed
+	return nil
+}
+
+// parseAddressPart parses a decimal half of an address, signs and other characters are rejected
+func parseAddressPart(s string) (int, error) {
+	if s == "" || strings.Trim(s, "0123456789") != "" {
+		return 0, fmt.Errorf("%w: %q is not a decimal number", ErrInvalidAddress, s)
+	}
+	n, err := strconv.ParseUint(s, 10, 16)
+	if err != nil || n > MaxAddressPart {
+		return 0, fmt.Errorf("%w: %v is larger than %v", ErrAddressRange, s, MaxAddressPart)
+	}
+	return int(n), nil
 }
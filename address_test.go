@@ -1,6 +1,11 @@
 package gerte
 
-import "testing"
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
 
 func TestAddressFromToBytes(t *testing.T) {
 	address := GertAddress{
@@ -8,7 +13,10 @@ func TestAddressFromToBytes(t *testing.T) {
 		Lower: 456,
 	}
 	addr := address.ToBytes()
-	address2 := AddressFromBytes(addr)
+	address2, err := AddressFromBytes(addr)
+	if err != nil {
+		t.Errorf("error on parse address: %+v", err)
+	}
 
 	if address != address2 {
 		t.Error("addresses don't match")
@@ -28,3 +36,78 @@ func TestAddressFromString(t *testing.T) {
 		t.Error("addresses don't match")
 	}
 }
+
+func TestAddressFromString_Invalid(t *testing.T) {
+	tests := []struct {
+		in   string
+		want error
+	}{
+		{"", ErrInvalidAddress},
+		{"1123", ErrInvalidAddress},
+		{"1123.", ErrInvalidAddress},
+		{".1456", ErrInvalidAddress},
+		{"1123.1456.1", ErrInvalidAddress},
+		{"-1.0001", ErrInvalidAddress},
+		{"+1.0001", ErrInvalidAddress},
+		{"0x10.0001", ErrInvalidAddress},
+		{"4096.0001", ErrAddressRange},
+		{"0001.99999999999999999999", ErrAddressRange},
+	}
+	for _, tt := range tests {
+		if _, err := AddressFromString(tt.in); !errors.Is(err, tt.want) {
+			t.Errorf("AddressFromString(%q): got %+v, want %+v", tt.in, err, tt.want)
+		}
+	}
+	if addr, err := AddressFromString("4095.0"); err != nil || addr != (GertAddress{Upper: 4095}) {
+		t.Errorf("got %v %+v, want 4095.0000", addr, err)
+	}
+}
+
+func TestNewAddress(t *testing.T) {
+	if _, err := NewAddress(4095, 4095); err != nil {
+		t.Errorf("error on valid address: %+v", err)
+	}
+	for _, addr := range []GertAddress{{Upper: 4096}, {Lower: 4096}, {Upper: -1}, {Lower: -1}} {
+		if _, err := NewAddress(addr.Upper, addr.Lower); !errors.Is(err, ErrAddressRange) {
+			t.Errorf("NewAddress(%v, %v): got %+v, want %+v", addr.Upper, addr.Lower, err, ErrAddressRange)
+		}
+		if _, err := (&Register{Address: addr, Key: "aaaaaaaaaaaaaaaaaaaa"}).MarshalBinary(); !errors.Is(err, ErrAddressRange) {
+			t.Errorf("register for %v: got %+v, want %+v", addr, err, ErrAddressRange)
+		}
+	}
+}
+
+func TestGertAddress_MarshalText(t *testing.T) {
+	type config struct {
+		Gateway GertAddress
+		Peers   map[GertAddress]GERTc
+	}
+	in := config{
+		Gateway: GertAddress{Upper: 1123, Lower: 1456},
+		Peers: map[GertAddress]GERTc{
+			{Upper: 2345, Lower: 1456}: {GERTe: GertAddress{Upper: 2345, Lower: 1456}, GERTi: GertAddress{Upper: 1, Lower: 1}},
+		},
+	}
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatalf("error on marshal: %+v", err)
+	}
+	want := `{"Gateway":"1123.1456","Peers":{"2345.1456":"2345.1456:0001.0001"}}`
+	if string(data) != want {
+		t.Errorf("got %s, want %s", data, want)
+	}
+	var out config
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("error on unmarshal: %+v", err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("got %+v, want %+v", out, in)
+	}
+
+	if err := json.Unmarshal([]byte(`{"Gateway":"5000.0001"}`), &out); !errors.Is(err, ErrAddressRange) {
+		t.Errorf("got %+v, want %+v", err, ErrAddressRange)
+	}
+	if _, err := json.Marshal(GertAddress{Upper: 5000}); !errors.Is(err, ErrAddressRange) {
+		t.Errorf("got %+v, want %+v", err, ErrAddressRange)
+	}
+}
@@ -3,18 +3,59 @@
 package gerte
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net"
-	"strings"
+	"sync"
+	"time"
 )
 
-// Api is used to perform GERTe API Operations
+// Api is used to perform GERTe API Operations.
+// An Api is safe for concurrent use by multiple goroutines, commands are written one at a time
+// and the STATE replies of the relay are matched to them in the order they were sent.
+// The Api tracks the state of its session with the relay, see SessionState, and rejects operations the current state doesn't allow.
+// Registered and Address are set by Register, use Registration to read them while other goroutines use the Api.
 type Api struct {
+	// socket is guarded by mu
 	socket     net.Conn
-	listener   net.Listener
 	Registered bool
 	Address    GertAddress
-	Version    Version
+	// Version is the protocol version requested by Startup, it is replaced with the version the relay negotiated
+	Version Version
+	// Policy restricts the versions Negotiate tries and Startup accepts from the relay, see VersionPolicy.
+	Policy VersionPolicy
+	// MaxInFlight is the number of DATA commands TransmitAsync keeps in flight, DefaultMaxInFlight if not set.
+	// It is read when the receive loop starts.
+	MaxInFlight int
+
+	// decoder reads frames from decoderConn, it is replaced whenever socket changes.
+	// Both are guarded by sendMu.
+	decoder     *Decoder
+	decoderConn net.Conn
+
+	// sendMu serializes writing commands to the socket and reading it without the receive loop,
+	// so queuing a reply waiter and writing its command is atomic and synchronous requests can't steal each other's replies
+	sendMu sync.Mutex
+	// mu guards the socket, the registration, the session state and the receive loop state below
+	mu    sync.Mutex
+	state SessionState
+	// cause is the error that made the session fail
+	cause error
+	// codec encodes the commands of the negotiated version
+	codec     FrameCodec
+	hooks     []*sessionHook
+	changes   []SessionChange
+	notifying bool
+	receiving bool
+	closing   bool
+	pending   []chan Command
+	packets   chan Packet
+	done      chan struct{}
+	recvErr   error
+	inFlight  chan struct{}
+	inbound   []InboundMiddleware
+	outbound  []OutboundMiddleware
 }
 
 // NewApi is the constructor for Api, it assigns the Version
@@ -30,96 +71,163 @@ func NewApi(ver Version) *Api {
 // Initializing the API is incredibly simple.
 // The API should be initialized for every program when it decides to use it (although it is potentially already initialized, the API will ensure it's safe to initialize.)
 func (api *Api) Startup(c net.Conn) error {
-	if api.socket != nil {
-		return fmt.Errorf("socket already open")
+	return api.StartupContext(context.Background(), c)
+}
+
+// StartupContext initializes the API like Startup, bounded by ctx.
+// It returns any encountered errors.
+// If ctx is cancelled or its deadline passes before the relay answers, the returned error wraps ctx.Err(),
+// so timeouts can be detected with errors.Is(err, context.DeadlineExceeded).
+// If the relay refuses the connection, answers with anything else or doesn't answer in time, the connection is closed
+// and the session fails, so Startup can be retried with a new connection.
+// The relay may negotiate an older version than Version, but it has to be accepted by Policy and have a registered FrameCodec,
+// otherwise the returned error wraps ErrVersion.
+// Relays close the connection after rejecting a version, so StartupContext can't fall back to an older one itself,
+// use Negotiate to fall back from ExtendedVersion to DefaultVersion.
+func (api *Api) StartupContext(ctx context.Context, c net.Conn) error {
+	defer api.notify()
+	api.mu.Lock()
+	if err := api.checkState(opStartup); err != nil {
+		api.mu.Unlock()
+		return err
 	}
 	api.socket = c
-	_, err := api.socket.Write([]byte{api.Version.Major, api.Version.Minor})
-	if err != nil {
-		return fmt.Errorf("error on send version: %w", err)
-	}
-	cmd, err := api.Parse()
+	api.codec = DefaultCodec
+	api.setState(SessionConnecting, nil)
+	api.mu.Unlock()
+	requested := api.Version
+	cmd, err := api.request(ctx, requested.ToBytes())
 	if err != nil {
-		return fmt.Errorf("error on parse response: %w", err)
+		_ = api.closeSocket(SessionFailed, err)
+		return err
 	}
 	if cmd.Command == CommandState {
 		switch cmd.Status.Status {
 		case StateConnected:
-			api.Version = cmd.Status.Version
+			ver := cmd.Status.Version
+			if err = api.Policy.accepts(requested, ver); err != nil {
+				break
+			}
+			codec, _ := CodecFor(ver)
+			api.sendMu.Lock()
+			api.reader(c).SetCodec(codec)
+			api.sendMu.Unlock()
+			api.mu.Lock()
+			api.Version = ver
+			api.codec = codec
+			api.setState(SessionConnected, nil)
+			api.mu.Unlock()
 			return nil
 		case StateFailure:
-			return cmd.Status.parseError()
+			err = cmd.Status.parseError()
+			if errors.Is(err, ErrVersion) {
+				err = fmt.Errorf("%w: relay rejected %v", err, requested.wire())
+			}
 		case StateSent:
-			return fmt.Errorf("invalid response: state \"sent\"")
+			err = fmt.Errorf("%w: state \"sent\"", ErrInvalidResponse)
 		case StateClosed:
-			err := api.socket.Close()
+			err := api.closeSocket(SessionClosed, nil)
 			if err != nil {
 				return fmt.Errorf("error while closing socket: %w", err)
 			}
-			api.socket = nil
+			return ErrClosedByRelay
 		case StateAssigned:
-			return fmt.Errorf("invalid response: state \"assigned\"")
+			err = fmt.Errorf("%w: state \"assigned\"", ErrInvalidResponse)
 		}
-
 	}
-	return fmt.Errorf("invalid response: %v", cmd)
+	if err == nil {
+		err = fmt.Errorf("%w: %v", ErrInvalidResponse, cmd)
+	}
+	_ = api.closeSocket(SessionFailed, err)
+	return err
 }
 
 // Register registers the GERTe client on the GERTe address with the associated 20 byte key.
 // It returns a bool whether the registration was successful and any encountered errors.
 // All gateways must register themselves with a valid GERTe address and key before sending data.
-// The API does not track if it is registered nor what address it has registered, it instead relies on the relay it is connected to.
+// Register is only allowed once the version was negotiated and before an address was registered.
 func (api *Api) Register(addr GertAddress, key string) (bool, error) {
-	var b strings.Builder
-	b.WriteByte(byte(CommandRegister))
-	b.Write(addr.ToBytes())
-	b.WriteString(key)
-	_, err := api.socket.Write([]byte(b.String()))
+	return api.RegisterContext(context.Background(), addr, key)
+}
+
+// RegisterContext registers the GERTe client like Register, bounded by ctx.
+// It returns a bool whether the registration was successful and any encountered errors.
+// If ctx ends before the relay answers, the returned error wraps ctx.Err().
+func (api *Api) RegisterContext(ctx context.Context, addr GertAddress, key string) (bool, error) {
+	defer api.notify()
+	api.mu.Lock()
+	err := api.checkState(opRegister)
+	api.mu.Unlock()
 	if err != nil {
-		return false, fmt.Errorf("error on write: %w", err)
+		return false, err
 	}
-	cmd, err := api.Parse()
+	cmd, err := api.requestMessage(ctx, &Register{Address: addr, Key: key})
 	if err != nil {
-		return false, fmt.Errorf("error parsing response: %w", err)
+		return false, err
 	}
 	if cmd.Command == CommandState {
 		switch cmd.Status.Status {
 		case StateFailure:
 			return false, cmd.Status.parseError()
 		case StateAssigned:
+			api.mu.Lock()
 			api.Address = addr
+			api.setState(SessionAssigned, nil)
+			api.mu.Unlock()
 			return true, nil
 		}
 	}
-	return false, fmt.Errorf("no valid response: %v", cmd)
+	return false, fmt.Errorf("%w: %v", ErrInvalidResponse, cmd)
 }
 
 // Transmit sends data to the target Address.
 // It returns a bool whether the operation was successful and any encountered errors.
 // The official API only allows transmissions from GERTi to GERTi via GERTe.
 // his means that a GERTi address must be provided for each endpoint in a message.
+// The Data of pkt cannot exceed MaxData bytes.
+// Transmit fails with ErrNotRegistered before an address was registered.
 func (api *Api) Transmit(pkt Packet) (bool, error) {
+	return api.TransmitContext(context.Background(), pkt)
+}
 
-	if api.socket == nil {
-		return false, fmt.Errorf("not connected")
+// TransmitContext sends data to the target Address like Transmit, bounded by ctx.
+// It returns a bool whether the operation was successful and any encountered errors.
+// If ctx ends before the relay answers, the returned error wraps ctx.Err().
+// Without the receive loop the late reply can't be matched anymore, so the connection is closed and the session fails.
+// The Packet passes the middleware added with UseOutbound first.
+func (api *Api) TransmitContext(ctx context.Context, pkt Packet) (bool, error) {
+	api.mu.Lock()
+	outbound := api.outbound
+	api.mu.Unlock()
+	if len(outbound) == 0 {
+		return api.transmit(ctx, pkt)
 	}
+	return ChainOutbound(outbound...)(api.transmit)(ctx, pkt)
+}
 
-	var b strings.Builder
+// transmit sends pkt to the relay and waits for the result
+func (api *Api) transmit(ctx context.Context, pkt Packet) (bool, error) {
+	return api.transmitNotify(ctx, pkt, nil)
+}
 
-	b.WriteByte(byte(CommandData))
-	data, err := pkt.ToBytes()
+// transmitNotify sends pkt to the relay like transmit, calling written once the DATA command was written if it is not nil
+func (api *Api) transmitNotify(ctx context.Context, pkt Packet, written func()) (bool, error) {
+	defer api.notify()
+	api.mu.Lock()
+	err := api.checkState(opTransmit)
+	api.mu.Unlock()
 	if err != nil {
-		return false, fmt.Errorf("error on marshal packet: %w", err)
+		return false, err
 	}
-	b.Write(data)
 
-	_, err = api.socket.Write([]byte(b.String()))
+	frame, err := api.marshal(NewOutboundData(pkt))
 	if err != nil {
-		return false, fmt.Errorf("error on write: %w", err)
+		return false, fmt.Errorf("error on marshal packet: %w", err)
 	}
-	cmd, err := api.Parse()
+
+	cmd, err := api.requestNotify(ctx, frame, written)
 	if err != nil {
-		return false, fmt.Errorf("error on parse response: %w", err)
+		return false, err
 	}
 	if cmd.Command == CommandState {
 		switch cmd.Status.Status {
@@ -128,71 +236,350 @@ func (api *Api) Transmit(pkt Packet) (bool, error) {
 		case StateSent:
 			return true, nil
 		case StateAssigned:
-			return false, fmt.Errorf("invalid status: Assigned")
+			return false, fmt.Errorf("%w: state \"assigned\"", ErrInvalidResponse)
 		case StateClosed:
-			return false, fmt.Errorf("invalid status: Closed")
+			return false, fmt.Errorf("%w: state \"closed\"", ErrInvalidResponse)
 		case StateConnected:
-			return false, fmt.Errorf("invalid status: Connected")
+			return false, fmt.Errorf("%w: state \"connected\"", ErrInvalidResponse)
 		}
 
 	}
-	return false, fmt.Errorf("no valid response: %v", cmd)
+	return false, fmt.Errorf("%w: %v", ErrInvalidResponse, cmd)
+}
+
+// State requests the state of the gateway from the relay.
+// It returns the Status the relay answered with and any encountered errors.
+// The relay answers CONNECTED with the negotiated Version or ASSIGNED, so State can be used to check that the relay is still alive.
+// If the relay reports that the gateway is no longer registered, the session state changes back to SessionConnected.
+func (api *Api) State() (Status, error) {
+	return api.StateContext(context.Background())
+}
+
+// StateContext requests the state of the gateway like State, bounded by ctx.
+// It returns the Status the relay answered with and any encountered errors.
+// If ctx ends before the relay answers, the returned error wraps ctx.Err().
+func (api *Api) StateContext(ctx context.Context) (Status, error) {
+	defer api.notify()
+	api.mu.Lock()
+	err := api.checkState(opQueryState)
+	api.mu.Unlock()
+	if err != nil {
+		return Status{}, err
+	}
+	cmd, err := api.requestMessage(ctx, &StateRequest{})
+	if err != nil {
+		return Status{}, err
+	}
+	if cmd.Command == CommandState {
+		switch cmd.Status.Status {
+		case StateFailure:
+			return cmd.Status, cmd.Status.parseError()
+		case StateConnected:
+			api.mu.Lock()
+			if api.state == SessionAssigned {
+				api.setState(SessionConnected, nil)
+			}
+			api.mu.Unlock()
+			return cmd.Status, nil
+		case StateAssigned:
+			return cmd.Status, nil
+		case StateClosed:
+			if err := api.closeSocket(SessionClosed, nil); err != nil {
+				return cmd.Status, fmt.Errorf("error while closing socket: %w", err)
+			}
+			return cmd.Status, nil
+		}
+	}
+	return Status{}, fmt.Errorf("%w: %v", ErrInvalidResponse, cmd)
 }
 
 // Shutdown Gracefully closes the GERTe Socket.
 // It returns any errors encountered.
 // The official API prefers using a safe shutdown procedure, although the GEDS servers should be more than stable enough to survive any number of unclean shutdowns.
 func (api *Api) Shutdown() error {
-	if api.socket != nil {
-		_, err := api.socket.Write([]byte{byte(CommandClose)})
-		if err != nil {
-			return fmt.Errorf("error on write close command: %w", err)
-		}
-		cmd, err := api.Parse()
+	return api.ShutdownContext(context.Background())
+}
+
+// ShutdownContext gracefully closes the GERTe Socket like Shutdown, bounded by ctx.
+// It returns any errors encountered.
+// If ctx ends before the relay answers, the returned error wraps ctx.Err().
+// The socket is left open if the receive loop is running, otherwise it is closed and the session fails.
+func (api *Api) ShutdownContext(ctx context.Context) error {
+	defer api.notify()
+	api.mu.Lock()
+	if err := api.checkState(opShutdown); err != nil {
+		api.mu.Unlock()
+		return err
+	}
+	api.closing = true
+	api.mu.Unlock()
+	cmd, err := api.requestMessage(ctx, &Close{})
+	if err != nil {
+		return err
+	}
+	if cmd.Command == CommandState && cmd.Status.Status == StateClosed {
+		err = api.closeSocket(SessionClosed, nil)
 		if err != nil {
-			return fmt.Errorf("error on parsing response: %w", err)
-		}
-		if cmd.Command == CommandState && cmd.Status.Status == StateClosed {
-			err = api.socket.Close()
-			if err != nil {
-				return fmt.Errorf("error on close socket: %w", err)
-			}
-			api.socket = nil
-			return nil
+			return fmt.Errorf("error on close socket: %w", err)
 		}
-		return fmt.Errorf("no valid response received")
+		return nil
 	}
-	return fmt.Errorf("socket already closed")
+	return fmt.Errorf("%w: %v", ErrInvalidResponse, cmd)
 }
 
 // Parse reads data from the GERTe socket and parses it.
 // It returns the received Command and any errors encountered.
 // The official API only checks the connection for data when requested.
 // This includes connection closures from the relay.
-// If the connection is closed, the API will call the error function instead of returning anything.
+// If the relay closes the connection, the socket is closed and the session state changes to SessionClosed.
+// Parse must not be used while the receive loop started by Receive is running.
+// Inbound DATA packets pass the middleware added with UseInbound, dropped packets are skipped.
+// Parse waits for running commands to receive their replies first, and commands sent meanwhile wait for Parse to return.
 func (api *Api) Parse() (Command, error) {
-	data := make([]byte, 1024)
-	// _, err := bufio.NewReader(api.socket).Read(data)
-	n, err := api.socket.Read(data)
-	if err != nil {
-		return Command{}, fmt.Errorf("error on read data (%v bytes): %w", n, err)
+	defer api.notify()
+	api.sendMu.Lock()
+	defer api.sendMu.Unlock()
+	return api.parse()
+}
+
+// parse reads and parses the next frame, api.sendMu must be held
+func (api *Api) parse() (Command, error) {
+	api.mu.Lock()
+	receiving := api.receiving
+	c := api.socket
+	api.mu.Unlock()
+	if receiving {
+		return Command{}, fmt.Errorf("receive loop is running")
 	}
-	cmd, err := CommandFromBytes(data)
-	if err != nil {
-		return Command{}, fmt.Errorf("error parsing command: %w", err)
+	if c == nil {
+		return Command{}, ErrNotConnected
+	}
+	var cmd Command
+	for {
+		var err error
+		cmd, err = api.reader(c).Decode()
+		if err != nil {
+			return Command{}, fmt.Errorf("error on read data: %w", err)
+		}
+		if cmd.Command != CommandData {
+			break
+		}
+		var ok bool
+		if cmd.Packet, ok = api.receivePacket(cmd.Packet); ok {
+			break
+		}
 	}
 
 	switch cmd.Command {
 	case CommandRegister:
-		return cmd, fmt.Errorf("geds returned command register")
+		return cmd, fmt.Errorf("%w: geds returned command register", ErrInvalidResponse)
 	case CommandClose:
-		err := api.socket.Close()
+		err := api.closeSocket(SessionClosed, nil)
 		if err != nil {
 			return Command{}, fmt.Errorf("error while closing socket: %w", err)
 		}
-		api.socket = nil
-		break
 	}
 
 	return cmd, nil
 }
+
+// request writes a command to the GERTe socket and waits for the relay's reply, bounded by ctx.
+// It returns the reply and any errors encountered.
+// If the receive loop is running, the reply is the next STATE reply not claimed by an earlier request,
+// otherwise the next frame is read with Parse.
+// Without the receive loop, the deadline of ctx is applied to the socket; the reply of a request that times out
+// can't be told apart from later replies anymore, so the connection is closed and the session fails.
+// With the receive loop, a reply arriving after ctx ended is discarded and later requests are unaffected.
+func (api *Api) request(ctx context.Context, data []byte) (Command, error) {
+	return api.requestNotify(ctx, data, nil)
+}
+
+// requestMessage marshals m and sends it like request
+func (api *Api) requestMessage(ctx context.Context, m Message) (Command, error) {
+	frame, err := api.marshal(m)
+	if err != nil {
+		return Command{}, fmt.Errorf("error on marshal message: %w", err)
+	}
+	return api.request(ctx, frame)
+}
+
+// MaxData returns the largest payload a Packet can carry with the negotiated version,
+// MaxExtendedData if the relay negotiated ExtendedVersion and MaxData otherwise.
+func (api *Api) MaxData() int {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if api.codec == nil {
+		return DefaultCodec.MaxData()
+	}
+	return api.codec.MaxData()
+}
+
+// marshal encodes m with the FrameCodec of the negotiated version
+func (api *Api) marshal(m Message) ([]byte, error) {
+	api.mu.Lock()
+	codec := api.codec
+	api.mu.Unlock()
+	if codec == nil {
+		codec = DefaultCodec
+	}
+	return codec.Marshal(m)
+}
+
+// requestNotify sends a command like request, calling written once the command was written if it is not nil
+func (api *Api) requestNotify(ctx context.Context, data []byte, written func()) (Command, error) {
+	if written == nil {
+		written = func() {}
+	}
+	api.sendMu.Lock()
+	api.mu.Lock()
+	c := api.socket
+	if c == nil {
+		api.mu.Unlock()
+		api.sendMu.Unlock()
+		written()
+		return Command{}, ErrNotConnected
+	}
+	if !api.receiving {
+		api.mu.Unlock()
+		defer api.sendMu.Unlock()
+		stop := watchContext(ctx, c.SetDeadline)
+		defer stop()
+		_, err := c.Write(data)
+		written()
+		if err != nil {
+			return Command{}, fmt.Errorf("error on write: %w", api.abandon(ctx, err))
+		}
+		cmd, err := api.parse()
+		if err != nil {
+			return Command{}, fmt.Errorf("error on parse response: %w", api.abandon(ctx, err))
+		}
+		return cmd, nil
+	}
+	reply := make(chan Command, 1)
+	api.pending = append(api.pending, reply)
+	done := api.done
+	api.mu.Unlock()
+	stop := watchContext(ctx, c.SetWriteDeadline)
+	n, err := c.Write(data)
+	stop()
+	if err != nil {
+		api.dropPending(reply)
+		if n > 0 {
+			// the relay got a partial command, the connection can't be used anymore
+			_ = api.closeSocket(SessionFailed, err)
+		}
+	}
+	api.sendMu.Unlock()
+	written()
+	if err != nil {
+		return Command{}, fmt.Errorf("error on write: %w", contextError(ctx, err))
+	}
+
+	select {
+	case cmd := <-reply:
+		return cmd, nil
+	case <-ctx.Done():
+		return Command{}, fmt.Errorf("error on parse response: %w", ctx.Err())
+	case <-done:
+		select {
+		case cmd := <-reply:
+			return cmd, nil
+		default:
+		}
+		return Command{}, fmt.Errorf("error on parse response: %w", api.Err())
+	}
+}
+
+// abandon handles an error of a request without the receive loop like contextError.
+// If ctx ended, the reply of the relay may still arrive and would be taken as the reply of the next request,
+// so the socket is closed and the session fails.
+func (api *Api) abandon(ctx context.Context, err error) error {
+	err = contextError(ctx, err)
+	if ctx.Err() != nil || errors.Is(err, context.DeadlineExceeded) {
+		_ = api.closeSocket(SessionFailed, err)
+	}
+	return err
+}
+
+// dropPending removes the reply waiter of a command that was never sent
+func (api *Api) dropPending(reply chan Command) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	for i, r := range api.pending {
+		if r == reply {
+			api.pending = append(api.pending[:i:i], api.pending[i+1:]...)
+			return
+		}
+	}
+}
+
+// watchContext applies the deadline and cancellation of ctx to a connection using setDeadline.
+// It returns a function that stops watching ctx and clears the deadline again.
+func watchContext(ctx context.Context, setDeadline func(time.Time) error) func() {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = setDeadline(deadline)
+	}
+	stop := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		select {
+		case <-ctx.Done():
+			// a deadline in the past aborts blocked reads and writes immediately
+			_ = setDeadline(time.Unix(1, 0))
+		case <-stop:
+		}
+	}()
+	return func() {
+		close(stop)
+		<-finished
+		_ = setDeadline(time.Time{})
+	}
+}
+
+// contextError replaces err with the error of ctx if ctx ended while the operation was running
+func contextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	// the socket deadline can fire just before the context notices its own deadline
+	var netErr net.Error
+	if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) && errors.As(err, &netErr) && netErr.Timeout() {
+		return context.DeadlineExceeded
+	}
+	return err
+}
+
+// reader returns the Decoder for the socket c, api.sendMu must be held
+func (api *Api) reader(c net.Conn) *Decoder {
+	if api.decoder == nil || api.decoderConn != c {
+		api.decoder = NewDecoder(c)
+		api.decoderConn = c
+	}
+	return api.decoder
+}
+
+// closeSocket closes the socket and forgets it together with the registration, the session state changes to state.
+// cause is the error that made the session fail if state is SessionFailed.
+func (api *Api) closeSocket(state SessionState, cause error) error {
+	api.mu.Lock()
+	c := api.socket
+	api.socket = nil
+	api.setState(state, cause)
+	api.mu.Unlock()
+	if c == nil {
+		return nil
+	}
+	return c.Close()
+}
+
+// Registration returns the GERTe address registered with Register and whether the Api is registered.
+// It is safe to call while other goroutines use the Api.
+func (api *Api) Registration() (GertAddress, bool) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	return api.Address, api.Registered
+}
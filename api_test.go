@@ -2,6 +2,7 @@ package gerte
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"net"
 	"sync"
@@ -27,7 +28,8 @@ func StartupSuccessful(t *testing.T) {
 			t.Errorf("server errored on read: %+v", err)
 		}
 
-		t.Logf("server received: %v", VersionFromBytes(dat))
+		ver, _ := VersionFromBytes(dat)
+		t.Logf("server received: %v", ver)
 
 		cmd := []byte{byte(CommandState), byte(StateConnected)}
 		verByte := Version{
@@ -64,7 +66,7 @@ func StartupSuccessful(t *testing.T) {
 	if err != nil {
 		t.Errorf("client errored on startup: %+v", err)
 	}
-	err = api.socket.Close()
+	err = client.Close()
 	if err != nil {
 		t.Errorf("client errored on close socket: %+v", err)
 	}
@@ -82,7 +84,8 @@ func StartupUnsuccessful(t *testing.T) {
 			t.Errorf("server errored on read: %+v", err)
 		}
 
-		t.Logf("server received: %v", VersionFromBytes(dat))
+		ver, _ := VersionFromBytes(dat)
+		t.Logf("server received: %v", ver)
 
 		cmd := []byte{byte(CommandState), byte(StateFailure), byte(ErrorVersion)}
 		p, err := PrettyPrint(cmd)
@@ -111,14 +114,14 @@ func StartupUnsuccessful(t *testing.T) {
 
 	err := api.Startup(client)
 	if err != nil {
-		if err.Error() == fmt.Sprintf("incompatible version during negotiation: %v", Version{0, 0, 0}) {
+		if err.Error() == fmt.Sprintf("incompatible version during negotiation: relay rejected %v", api.Version) {
 			t.Logf("client errored successfully on startup: %+v", err)
 		} else {
 			t.Errorf("client errored on startup: %+v", err)
 		}
 
 	}
-	err = api.socket.Close()
+	err = client.Close()
 	if err != nil {
 		t.Errorf("client errored on close socket: %+v", err)
 	}
@@ -136,7 +139,8 @@ func StartupSent(t *testing.T) {
 			t.Errorf("server errored on read: %+v", err)
 		}
 
-		t.Logf("server received: %v", VersionFromBytes(dat))
+		ver, _ := VersionFromBytes(dat)
+		t.Logf("server received: %v", ver)
 
 		cmd := []byte{byte(CommandState), byte(StateSent)}
 		p, err := PrettyPrint(cmd)
@@ -172,7 +176,7 @@ func StartupSent(t *testing.T) {
 		}
 
 	}
-	err = api.socket.Close()
+	err = client.Close()
 	if err != nil {
 		t.Errorf("client errored on close socket: %+v", err)
 	}
@@ -190,7 +194,8 @@ func StartupAssigned(t *testing.T) {
 			t.Errorf("server errored on read: %+v", err)
 		}
 
-		t.Logf("server received: %v", VersionFromBytes(dat))
+		ver, _ := VersionFromBytes(dat)
+		t.Logf("server received: %v", ver)
 
 		cmd := []byte{byte(CommandState), byte(StateAssigned)}
 		p, err := PrettyPrint(cmd)
@@ -226,7 +231,7 @@ func StartupAssigned(t *testing.T) {
 		}
 
 	}
-	err = api.socket.Close()
+	err = client.Close()
 	if err != nil {
 		t.Errorf("client errored on close socket: %+v", err)
 	}
@@ -244,7 +249,8 @@ func StartupInvalidCmd(t *testing.T) {
 			t.Errorf("server errored on read: %+v", err)
 		}
 
-		t.Logf("server received: %v", VersionFromBytes(dat))
+		ver, _ := VersionFromBytes(dat)
+		t.Logf("server received: %v", ver)
 
 		cmd := []byte(string([]byte{byte(CommandRegister)}) +
 			string(GertAddress{Upper: 0, Lower: 0}.ToBytes()) +
@@ -275,14 +281,14 @@ func StartupInvalidCmd(t *testing.T) {
 
 	err := api.Startup(client)
 	if err != nil {
-		if err.Error() == "error on parse response: geds returned command register" {
+		if errors.Is(err, ErrInvalidResponse) {
 			t.Logf("client errored successfully on startup: %+v", err)
 		} else {
 			t.Errorf("client errored on startup: %+v", err)
 		}
 
 	}
-	err = api.socket.Close()
+	err = client.Close()
 	if err != nil {
 		t.Errorf("client errored on close socket: %+v", err)
 	}
@@ -323,7 +329,7 @@ func TestApi_Shutdown(t *testing.T) {
 	}()
 
 	var api Api
-	api.socket = client
+	attach(&api, client, SessionConnected)
 
 	err := api.Shutdown()
 	if err != nil {
@@ -373,13 +379,13 @@ func RegisterSuccessful(t *testing.T) {
 	}()
 
 	var api Api
-	api.socket = client
+	attach(&api, client, SessionConnected)
 	addr, _ := AddressFromString("0000.1999")
-	_, err := api.Register(addr, "test")
+	_, err := api.Register(addr, "testtesttesttesttest")
 	if err != nil {
 		t.Errorf("client errored on register: %+v", err)
 	}
-	err = api.socket.Close()
+	err = client.Close()
 	if err != nil {
 		t.Errorf("client errored on close socket: %+v", err)
 	}
@@ -419,9 +425,9 @@ func RegisterBadKey(t *testing.T) {
 	}()
 
 	var api Api
-	api.socket = client
+	attach(&api, client, SessionConnected)
 	addr, _ := AddressFromString("0000.1999")
-	_, err := api.Register(addr, "test")
+	_, err := api.Register(addr, "testtesttesttesttest")
 	if err != nil {
 		if err.Error() == "key did not match that used for the requested address. Requested address may not exist" {
 			t.Logf("client errored successfully on register: %+v", err)
@@ -430,7 +436,7 @@ func RegisterBadKey(t *testing.T) {
 		}
 
 	}
-	err = api.socket.Close()
+	err = client.Close()
 	if err != nil {
 		t.Errorf("client errored on close socket: %+v", err)
 	}
@@ -470,9 +476,9 @@ func RegisterAddressTaken(t *testing.T) {
 	}()
 
 	var api Api
-	api.socket = client
+	attach(&api, client, SessionConnected)
 	addr, _ := AddressFromString("0000.1999")
-	_, err := api.Register(addr, "test")
+	_, err := api.Register(addr, "testtesttesttesttest")
 	if err != nil {
 		if err.Error() == "address request has already been claimed" {
 			t.Logf("client errored successfully on register: %+v", err)
@@ -481,7 +487,7 @@ func RegisterAddressTaken(t *testing.T) {
 		}
 
 	}
-	err = api.socket.Close()
+	err = client.Close()
 	if err != nil {
 		t.Errorf("client errored on close socket: %+v", err)
 	}
@@ -521,9 +527,9 @@ func RegisterAlreadyRegistered(t *testing.T) {
 	}()
 
 	var api Api
-	api.socket = client
+	attach(&api, client, SessionConnected)
 	addr, _ := AddressFromString("0000.1999")
-	_, err := api.Register(addr, "test")
+	_, err := api.Register(addr, "testtesttesttesttest")
 	if err != nil {
 		if err.Error() == "registration has already been performed successfully" {
 			t.Logf("client errored successfully on register: %+v", err)
@@ -532,7 +538,7 @@ func RegisterAlreadyRegistered(t *testing.T) {
 		}
 
 	}
-	err = api.socket.Close()
+	err = client.Close()
 	if err != nil {
 		t.Errorf("client errored on close socket: %+v", err)
 	}
@@ -578,7 +584,7 @@ func TransmitSuccessful(t *testing.T) {
 	}()
 
 	var api Api
-	api.socket = client
+	attach(&api, client, SessionAssigned)
 	addrE, _ := AddressFromString("0000.1999")
 	addrI, _ := AddressFromString("0123.0456")
 	gertC := GERTc{
@@ -595,7 +601,7 @@ func TransmitSuccessful(t *testing.T) {
 		t.Errorf("client errored on transmit: %+v", err)
 	}
 
-	err = api.socket.Close()
+	err = client.Close()
 	if err != nil {
 		t.Errorf("client errored on close socket: %+v", err)
 	}
@@ -603,39 +609,10 @@ func TransmitSuccessful(t *testing.T) {
 }
 func TransmitNotRegistered(t *testing.T) {
 	server, client := net.Pipe()
-	var wg sync.WaitGroup
-	wg.Add(1)
-	go func() {
-		dat := make([]byte, 1024)
-		_, err := bufio.NewReader(server).Read(dat)
-		if err != nil {
-			t.Errorf("server errored on read: %+v", err)
-		}
-		p, err := PrettyPrint(dat)
-		if err != nil {
-			t.Errorf("server errored on pretty print: %+v", err)
-		}
-		t.Logf("server received: %v", p)
-		cmd := []byte{byte(CommandState), byte(StateFailure), byte(ErrorNotRegistered)}
-		p, err = PrettyPrint(cmd)
-		if err != nil {
-			t.Errorf("server errored on pretty print: %+v", err)
-		}
-		t.Logf("server sent: %v", p)
-		_, err = server.Write(cmd)
-		if err != nil {
-			t.Errorf("server errored on write: %+v", err)
-		}
-
-		err = server.Close()
-		if err != nil {
-			t.Errorf("server errored on close: %+v", err)
-		}
-		wg.Done()
-	}()
+	defer server.Close()
 
 	var api Api
-	api.socket = client
+	attach(&api, client, SessionConnected)
 	addrE, _ := AddressFromString("0000.1999")
 	addrI, _ := AddressFromString("0123.0456")
 	gertC := GERTc{
@@ -647,20 +624,15 @@ func TransmitNotRegistered(t *testing.T) {
 		Target: gertC,
 		Data:   []byte("hello world!"),
 	}
+	// the relay never reads, so transmit only returns if it is rejected locally
 	_, err := api.Transmit(pkt)
-
-	if err != nil {
-		if err.Error() == "gateway cannot send data before claiming an address" {
-			t.Logf("client errored successfully on transmit: %+v", err)
-		} else {
-			t.Errorf("client errored on transmit: %+v", err)
-		}
+	if !errors.Is(err, ErrNotRegistered) {
+		t.Errorf("got %+v, want %+v", err, ErrNotRegistered)
 	}
-	err = api.socket.Close()
+	err = client.Close()
 	if err != nil {
 		t.Errorf("client errored on close socket: %+v", err)
 	}
-	wg.Wait()
 }
 func TransmitNoRoute(t *testing.T) {
 	server, client := net.Pipe()
@@ -696,7 +668,7 @@ func TransmitNoRoute(t *testing.T) {
 	}()
 
 	var api Api
-	api.socket = client
+	attach(&api, client, SessionAssigned)
 	addrE, _ := AddressFromString("0000.1999")
 	addrI, _ := AddressFromString("0123.0456")
 	gertC := GERTc{
@@ -717,7 +689,7 @@ func TransmitNoRoute(t *testing.T) {
 		}
 	}
 
-	err = api.socket.Close()
+	err = client.Close()
 	if err != nil {
 		t.Errorf("client errored on close socket: %+v", err)
 	}
@@ -755,16 +727,23 @@ func TestApi_Parse(t *testing.T) {
 	}()
 
 	var api Api
-	api.socket = client
+	attach(&api, client, SessionConnected)
 	cmd, err := api.Parse()
 	if err != nil {
 		t.Errorf("client errored on parse response: %+v", err)
 	}
 
 	t.Logf("client received: %#v", cmd)
-	err = api.socket.Close()
+	err = client.Close()
 	if err != nil {
 		t.Errorf("client errored on close socket: %+v", err)
 	}
 	wg.Wait()
 }
+
+// attach hands the connection c to api as if its session had reached state
+func attach(api *Api, c net.Conn, state SessionState) {
+	api.socket = c
+	api.state = state
+	api.Registered = state == SessionAssigned
+}
@@ -0,0 +1,155 @@
+package gerte
+
+import (
+	"fmt"
+	"io"
+)
+
+type (
+	// ClientCodec reads and writes the frames of the gateway side of a connection.
+	// It writes the messages gateways send and reads the messages relays send.
+	// Reads and writes may happen concurrently, but only one goroutine may read and one may write at a time.
+	ClientCodec struct {
+		dec *Decoder
+		w   io.Writer
+	}
+
+	// ServerCodec reads and writes the frames of the relay side of a connection.
+	// It reads the messages gateways send and writes the messages relays send.
+	// Reads and writes may happen concurrently, but only one goroutine may read and one may write at a time.
+	ServerCodec struct {
+		dec *Decoder
+		w   io.Writer
+	}
+)
+
+// NewClientCodec is the constructor for ClientCodec, it buffers reads from rw
+func NewClientCodec(rw io.ReadWriter) *ClientCodec {
+	return &ClientCodec{
+		dec: NewDecoder(rw),
+		w:   rw,
+	}
+}
+
+// SetCodec makes the ClientCodec use the frame layout of codec once a version was negotiated, a nil codec selects DefaultCodec.
+// It must not be called concurrently with reads or writes.
+func (c *ClientCodec) SetCodec(codec FrameCodec) {
+	c.dec.SetCodec(codec)
+}
+
+// WriteVersion writes the version a gateway requests at the start of a connection.
+// It returns any encountered errors.
+func (c *ClientCodec) WriteVersion(ver Version) error {
+	return writeFrame(c.w, ver.ToBytes())
+}
+
+// WriteMessage writes a StateRequest, Register, OutboundData or Close message.
+// It returns any encountered errors.
+func (c *ClientCodec) WriteMessage(m Message) error {
+	switch m.(type) {
+	case *StateRequest, *Register, *OutboundData, *Close:
+	default:
+		return fmt.Errorf("message %T is not sent by gateways", m)
+	}
+	return writeMessage(c.w, c.dec.codec, m)
+}
+
+// ReadMessage reads the next frame sent by the relay.
+// It returns a *StateReply, *InboundData or *Close and any encountered errors, io.EOF if the stream ended cleanly between two frames.
+func (c *ClientCodec) ReadMessage() (Message, error) {
+	frame, err := c.dec.ReadFrame()
+	if err != nil {
+		return nil, err
+	}
+	var m Message
+	switch GertCommand(frame[0]) {
+	case CommandState:
+		m = new(StateReply)
+	case CommandData:
+		m = new(InboundData)
+	case CommandClose:
+		m = new(Close)
+	default:
+		return nil, fmt.Errorf("%w: relay sent command %v", ErrInvalidResponse, frame[0])
+	}
+	if err := c.dec.codec.Unmarshal(frame, m); err != nil {
+		return nil, fmt.Errorf("error parsing message: %w", err)
+	}
+	return m, nil
+}
+
+// NewServerCodec is the constructor for ServerCodec, it buffers reads from rw
+func NewServerCodec(rw io.ReadWriter) *ServerCodec {
+	return &ServerCodec{
+		dec: NewDecoder(rw),
+		w:   rw,
+	}
+}
+
+// SetCodec makes the ServerCodec use the frame layout of codec once a version was negotiated, a nil codec selects DefaultCodec.
+// It must not be called concurrently with reads or writes.
+func (c *ServerCodec) SetCodec(codec FrameCodec) {
+	c.dec.SetCodec(codec)
+}
+
+// ReadVersion reads the version a gateway requests at the start of a connection.
+// It returns the Version and any encountered errors.
+func (c *ServerCodec) ReadVersion() (Version, error) {
+	frame, err := readN(c.dec.r, nil, 2)
+	if err != nil {
+		return Version{}, err
+	}
+	return VersionFromBytes(frame)
+}
+
+// ReadMessage reads the next frame sent by the gateway.
+// It returns a *StateRequest, *Register, *OutboundData or *Close and any encountered errors, io.EOF if the stream ended cleanly between two frames.
+func (c *ServerCodec) ReadMessage() (Message, error) {
+	frame, err := c.dec.readGatewayFrame()
+	if err != nil {
+		return nil, err
+	}
+	var m Message
+	switch GertCommand(frame[0]) {
+	case CommandState:
+		m = new(StateRequest)
+	case CommandRegister:
+		m = new(Register)
+	case CommandData:
+		m = new(OutboundData)
+	case CommandClose:
+		m = new(Close)
+	}
+	if err := c.dec.codec.Unmarshal(frame, m); err != nil {
+		return nil, fmt.Errorf("error parsing message: %w", err)
+	}
+	return m, nil
+}
+
+// WriteMessage writes a StateReply, InboundData or Close message.
+// It returns any encountered errors.
+func (c *ServerCodec) WriteMessage(m Message) error {
+	switch m.(type) {
+	case *StateReply, *InboundData, *Close:
+	default:
+		return fmt.Errorf("message %T is not sent by relays", m)
+	}
+	return writeMessage(c.w, c.dec.codec, m)
+}
+
+// writeMessage marshals m with codec and writes it to w as a single frame
+func writeMessage(w io.Writer, codec FrameCodec, m Message) error {
+	frame, err := codec.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("error on marshal message: %w", err)
+	}
+	return writeFrame(w, frame)
+}
+
+// writeFrame writes frame to w with a single Write call
+func writeFrame(w io.Writer, frame []byte) error {
+	if _, err := w.Write(frame); err != nil {
+		return fmt.Errorf("error on write: %w", err)
+	}
+	return nil
+}
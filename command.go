@@ -25,8 +25,12 @@ const (
 	CommandClose
 )
 
-// CommandFromBytes parses bytes to a GERT Command
+// CommandFromBytes parses bytes to a GERT Command.
+// It returns the Command and any encountered errors.
 func CommandFromBytes(data []byte) (Command, error) {
+	if err := checkLength(data, 1, "command"); err != nil {
+		return Command{}, err
+	}
 	switch data[0] {
 	case byte(CommandState):
 		state, err := StatusFromBytes(data[1:])
@@ -38,6 +42,9 @@ func CommandFromBytes(data []byte) (Command, error) {
 			Status:  state,
 		}, nil
 	case byte(CommandRegister):
+		if err := checkLength(data, 24, "register command"); err != nil {
+			return Command{}, err
+		}
 		return Command{
 			Command: CommandRegister,
 		}, nil
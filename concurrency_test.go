@@ -0,0 +1,94 @@
+package gerte_test
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/OmegaRogue/gerte-go"
+)
+
+// hammer transmits from many goroutines at once, half of the packets go to an unknown gateway and must fail.
+// Every call has to get the reply to its own command.
+func hammer(t *testing.T, api *gerte.Api, target, unknown gerte.GERTc) {
+	const goroutines, packets = 8, 25
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < packets; i++ {
+				fail := (g+i)%2 == 0
+				to := target
+				if fail {
+					to = unknown
+				}
+				ok, err := api.Transmit(gerte.Packet{Target: to, Data: []byte(fmt.Sprintf("%v-%v", g, i))})
+				if fail && (ok || err == nil || !errors.Is(err, gerte.ErrNoRoute)) {
+					t.Errorf("transmit to unknown gateway: got %v %v", ok, err)
+				}
+				if !fail && (!ok || err != nil) {
+					t.Errorf("transmit: got %v %+v", ok, err)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestApi_Concurrent(t *testing.T) {
+	addrA := gerte.GertAddress{Upper: 1123, Lower: 1456}
+	addrB := gerte.GertAddress{Upper: 2345, Lower: 1456}
+	srv := startRelay(t, addrA, addrB)
+	target := gerte.GERTc{GERTe: addrB}
+	unknown := gerte.GERTc{GERTe: gerte.GertAddress{Upper: 3456, Lower: 1456}}
+
+	apiB := registerApi(t, srv, addrB)
+	defer apiB.Shutdown()
+	if err := apiB.Receive(func(gerte.Packet) {}); err != nil {
+		t.Fatalf("error on receive: %+v", err)
+	}
+
+	t.Run("ConcurrentSync", func(t *testing.T) {
+		apiA := registerApi(t, srv, addrA)
+		defer apiA.Shutdown()
+		hammer(t, apiA, target, unknown)
+	})
+	t.Run("ConcurrentReceiveLoop", func(t *testing.T) {
+		apiA := registerApi(t, srv, addrA)
+		defer apiA.Shutdown()
+		if err := apiA.Receive(nil); err != nil {
+			t.Fatalf("error on receive: %+v", err)
+		}
+		hammer(t, apiA, target, unknown)
+	})
+	t.Run("ConcurrentRegistration", func(t *testing.T) {
+		apiA := registerApi(t, srv, addrA)
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				if addr, ok := apiA.Registration(); !ok || addr != addrA {
+					t.Errorf("got %v %v, want %v true", addr, ok, addrA)
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			hammer(t, apiA, target, unknown)
+		}()
+		wg.Wait()
+		if err := apiA.Shutdown(); err != nil {
+			t.Fatalf("error on shutdown: %+v", err)
+		}
+		if _, ok := apiA.Registration(); ok {
+			t.Error("still registered after shutdown")
+		}
+		if _, err := apiA.Transmit(gerte.Packet{Target: target}); err == nil {
+			t.Error("transmit after shutdown succeeded")
+		}
+	})
+}
@@ -0,0 +1,170 @@
+package gerte
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// silentRelay reads everything sent to it and never answers
+func silentRelay(server net.Conn) *sync.WaitGroup {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		dat := make([]byte, 1024)
+		for {
+			if _, err := server.Read(dat); err != nil {
+				return
+			}
+		}
+	}()
+	return &wg
+}
+
+func TestApi_StartupContext(t *testing.T) {
+	server, client := net.Pipe()
+	wg := silentRelay(server)
+
+	api := NewApi(Version{Major: 1, Minor: 1})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := api.StartupContext(ctx, client)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %+v", err)
+	}
+	if api.SessionState() != SessionFailed {
+		t.Errorf("got session state %v, want %v", api.SessionState(), SessionFailed)
+	}
+	server.Close()
+	wg.Wait()
+
+	// the failed session can start again on a new connection
+	server, client = net.Pipe()
+	defer server.Close()
+	go func() {
+		dat := make([]byte, 2)
+		if _, err := server.Read(dat); err == nil {
+			server.Write([]byte{byte(CommandState), byte(StateConnected), 1, 1})
+		}
+	}()
+	if err := api.Startup(client); err != nil {
+		t.Errorf("error on startup after timeout: %+v", err)
+	}
+	if api.SessionState() != SessionConnected {
+		t.Errorf("got session state %v, want %v", api.SessionState(), SessionConnected)
+	}
+}
+
+func TestApi_RegisterContext(t *testing.T) {
+	server, client := net.Pipe()
+	wg := silentRelay(server)
+
+	var api Api
+	attach(&api, client, SessionConnected)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	_, err := api.RegisterContext(ctx, GertAddress{Upper: 1, Lower: 1}, "aaaaaaaaaaaaaaaaaaaa")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancellation, got %+v", err)
+	}
+	client.Close()
+	server.Close()
+	wg.Wait()
+}
+
+func TestApi_TransmitContext(t *testing.T) {
+	server, client := net.Pipe()
+	replies := make(chan []byte)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		dat := make([]byte, 1024)
+		for reply := range replies {
+			if _, err := server.Read(dat); err != nil {
+				t.Errorf("server errored on read: %+v", err)
+				return
+			}
+			if reply == nil {
+				continue
+			}
+			if _, err := server.Write(reply); err != nil {
+				t.Errorf("server errored on write: %+v", err)
+				return
+			}
+		}
+	}()
+
+	var api Api
+	attach(&api, client, SessionAssigned)
+	if err := api.Receive(func(Packet) {}); err != nil {
+		t.Fatalf("client errored on receive: %+v", err)
+	}
+	pkt := Packet{Data: []byte("hello world!")}
+
+	// the first transmit times out, its reply only arrives later and must not be used by the second one
+	replies <- nil
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := api.TransmitContext(ctx, pkt)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %+v", err)
+	}
+
+	replies <- nil
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		if _, err := server.Write([]byte{byte(CommandState), byte(StateFailure), byte(ErrorNoRoute)}); err != nil {
+			t.Errorf("server errored on write: %+v", err)
+		}
+		if _, err := server.Write([]byte{byte(CommandState), byte(StateSent)}); err != nil {
+			t.Errorf("server errored on write: %+v", err)
+		}
+	}()
+	ok, err := api.TransmitContext(context.Background(), pkt)
+	if !ok || err != nil {
+		t.Errorf("client received stale reply: %v %+v", ok, err)
+	}
+	close(replies)
+	client.Close()
+	wg.Wait()
+	server.Close()
+}
+
+func TestApi_TransmitContextWithoutReceive(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	read := make(chan struct{})
+	go func() {
+		dat := make([]byte, 1024)
+		if _, err := server.Read(dat); err != nil {
+			return
+		}
+		<-read
+		// the reply only arrives after the transmit timed out
+		server.Write([]byte{byte(CommandState), byte(StateFailure), byte(ErrorNoRoute)})
+	}()
+
+	var api Api
+	attach(&api, client, SessionAssigned)
+	pkt := Packet{Data: []byte("hello world!")}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := api.TransmitContext(ctx, pkt)
+	close(read)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %+v", err)
+	}
+	if api.SessionState() != SessionFailed {
+		t.Errorf("got session state %v, want %v", api.SessionState(), SessionFailed)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := api.TransmitContext(ctx, pkt); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("got %+v, want %+v instead of the stale reply", err, ErrNotConnected)
+	}
+}
@@ -0,0 +1,76 @@
+package gerte
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// Decoder reads frames sent by a GERTe relay from an input stream.
+// Unlike CommandFromBytes it knows the wire length of every command,
+// so frames coalesced into a single read or split across several reads are decoded correctly.
+type Decoder struct {
+	r     *bufio.Reader
+	codec FrameCodec
+}
+
+// NewDecoder is the constructor for Decoder, it buffers reads from r and reads frames with DefaultCodec
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r), codec: DefaultCodec}
+}
+
+// Decode reads exactly one frame from the stream and parses it.
+// It returns the parsed Command and any encountered errors.
+// It returns io.EOF if the stream ended cleanly between two frames and io.ErrUnexpectedEOF if it ended inside a frame.
+// After any other error the stream position is undefined and the Decoder should not be used anymore.
+func (dec *Decoder) Decode() (Command, error) {
+	frame, err := dec.ReadFrame()
+	if err != nil {
+		return Command{}, err
+	}
+	var m Message
+	switch GertCommand(frame[0]) {
+	case CommandState:
+		m = new(StateReply)
+	case CommandData:
+		m = new(InboundData)
+	default:
+		// REGISTER and CLOSE carry nothing the Command holds
+		cmd, err := CommandFromBytes(frame)
+		if err != nil {
+			return Command{}, fmt.Errorf("error parsing command: %w", err)
+		}
+		return cmd, nil
+	}
+	if err := dec.codec.Unmarshal(frame, m); err != nil {
+		return Command{}, fmt.Errorf("error parsing command: %w", err)
+	}
+	switch m := m.(type) {
+	case *StateReply:
+		return Command{Command: CommandState, Status: m.Status}, nil
+	case *InboundData:
+		return Command{Command: CommandData, Packet: m.Packet()}, nil
+	}
+	return Command{}, nil
+}
+
+// ReadFrame reads the raw bytes of exactly one frame from the stream, including the command byte.
+// It returns the frame and any encountered errors.
+func (dec *Decoder) ReadFrame() ([]byte, error) {
+	return dec.codec.ReadRelayFrame(dec.r)
+}
+
+// SetCodec makes the Decoder read frames with the layout of codec, a nil codec selects DefaultCodec.
+// It is used once a version was negotiated and must not be called concurrently with reads.
+func (dec *Decoder) SetCodec(codec FrameCodec) {
+	if codec == nil {
+		codec = DefaultCodec
+	}
+	dec.codec = codec
+}
+
+// readGatewayFrame reads the raw bytes of exactly one frame sent by a gateway, including the command byte.
+// It returns the frame and any encountered errors.
+func (dec *Decoder) readGatewayFrame() ([]byte, error) {
+	return dec.codec.ReadGatewayFrame(dec.r)
+}
@@ -0,0 +1,73 @@
+package gerte
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func TestDecoder_Decode(t *testing.T) {
+	pkt := Packet{
+		Source: GERTc{GERTe: GertAddress{Upper: 2345, Lower: 1456}, GERTi: GertAddress{Upper: 1, Lower: 1}},
+		Target: GERTc{GERTe: GertAddress{Upper: 1123, Lower: 1456}, GERTi: GertAddress{Upper: 2, Lower: 2}},
+		Data:   []byte("hello world!"),
+	}
+	var stream bytes.Buffer
+	stream.Write([]byte{byte(CommandState), byte(StateConnected), 1, 1})
+	stream.Write([]byte{byte(CommandState), byte(StateAssigned)})
+	stream.Write(inboundFrame(pkt))
+	stream.Write([]byte{byte(CommandState), byte(StateFailure), byte(ErrorNoRoute)})
+	stream.Write([]byte{byte(CommandClose)})
+
+	expected := []Command{
+		{Command: CommandState, Status: Status{Status: StateConnected, Size: 4, Version: Version{Major: 1, Minor: 1}}},
+		{Command: CommandState, Status: Status{Status: StateAssigned, Size: 1}},
+		{Command: CommandData, Packet: pkt},
+		{Command: CommandState, Status: Status{Status: StateFailure, Size: 2, Error: ErrorNoRoute}},
+		{Command: CommandClose},
+	}
+
+	readers := map[string]io.Reader{
+		"Coalesced": bytes.NewReader(stream.Bytes()),
+		"Split":     iotest.OneByteReader(bytes.NewReader(stream.Bytes())),
+	}
+	for name, r := range readers {
+		dec := NewDecoder(r)
+		for i, cmd := range expected {
+			cmd2, err := dec.Decode()
+			if err != nil {
+				t.Fatalf("%v: error on decode command %v: %+v", name, i, err)
+			}
+			if cmd.Command != cmd2.Command ||
+				string(cmd.Packet.Data) != string(cmd2.Packet.Data) ||
+				cmd.Packet.Target != cmd2.Packet.Target ||
+				cmd.Packet.Source != cmd2.Packet.Source ||
+				cmd.Status != cmd2.Status {
+				t.Errorf("%v: commands don't match:\n%+v\n%+v", name, cmd, cmd2)
+			}
+		}
+		if _, err := dec.Decode(); err != io.EOF {
+			t.Errorf("%v: expected EOF after last frame, got %+v", name, err)
+		}
+	}
+}
+
+func TestDecoder_Truncated(t *testing.T) {
+	frame := inboundFrame(Packet{Data: []byte("hello world!")})
+	dec := NewDecoder(bytes.NewReader(frame[:len(frame)-1]))
+	_, err := dec.Decode()
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected unexpected EOF, got %+v", err)
+	}
+}
+
+func TestDecoder_Invalid(t *testing.T) {
+	for _, frame := range [][]byte{{42}, {byte(CommandState), 42}} {
+		dec := NewDecoder(bytes.NewReader(frame))
+		if _, err := dec.Decode(); err == nil {
+			t.Errorf("invalid frame %v was decoded", frame)
+		}
+	}
+}
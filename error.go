@@ -1,8 +1,12 @@
 package gerte
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
-// GertError is the Error Code in a "Failed" Status
+// GertError is the Error Code in a "Failed" Status.
+// It implements error, so the errors returned for a "Failed" Status can be checked with errors.Is and errors.As.
 type GertError byte
 
 const (
@@ -43,5 +47,94 @@ func (error GertError) String() string {
 
 // PrintError prints a GERT Error to a Human-readable string
 func (error GertError) GoString() string {
-	return fmt.Sprintf("[%v]", error)
+	return fmt.Sprintf("[%v]", error.String())
+}
+
+// Error returns the description of a GERT Error
+func (error GertError) Error() string {
+	switch error {
+	case ErrorVersion:
+		return "incompatible version during negotiation"
+	case ErrorBadKey:
+		return "key did not match that used for the requested address. Requested address may not exist"
+	case ErrorAlreadyRegistered:
+		return "registration has already been performed successfully"
+	case ErrorNotRegistered:
+		return "gateway cannot send data before claiming an address"
+	case ErrorNoRoute:
+		return "data failed to send because remote gateway could not be found"
+	case ErrorAddressTaken:
+		return "address request has already been claimed"
+	}
+	return "no valid error"
+}
+
+// Is reports whether the GERT Error matches target.
+// ErrorNotRegistered and ErrorAlreadyRegistered also match ErrNotRegistered and ErrAlreadyRegistered,
+// so they are handled like the errors returned when the Api rejects an operation itself.
+func (error GertError) Is(target error) bool {
+	switch target {
+	case ErrNotRegistered:
+		return error == ErrorNotRegistered
+	case ErrAlreadyRegistered:
+		return error == ErrorAlreadyRegistered
+	}
+	return false
+}
+
+// Temporary returns whether repeating the failed command later may succeed.
+// Only ErrorNoRoute and ErrorAddressTaken are temporary, the other errors need different parameters or another command first.
+func (error GertError) Temporary() bool {
+	return error == ErrorNoRoute || error == ErrorAddressTaken
+}
+
+var (
+	// ErrVersion is returned by Startup if the relay doesn't support the Version
+	ErrVersion error = ErrorVersion
+	// ErrBadKey is returned by Register if the key doesn't match the address
+	ErrBadKey error = ErrorBadKey
+	// ErrNoRoute is returned by Transmit if the relay couldn't find the remote gateway
+	ErrNoRoute error = ErrorNoRoute
+	// ErrAddressTaken is returned by Register if another gateway claimed the address
+	ErrAddressTaken error = ErrorAddressTaken
+	// ErrInvalidResponse is returned if the relay answered a command with something that isn't a valid reply
+	ErrInvalidResponse = errors.New("invalid response")
+	// ErrTooShort is returned when decoding data that is shorter than the message it has to contain
+	ErrTooShort = errors.New("data too short")
+	// ErrInvalidAddress is returned when parsing a string that is not an address
+	ErrInvalidAddress = errors.New("invalid address")
+	// ErrAddressRange is returned for an address whose halves don't fit into 12 bits
+	ErrAddressRange = errors.New("address out of range")
+)
+
+// checkLength returns an error wrapping ErrTooShort if data is shorter than n bytes, what names the decoded message
+func checkLength(data []byte, n int, what string) error {
+	if len(data) < n {
+		return fmt.Errorf("%w for %v: %v<%v", ErrTooShort, what, len(data), n)
+	}
+	return nil
+}
+
+// temporaryError is an error of the Api whose operation may succeed when repeated later
+type temporaryError struct {
+	msg string
+}
+
+func (err *temporaryError) Error() string {
+	return err.msg
+}
+
+// Temporary returns true
+func (err *temporaryError) Temporary() bool {
+	return true
+}
+
+// IsTemporary reports whether err or an error it wraps is temporary, so repeating the operation later may succeed.
+// This includes the temporary GertError codes, ErrRateLimited, ErrQueueFull and timeouts.
+// All other errors are permanent.
+func IsTemporary(err error) bool {
+	var temporary interface {
+		Temporary() bool
+	}
+	return errors.As(err, &temporary) && temporary.Temporary()
 }
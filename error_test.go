@@ -0,0 +1,101 @@
+package gerte
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+)
+
+func TestGertError(t *testing.T) {
+	t.Run("GertErrorString", func(t *testing.T) {
+		status := Status{Status: StateFailure, Error: ErrorNoRoute}
+		if got := status.String(); got != "FAILURE NO_ROUTE" {
+			t.Errorf("got %q, want %q", got, "FAILURE NO_ROUTE")
+		}
+		if got := fmt.Sprintf("%#v", ErrorNoRoute); got != "[NO_ROUTE]" {
+			t.Errorf("got %q, want %q", got, "[NO_ROUTE]")
+		}
+		if got := ErrorNoRoute.Error(); got != "data failed to send because remote gateway could not be found" {
+			t.Errorf("unexpected message %q", got)
+		}
+	})
+	t.Run("GertErrorIs", func(t *testing.T) {
+		err := fmt.Errorf("error on transmit: %w", Status{Status: StateFailure, Error: ErrorNotRegistered}.parseError())
+		if !errors.Is(err, ErrNotRegistered) {
+			t.Errorf("%+v doesn't match %+v", err, ErrNotRegistered)
+		}
+		if errors.Is(err, ErrNoRoute) {
+			t.Errorf("%+v matches %+v", err, ErrNoRoute)
+		}
+		var code GertError
+		if !errors.As(err, &code) || code != ErrorNotRegistered {
+			t.Errorf("got code %v, want %v", code, ErrorNotRegistered)
+		}
+		err = Status{Status: StateFailure, Error: ErrorVersion}.parseError()
+		if !errors.Is(err, ErrVersion) || err.Error() != "incompatible version during negotiation" {
+			t.Errorf("unexpected version error %+v", err)
+		}
+	})
+	t.Run("GertErrorTemporary", func(t *testing.T) {
+		tests := []struct {
+			err  error
+			want bool
+		}{
+			{ErrNoRoute, true},
+			{ErrAddressTaken, true},
+			{fmt.Errorf("wrapped: %w", ErrNoRoute), true},
+			{ErrBadKey, false},
+			{ErrVersion, false},
+			{ErrorNotRegistered, false},
+			{ErrRateLimited, true},
+			{ErrQueueFull, true},
+			{context.DeadlineExceeded, true},
+			{ErrNotConnected, false},
+			{ErrInvalidResponse, false},
+			{nil, false},
+		}
+		for _, tt := range tests {
+			if got := IsTemporary(tt.err); got != tt.want {
+				t.Errorf("IsTemporary(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		}
+	})
+}
+
+func TestApi_Errors(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	wg := scriptedRelay(t, server,
+		[]byte{byte(CommandState), byte(StateFailure), byte(ErrorAddressTaken)},
+		[]byte{byte(CommandState), byte(StateFailure), byte(ErrorBadKey)},
+		[]byte{byte(CommandState), byte(StateAssigned)},
+		[]byte{byte(CommandState), byte(StateFailure), byte(ErrorNoRoute)},
+	)
+
+	var api Api
+	attach(&api, client, SessionConnected)
+	addr := GertAddress{Upper: 1, Lower: 1}
+	_, err := api.Register(addr, "aaaaaaaaaaaaaaaaaaaa")
+	if !errors.Is(err, ErrAddressTaken) || !IsTemporary(err) {
+		t.Errorf("got %+v, want temporary %+v", err, ErrAddressTaken)
+	}
+	_, err = api.Register(addr, "aaaaaaaaaaaaaaaaaaaa")
+	if !errors.Is(err, ErrBadKey) || IsTemporary(err) {
+		t.Errorf("got %+v, want permanent %+v", err, ErrBadKey)
+	}
+
+	api.mu.Lock()
+	api.setState(SessionAssigned, nil)
+	api.mu.Unlock()
+	_, err = api.Transmit(Packet{})
+	if !errors.Is(err, ErrInvalidResponse) {
+		t.Errorf("got %+v, want %+v", err, ErrInvalidResponse)
+	}
+	_, err = api.Transmit(Packet{})
+	if !errors.Is(err, ErrNoRoute) || !IsTemporary(err) {
+		t.Errorf("got %+v, want temporary %+v", err, ErrNoRoute)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,138 @@
+// Package fragment provides an optional fragmentation layer for GERTe packets.
+// Messages larger than the 255 bytes a single Packet can carry are split into numbered fragments,
+// transmitted as ordinary packets and reassembled by the receiver, so the relay never sees a different wire format.
+package fragment
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/OmegaRogue/gerte-go"
+)
+
+const (
+	// HeaderSize is the size of the header in front of the data of every fragment.
+	// It holds the 2 byte message ID, the index of the fragment and the number of fragments in the message.
+	HeaderSize = 4
+	// MaxFragmentData is the amount of message data carried by a single fragment
+	MaxFragmentData = 255 - HeaderSize
+	// MaxMessageSize is the largest message that can be fragmented
+	MaxMessageSize = 255 * MaxFragmentData
+)
+
+// Header is the header in front of the data of every fragment
+type Header struct {
+	ID    uint16
+	Index byte
+	Count byte
+}
+
+// ToBytes converts a Header to bytes for sending
+func (h Header) ToBytes() []byte {
+	b := make([]byte, HeaderSize)
+	binary.BigEndian.PutUint16(b, h.ID)
+	b[2] = h.Index
+	b[3] = h.Count
+	return b
+}
+
+// HeaderFromBytes parses the Header at the start of the data of a fragment.
+// It returns the Header, the remaining fragment data and any encountered errors.
+func HeaderFromBytes(data []byte) (Header, []byte, error) {
+	if len(data) < HeaderSize {
+		return Header{}, nil, fmt.Errorf("fragment too short: %v<%v", len(data), HeaderSize)
+	}
+	h := Header{
+		ID:    binary.BigEndian.Uint16(data),
+		Index: data[2],
+		Count: data[3],
+	}
+	if h.Count == 0 || h.Index >= h.Count {
+		return Header{}, nil, fmt.Errorf("invalid fragment %v of %v", h.Index, h.Count)
+	}
+	return h, data[HeaderSize:], nil
+}
+
+// Split splits the data of pkt into fragments carrying the message ID id.
+// It returns the fragments in order and any encountered errors.
+// Every message is sent with a header, even if it fits into a single fragment.
+func Split(pkt gerte.Packet, id uint16) ([]gerte.Packet, error) {
+	if len(pkt.Data) > MaxMessageSize {
+		return nil, fmt.Errorf("message cannot exceed %v bytes", MaxMessageSize)
+	}
+	count := (len(pkt.Data) + MaxFragmentData - 1) / MaxFragmentData
+	if count == 0 {
+		count = 1
+	}
+	fragments := make([]gerte.Packet, 0, count)
+	for i := 0; i < count; i++ {
+		start := i * MaxFragmentData
+		end := start + MaxFragmentData
+		if end > len(pkt.Data) {
+			end = len(pkt.Data)
+		}
+		header := Header{
+			ID:    id,
+			Index: byte(i),
+			Count: byte(count),
+		}
+		fragments = append(fragments, gerte.Packet{
+			Source: pkt.Source,
+			Target: pkt.Target,
+			Data:   append(header.ToBytes(), pkt.Data[start:end]...),
+		})
+	}
+	return fragments, nil
+}
+
+// Sender transmits messages of up to MaxMessageSize bytes as fragments over an Api
+type Sender struct {
+	api *gerte.Api
+
+	mu     sync.Mutex
+	nextID uint16
+}
+
+// NewSender is the constructor for Sender, it assigns the Api used for transmitting
+func NewSender(api *gerte.Api) *Sender {
+	return &Sender{api: api, nextID: randomID()}
+}
+
+// Transmit splits pkt into fragments and transmits them in order.
+// It returns a bool whether all fragments were sent and any encountered errors.
+// If a fragment fails, the remaining ones are not sent and the receiver eventually drops the incomplete message.
+func (s *Sender) Transmit(ctx context.Context, pkt gerte.Packet) (bool, error) {
+	s.mu.Lock()
+	id := s.nextID
+	s.nextID++
+	s.mu.Unlock()
+
+	fragments, err := Split(pkt, id)
+	if err != nil {
+		return false, err
+	}
+	for i, frag := range fragments {
+		ok, err := s.api.TransmitContext(ctx, frag)
+		if err != nil {
+			return false, fmt.Errorf("error on transmit fragment %v of %v: %w", i, len(fragments), err)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// randomID returns a random first message ID,
+// so the fragments of a restarted Sender aren't mixed up with a message the receiver is still reassembling
+func randomID() uint16 {
+	var b [2]byte
+	if _, err := crand.Read(b[:]); err != nil {
+		return uint16(time.Now().UnixNano())
+	}
+	return binary.BigEndian.Uint16(b[:])
+}
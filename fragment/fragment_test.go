@@ -0,0 +1,105 @@
+package fragment
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+
+	"github.com/OmegaRogue/gerte-go"
+	"github.com/OmegaRogue/gerte-go/geds"
+)
+
+func TestHeaderFromToBytes(t *testing.T) {
+	header := Header{
+		ID:    4242,
+		Index: 3,
+		Count: 7,
+	}
+	header2, data, err := HeaderFromBytes(append(header.ToBytes(), "test"...))
+	if err != nil {
+		t.Fatalf("error on parse header: %+v", err)
+	}
+	if header != header2 || string(data) != "test" {
+		t.Errorf("headers don't match:\n%+v\n%+v", header, header2)
+	}
+	for _, data := range [][]byte{{0, 1, 2}, {0, 1, 0, 0}, {0, 1, 3, 3}} {
+		if _, _, err := HeaderFromBytes(data); err == nil {
+			t.Errorf("invalid header %v was parsed", data)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 60)
+	fragments, err := Split(gerte.Packet{Data: data}, 1)
+	if err != nil {
+		t.Fatalf("error on split: %+v", err)
+	}
+	if len(fragments) != 3 {
+		t.Fatalf("expected 3 fragments, got %v", len(fragments))
+	}
+	for i, frag := range fragments {
+		if _, err := frag.ToBytes(); err != nil {
+			t.Errorf("fragment %v can't be sent: %+v", i, err)
+		}
+	}
+
+	fragments, err = Split(gerte.Packet{}, 2)
+	if err != nil || len(fragments) != 1 {
+		t.Errorf("empty message wasn't sent as a single fragment: %v %+v", len(fragments), err)
+	}
+	if _, err := Split(gerte.Packet{Data: make([]byte, MaxMessageSize+1)}, 3); err == nil {
+		t.Error("oversized message was split")
+	}
+}
+
+func TestSender_Transmit(t *testing.T) {
+	addrA := gerte.GertAddress{Upper: 1123, Lower: 1456}
+	addrB := gerte.GertAddress{Upper: 2345, Lower: 1456}
+	key := "aaaaaaaaaaaaaaaaaaaa"
+	ver := gerte.Version{Major: 1, Minor: 1}
+	srv := geds.NewServer(ver, geds.Resolutions{addrA: key, addrB: key})
+	defer srv.Close()
+
+	connect := func(addr gerte.GertAddress) *gerte.Api {
+		server, client := net.Pipe()
+		go srv.ServeConn(server)
+		api := gerte.NewApi(ver)
+		if err := api.Startup(client); err != nil {
+			t.Fatalf("error on startup: %+v", err)
+		}
+		if _, err := api.Register(addr, key); err != nil {
+			t.Fatalf("error on register: %+v", err)
+		}
+		return api
+	}
+	sender := connect(addrA)
+	receiver := connect(addrB)
+
+	received := make(chan gerte.Packet, 1)
+	if err := receiver.Receive(NewReassembler(0, 0).Handler(func(pkt gerte.Packet) {
+		received <- pkt
+	})); err != nil {
+		t.Fatalf("error on receive: %+v", err)
+	}
+
+	data := bytes.Repeat([]byte("0123456789"), 200)
+	ok, err := NewSender(sender).Transmit(context.Background(), gerte.Packet{
+		Target: gerte.GERTc{GERTe: addrB},
+		Data:   data,
+	})
+	if !ok || err != nil {
+		t.Fatalf("error on transmit: %+v", err)
+	}
+	pkt := <-received
+	if !bytes.Equal(pkt.Data, data) {
+		t.Errorf("reassembled message doesn't match: %v bytes", len(pkt.Data))
+	}
+	if pkt.Source.GERTe != addrA {
+		t.Errorf("unexpected source: %v", pkt.Source)
+	}
+	if a, b := NewSender(sender), NewSender(sender); a.nextID == b.nextID && a.nextID == NewSender(sender).nextID {
+		t.Error("new senders start with the same message ID")
+	}
+}
@@ -0,0 +1,206 @@
+package fragment
+
+import (
+	"sync"
+	"time"
+
+	"github.com/OmegaRogue/gerte-go"
+)
+
+const (
+	// DefaultTimeout is the time a Reassembler waits for the missing fragments of a message if no timeout is set
+	DefaultTimeout = 10 * time.Second
+	// DefaultMaxBytes is the amount of buffered fragment data a Reassembler holds if no limit is set
+	DefaultMaxBytes = 1 << 20
+)
+
+type (
+	// Reassembler collects fragments and rebuilds the messages they were split from.
+	// Incomplete messages are dropped once they are older than Timeout,
+	// or when the data buffered for all incomplete messages would exceed MaxBytes, starting with the oldest.
+	Reassembler struct {
+		Timeout  time.Duration
+		MaxBytes int
+
+		mu      sync.Mutex
+		partial map[messageKey]*message
+		bytes   int
+		dropped int
+		now     func() time.Time
+	}
+
+	// messageKey identifies a message by its endpoints and ID
+	messageKey struct {
+		source gerte.GERTc
+		target gerte.GERTc
+		id     uint16
+	}
+
+	// message holds the fragments of an incomplete message
+	message struct {
+		started   time.Time
+		fragments [][]byte
+		received  int
+		bytes     int
+	}
+)
+
+// NewReassembler is the constructor for Reassembler, it assigns the Timeout and MaxBytes
+func NewReassembler(timeout time.Duration, maxBytes int) *Reassembler {
+	return &Reassembler{
+		Timeout:  timeout,
+		MaxBytes: maxBytes,
+	}
+}
+
+// Add adds a received fragment.
+// It returns the reassembled Packet and true once the last missing fragment of a message was added,
+// and any encountered errors if pkt is not a valid fragment.
+func (r *Reassembler) Add(pkt gerte.Packet) (gerte.Packet, bool, error) {
+	h, data, err := HeaderFromBytes(pkt.Data)
+	if err != nil {
+		return gerte.Packet{}, false, err
+	}
+	if h.Count == 1 {
+		return gerte.Packet{
+			Source: pkt.Source,
+			Target: pkt.Target,
+			Data:   data,
+		}, true, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.expire()
+
+	key := messageKey{
+		source: pkt.Source,
+		target: pkt.Target,
+		id:     h.ID,
+	}
+	msg, ok := r.partial[key]
+	if ok && len(msg.fragments) != int(h.Count) {
+		// the sender reused the ID for a different message, the old one can't be completed anymore
+		r.drop(key, msg)
+		ok = false
+	}
+	if !ok {
+		msg = &message{
+			started:   r.clock(),
+			fragments: make([][]byte, h.Count),
+		}
+		if r.partial == nil {
+			r.partial = make(map[messageKey]*message)
+		}
+		r.partial[key] = msg
+	}
+	if msg.fragments[h.Index] != nil {
+		return gerte.Packet{}, false, nil
+	}
+	if !r.reserve(len(data), key) {
+		r.drop(key, msg)
+		return gerte.Packet{}, false, nil
+	}
+	msg.fragments[h.Index] = append([]byte{}, data...)
+	msg.received++
+	msg.bytes += len(data)
+	r.bytes += len(data)
+	if msg.received < len(msg.fragments) {
+		return gerte.Packet{}, false, nil
+	}
+
+	delete(r.partial, key)
+	r.bytes -= msg.bytes
+	full := make([]byte, 0, msg.bytes)
+	for _, frag := range msg.fragments {
+		full = append(full, frag...)
+	}
+	return gerte.Packet{
+		Source: pkt.Source,
+		Target: pkt.Target,
+		Data:   full,
+	}, true, nil
+}
+
+// Handler wraps next so it receives reassembled messages instead of fragments.
+// The returned function can be passed to Api.Receive, invalid fragments are dropped.
+func (r *Reassembler) Handler(next func(gerte.Packet)) func(gerte.Packet) {
+	return func(pkt gerte.Packet) {
+		msg, ok, err := r.Add(pkt)
+		if err != nil || !ok {
+			return
+		}
+		next(msg)
+	}
+}
+
+// Expire drops all incomplete messages older than Timeout.
+// Add expires messages itself, Expire only needs to be called to release memory while no fragments arrive.
+func (r *Reassembler) Expire() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.expire()
+}
+
+// Pending returns the number of incomplete messages
+func (r *Reassembler) Pending() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.partial)
+}
+
+// Dropped returns the number of incomplete messages dropped because of the timeout or memory limit
+func (r *Reassembler) Dropped() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.dropped
+}
+
+func (r *Reassembler) expire() {
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	now := r.clock()
+	for key, msg := range r.partial {
+		if now.Sub(msg.started) > timeout {
+			r.drop(key, msg)
+		}
+	}
+}
+
+// reserve makes room for n more bytes by dropping the oldest incomplete messages other than keep.
+// It returns false if the limit can't be met.
+func (r *Reassembler) reserve(n int, keep messageKey) bool {
+	limit := r.MaxBytes
+	if limit <= 0 {
+		limit = DefaultMaxBytes
+	}
+	for r.bytes+n > limit {
+		var oldestKey messageKey
+		var oldest *message
+		for key, msg := range r.partial {
+			if key != keep && (oldest == nil || msg.started.Before(oldest.started)) {
+				oldestKey, oldest = key, msg
+			}
+		}
+		if oldest == nil {
+			return false
+		}
+		r.drop(oldestKey, oldest)
+	}
+	return true
+}
+
+func (r *Reassembler) drop(key messageKey, msg *message) {
+	delete(r.partial, key)
+	r.bytes -= msg.bytes
+	r.dropped++
+}
+
+func (r *Reassembler) clock() time.Time {
+	if r.now != nil {
+		return r.now()
+	}
+	return time.Now()
+}
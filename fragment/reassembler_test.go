@@ -0,0 +1,86 @@
+package fragment
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/OmegaRogue/gerte-go"
+)
+
+func TestReassembler_Add(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	fragments, err := Split(gerte.Packet{Data: data}, 7)
+	if err != nil {
+		t.Fatalf("error on split: %+v", err)
+	}
+	r := NewReassembler(time.Minute, 0)
+	// deliver out of order and with a duplicate
+	order := []int{3, 1, 1, 0, 2}
+	for i, idx := range order {
+		pkt, ok, err := r.Add(fragments[idx])
+		if err != nil {
+			t.Fatalf("error on add fragment %v: %+v", idx, err)
+		}
+		if ok != (i == len(order)-1) {
+			t.Fatalf("unexpected completion after fragment %v: %v", idx, ok)
+		}
+		if ok && !bytes.Equal(pkt.Data, data) {
+			t.Errorf("reassembled message doesn't match")
+		}
+	}
+	if r.Pending() != 0 {
+		t.Errorf("message still pending after completion")
+	}
+	if _, _, err := r.Add(gerte.Packet{Data: []byte{1}}); err == nil {
+		t.Error("invalid fragment was accepted")
+	}
+}
+
+func TestReassembler_Timeout(t *testing.T) {
+	now := time.Now()
+	r := NewReassembler(time.Second, 0)
+	r.now = func() time.Time {
+		return now
+	}
+	fragments, _ := Split(gerte.Packet{Data: make([]byte, 600)}, 1)
+	if _, ok, _ := r.Add(fragments[0]); ok {
+		t.Fatal("message completed early")
+	}
+	now = now.Add(2 * time.Second)
+	r.Expire()
+	if r.Pending() != 0 || r.Dropped() != 1 {
+		t.Errorf("expired message wasn't dropped: %v pending, %v dropped", r.Pending(), r.Dropped())
+	}
+	// the rest of the expired message must not complete it
+	for _, frag := range fragments[1:] {
+		if _, ok, _ := r.Add(frag); ok {
+			t.Error("expired message was completed")
+		}
+	}
+}
+
+func TestReassembler_MaxBytes(t *testing.T) {
+	r := NewReassembler(time.Minute, 2*MaxFragmentData)
+	first, _ := Split(gerte.Packet{Data: make([]byte, 3*MaxFragmentData)}, 1)
+	second, _ := Split(gerte.Packet{Data: make([]byte, 2*MaxFragmentData)}, 2)
+
+	r.Add(first[0])
+	r.Add(first[1])
+	// the oldest incomplete message makes room for the new one
+	r.Add(second[0])
+	if r.Dropped() != 1 {
+		t.Errorf("oldest message wasn't dropped: %v dropped", r.Dropped())
+	}
+	if _, ok, _ := r.Add(second[1]); !ok {
+		t.Error("message within the limit wasn't completed")
+	}
+
+	// a message that can never fit is dropped
+	r.Add(first[0])
+	r.Add(first[1])
+	r.Add(first[2])
+	if r.Pending() != 0 || r.Dropped() != 2 {
+		t.Errorf("oversized message wasn't dropped: %v pending, %v dropped", r.Pending(), r.Dropped())
+	}
+}
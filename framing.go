@@ -0,0 +1,197 @@
+package gerte
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// FrameCodec reads and encodes the frames of a protocol version.
+// Every negotiated version uses the FrameCodec registered for it with RegisterCodec,
+// so frame layouts of different versions can coexist.
+// The version negotiation and the STATE replies to it are the same in every version.
+type FrameCodec interface {
+	// ReadRelayFrame reads the raw bytes of exactly one frame sent by a relay from r, including the command byte.
+	// It returns io.EOF if the stream ended cleanly between two frames and io.ErrUnexpectedEOF if it ended inside a frame.
+	ReadRelayFrame(r *bufio.Reader) ([]byte, error)
+	// ReadGatewayFrame reads the raw bytes of exactly one frame sent by a gateway from r like ReadRelayFrame
+	ReadGatewayFrame(r *bufio.Reader) ([]byte, error)
+	// Marshal encodes m into a frame
+	Marshal(m Message) ([]byte, error)
+	// Unmarshal decodes a complete frame into m
+	Unmarshal(frame []byte, m Message) error
+	// MaxData returns the largest payload of a DATA frame
+	MaxData() int
+}
+
+// frameCodec is the FrameCodec of the GERTe versions, they only differ in the size of the length of DATA frames.
+// The frames of a one byte length are the ones the Message types encode themselves.
+type frameCodec struct {
+	lengthSize int
+}
+
+// ExtendedVersion is the first version whose DATA frames carry a two byte big endian length,
+// so a Packet can hold up to MaxExtendedData bytes instead of MaxData.
+// Gateways opt in by requesting it or allowing it in their VersionPolicy,
+// relays without it reject the version and Negotiate falls back to DefaultVersion.
+var ExtendedVersion = Version{Major: 1, Minor: 2}
+
+// DefaultVersion is the newest version without the extensions of ExtendedVersion
+var DefaultVersion = Version{Major: 1, Minor: 1}
+
+var (
+	// DefaultCodec is the FrameCodec used before a version was negotiated, the one of 1.0 and 1.1
+	DefaultCodec FrameCodec = frameCodec{lengthSize: 1}
+	// ExtendedCodec is the FrameCodec of ExtendedVersion
+	ExtendedCodec FrameCodec = frameCodec{lengthSize: 2}
+)
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[Version]FrameCodec{
+		{Major: 1, Minor: 0}: DefaultCodec,
+		{Major: 1, Minor: 1}: DefaultCodec,
+		ExtendedVersion:      ExtendedCodec,
+	}
+)
+
+// RegisterCodec makes codec the FrameCodec of the protocol version ver, replacing the previous one.
+// The Patch of ver is ignored because it is never sent.
+// Registering a nil codec removes the version.
+func RegisterCodec(ver Version, codec FrameCodec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	if codec == nil {
+		delete(codecs, ver.wire())
+		return
+	}
+	codecs[ver.wire()] = codec
+}
+
+// CodecFor looks up the FrameCodec of the protocol version ver.
+// It returns the FrameCodec and an error wrapping ErrVersion if none is registered.
+func CodecFor(ver Version) (FrameCodec, error) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	codec, ok := codecs[ver.wire()]
+	if !ok {
+		return nil, fmt.Errorf("%w: no codec for %v", ErrVersion, ver.wire())
+	}
+	return codec, nil
+}
+
+// SupportedVersions returns the versions with a registered FrameCodec, newest first
+func SupportedVersions() []Version {
+	codecsMu.RLock()
+	versions := make([]Version, 0, len(codecs))
+	for ver := range codecs {
+		versions = append(versions, ver)
+	}
+	codecsMu.RUnlock()
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[j].Less(versions[i])
+	})
+	return versions
+}
+
+func (codec frameCodec) ReadRelayFrame(r *bufio.Reader) ([]byte, error) {
+	cmd, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	frame := []byte{cmd}
+	switch GertCommand(cmd) {
+	case CommandState:
+		state, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("error on read state: %w", noEOF(err))
+		}
+		frame = append(frame, state)
+		switch GertStatus(state) {
+		case StateFailure:
+			return readN(r, frame, 1)
+		case StateConnected:
+			return readN(r, frame, 2)
+		case StateAssigned, StateClosed, StateSent:
+			return frame, nil
+		}
+		return nil, fmt.Errorf("state didn't match any known state: %v", state)
+	case CommandRegister:
+		return readN(r, frame, 3+KeySize)
+	case CommandData:
+		return codec.readData(r, frame, 12)
+	case CommandClose:
+		return frame, nil
+	}
+	return nil, fmt.Errorf("error while parsing command data: invalid command %v", cmd)
+}
+
+func (codec frameCodec) ReadGatewayFrame(r *bufio.Reader) ([]byte, error) {
+	cmd, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	frame := []byte{cmd}
+	switch GertCommand(cmd) {
+	case CommandState, CommandClose:
+		return frame, nil
+	case CommandRegister:
+		return readN(r, frame, 3+KeySize)
+	case CommandData:
+		return codec.readData(r, frame, 9)
+	}
+	return nil, fmt.Errorf("error while parsing command data: invalid command %v", cmd)
+}
+
+func (codec frameCodec) Marshal(m Message) ([]byte, error) {
+	switch m := m.(type) {
+	case *OutboundData:
+		return m.marshal(codec.lengthSize)
+	case *InboundData:
+		return m.marshal(codec.lengthSize)
+	}
+	return m.MarshalBinary()
+}
+
+func (codec frameCodec) Unmarshal(frame []byte, m Message) error {
+	switch m := m.(type) {
+	case *OutboundData:
+		return m.unmarshal(frame, codec.lengthSize)
+	case *InboundData:
+		return m.unmarshal(frame, codec.lengthSize)
+	}
+	return m.UnmarshalBinary(frame)
+}
+
+func (codec frameCodec) MaxData() int {
+	return maxData(codec.lengthSize)
+}
+
+// readData reads the rest of a DATA frame whose addresses take headerSize bytes after the command byte
+func (codec frameCodec) readData(r *bufio.Reader, frame []byte, headerSize int) ([]byte, error) {
+	frame, err := readN(r, frame, headerSize+codec.lengthSize)
+	if err != nil {
+		return nil, err
+	}
+	return readN(r, frame, dataLength(frame[1+headerSize:]))
+}
+
+// readN appends the next n bytes of r to frame
+func readN(r io.Reader, frame []byte, n int) ([]byte, error) {
+	start := len(frame)
+	frame = append(frame, make([]byte, n)...)
+	if m, err := io.ReadFull(r, frame[start:]); err != nil {
+		return nil, fmt.Errorf("error on read frame (%v of %v bytes): %w", m, n, noEOF(err))
+	}
+	return frame, nil
+}
+
+// noEOF converts io.EOF into io.ErrUnexpectedEOF for reads in the middle of a frame
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,238 @@
+package gerte
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+// fuzzSeeds are well-formed frames of every command and some truncated ones
+var fuzzSeeds = [][]byte{
+	{byte(CommandState), byte(StateFailure), byte(ErrorNoRoute)},
+	{byte(CommandState), byte(StateConnected), 1, 1},
+	{byte(CommandState), byte(StateAssigned)},
+	{byte(CommandState), byte(StateClosed)},
+	{byte(CommandState), byte(StateSent)},
+	append([]byte{byte(CommandRegister), 0x46, 0x35, 0xB0}, "aaaaaaaaaaaaaaaaaaaa"...),
+	append([]byte{byte(CommandData), 0x92, 0x95, 0xB0, 0x00, 0x10, 0x01, 0x46, 0x35, 0xB0, 0x00, 0x20, 0x02, 5}, "hello"...),
+	{byte(CommandClose)},
+	{},
+	{byte(CommandState)},
+	{byte(CommandState), byte(StateFailure)},
+	{byte(CommandState), byte(StateConnected), 1},
+	{byte(CommandData), 0x92, 0x95, 0xB0, 0x00, 0x10, 0x01, 0x46, 0x35, 0xB0, 0x00, 0x20, 0x02, 5, 'h'},
+}
+
+// fuzzGatewaySeeds are well-formed frames sent by gateways and the frames of ExtendedCodec
+var fuzzGatewaySeeds = [][]byte{
+	{byte(CommandState)},
+	append([]byte{byte(CommandData), 0x46, 0x35, 0xB0, 0x00, 0x20, 0x02, 0x00, 0x10, 0x01, 5}, "hello"...),
+	append([]byte{byte(CommandData), 0x46, 0x35, 0xB0, 0x00, 0x20, 0x02, 0x00, 0x10, 0x01, 0, 5}, "hello"...),
+	append([]byte{byte(CommandData), 0x92, 0x95, 0xB0, 0x00, 0x10, 0x01, 0x46, 0x35, 0xB0, 0x00, 0x20, 0x02, 0, 5}, "hello"...),
+	{byte(CommandData), 0x46, 0x35, 0xB0, 0x00, 0x20, 0x02, 0x00, 0x10, 0x01, 0xFF, 0xFF, 'h'},
+}
+
+// fuzzCodecs are the FrameCodecs of every registered version
+var fuzzCodecs = map[string]FrameCodec{
+	"Default":  DefaultCodec,
+	"Extended": ExtendedCodec,
+}
+
+func FuzzCommandFromBytes(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		cmd, err := CommandFromBytes(data)
+		if err != nil {
+			return
+		}
+		if cmd.Command == CommandData && len(cmd.Packet.Data) != int(data[13]) {
+			t.Errorf("got %v bytes of data, declared %v", len(cmd.Packet.Data), data[13])
+		}
+	})
+}
+
+func FuzzDecoder(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+	f.Add(bytes.Join(fuzzSeeds[:8], nil))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		dec := NewDecoder(bytes.NewReader(data))
+		for {
+			frame, err := dec.ReadFrame()
+			if err != nil {
+				return
+			}
+			if len(frame) > len(data) {
+				t.Fatalf("frame of %v bytes is longer than the input of %v bytes", len(frame), len(data))
+			}
+			// the decoder only returns frames of the length their command declares, so they must parse
+			if _, err := CommandFromBytes(frame); err != nil {
+				t.Errorf("frame %v read by the decoder doesn't parse: %+v", frame, err)
+			}
+		}
+	})
+}
+
+func FuzzMessage(f *testing.F) {
+	for _, seed := range append(fuzzSeeds, fuzzGatewaySeeds...) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		messages := []Message{new(StateRequest), new(Register), new(OutboundData), new(InboundData), new(StateReply), new(Close)}
+		for name, codec := range fuzzCodecs {
+			for _, m := range messages {
+				if err := codec.Unmarshal(data, m); err != nil {
+					continue
+				}
+				// every frame that unmarshals is the only encoding of its message
+				frame, err := codec.Marshal(m)
+				if err != nil {
+					t.Errorf("%v: %T unmarshaled from %v doesn't marshal: %+v", name, m, data, err)
+				} else if !bytes.Equal(frame, data) {
+					t.Errorf("%v: %T doesn't round trip: %v != %v", name, m, frame, data)
+				}
+			}
+		}
+	})
+}
+
+func FuzzCodec(f *testing.F) {
+	for _, seed := range append(fuzzSeeds, fuzzGatewaySeeds...) {
+		f.Add(seed)
+	}
+	f.Add(bytes.Join(fuzzSeeds[:8], nil))
+	f.Add(bytes.Join(fuzzGatewaySeeds[:3], nil))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for name, codec := range fuzzCodecs {
+			client := NewClientCodec(readWriter{bytes.NewReader(data), ioutil.Discard})
+			client.SetCodec(codec)
+			server := NewServerCodec(readWriter{bytes.NewReader(data), ioutil.Discard})
+			server.SetCodec(codec)
+			for _, read := range []func() (Message, error){client.ReadMessage, server.ReadMessage} {
+				n := 0
+				for {
+					m, err := read()
+					if err != nil {
+						break
+					}
+					frame, err := codec.Marshal(m)
+					if err != nil {
+						t.Fatalf("%v: %T read from %v doesn't marshal: %+v", name, m, data, err)
+					}
+					if n += len(frame); n > len(data) {
+						t.Fatalf("%v: read %v bytes of frames from %v bytes of input", name, n, len(data))
+					}
+				}
+			}
+		}
+	})
+}
+
+func FuzzPrettyPrint(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, _ = PrettyPrint(data)
+	})
+}
+
+func FuzzAddressFromBytes(f *testing.F) {
+	f.Add([]byte{0x46, 0x35, 0xB0})
+	f.Add([]byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF})
+	f.Add([]byte{0x00})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		addr, err := AddressFromBytes(data)
+		if err != nil {
+			if len(data) >= 3 {
+				t.Errorf("error on %v bytes: %+v", len(data), err)
+			}
+			return
+		}
+		if !bytes.Equal(addr.ToBytes(), data[:3]) {
+			t.Errorf("address %v doesn't round trip: %v != %v", addr, addr.ToBytes(), data[:3])
+		}
+		addrC, err := GertCFromBytes(data)
+		if len(data) >= 6 && (err != nil || addrC.GERTe != addr || !bytes.Equal(addrC.ToBytes(), data[:6])) {
+			t.Errorf("GERTc %v doesn't round trip: %+v", addrC, err)
+		}
+	})
+}
+
+func TestFromBytes_Short(t *testing.T) {
+	decoders := map[string]func([]byte) error{
+		"CommandFromBytes": func(b []byte) error {
+			_, err := CommandFromBytes(b)
+			return err
+		},
+		"StatusFromBytes": func(b []byte) error {
+			_, err := StatusFromBytes(b)
+			return err
+		},
+		"PacketFromBytes": func(b []byte) error {
+			_, err := PacketFromBytes(b)
+			return err
+		},
+		"AddressFromBytes": func(b []byte) error {
+			_, err := AddressFromBytes(b)
+			return err
+		},
+		"GertCFromBytes": func(b []byte) error {
+			_, err := GertCFromBytes(b)
+			return err
+		},
+		"VersionFromBytes": func(b []byte) error {
+			_, err := VersionFromBytes(b)
+			return err
+		},
+		"PrettyPrint": func(b []byte) error {
+			_, err := PrettyPrint(b)
+			return err
+		},
+	}
+	for name, decode := range decoders {
+		if err := decode(nil); !errors.Is(err, ErrTooShort) {
+			t.Errorf("%v(nil): got %+v, want %+v", name, err, ErrTooShort)
+		}
+	}
+	for _, seed := range fuzzSeeds[9:] {
+		if _, err := CommandFromBytes(seed); !errors.Is(err, ErrTooShort) {
+			t.Errorf("CommandFromBytes(%v): got %+v, want %+v", seed, err, ErrTooShort)
+		}
+		// PrettyPrint reads outbound DATA commands, so the truncated inbound frame is only short for the decoders
+		if _, err := PrettyPrint(seed); seed[0] != byte(CommandData) && !errors.Is(err, ErrTooShort) {
+			t.Errorf("PrettyPrint(%v): got %+v, want %+v", seed, err, ErrTooShort)
+		}
+		if _, err := NewDecoder(bytes.NewReader(seed)).Decode(); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("Decode(%v): got %+v, want %+v", seed, err, io.ErrUnexpectedEOF)
+		}
+	}
+
+	pkt, err := PacketFromBytes(append(make([]byte, 12), 2, 'a', 'b', 'c'))
+	if err != nil || string(pkt.Data) != "ab" {
+		t.Errorf("got %q %+v, want the declared 2 bytes", pkt.Data, err)
+	}
+}
+
+func FuzzAddressFromString(f *testing.F) {
+	for _, seed := range []string{"1123.1456", "0000.0000", "4095.4095", "4096.1", "1123", "", ".", "1.2.3", "-1.1", "1123.1456:0001.0001"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		addr, err := AddressFromString(s)
+		if err != nil {
+			return
+		}
+		if err := addr.Validate(); err != nil {
+			t.Errorf("parsed invalid address %v: %+v", addr, err)
+		}
+		if again, err := AddressFromString(addr.String()); err != nil || again != addr {
+			t.Errorf("address %v doesn't round trip: %v %+v", addr, again, err)
+		}
+	})
+}
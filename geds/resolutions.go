@@ -0,0 +1,72 @@
+package geds
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/OmegaRogue/gerte-go"
+)
+
+// KeySize is the length of a registration key in bytes
+const KeySize = gerte.KeySize
+
+// resolutionSize is the length of a single entry in a resolutions file
+const resolutionSize = 3 + KeySize
+
+// Resolutions maps every GERTe address the relay accepts to its registration key
+type Resolutions map[gerte.GertAddress]string
+
+// ReadResolutions parses resolutions in the GEDS file format.
+// It returns the parsed Resolutions and any encountered errors.
+// The format is a sequence of 3 byte addresses, each followed by its 20 byte key, as written by tools/create_resolutions.
+func ReadResolutions(r io.Reader) (Resolutions, error) {
+	res := make(Resolutions)
+	br := bufio.NewReader(r)
+	entry := make([]byte, resolutionSize)
+	for {
+		n, err := io.ReadFull(br, entry)
+		if err == io.EOF {
+			return res, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error on read resolution (%v bytes): %w", n, err)
+		}
+		addr, err := gerte.AddressFromBytes(entry[:3])
+		if err != nil {
+			return nil, fmt.Errorf("error on parse resolution address: %w", err)
+		}
+		res[addr] = string(entry[3:])
+	}
+}
+
+// LoadResolutions reads the resolutions file at path.
+// It returns the parsed Resolutions and any encountered errors.
+func LoadResolutions(path string) (Resolutions, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error on open resolutions: %w", err)
+	}
+	defer f.Close()
+	return ReadResolutions(f)
+}
+
+// WriteTo writes the Resolutions in the GEDS file format.
+// It returns the number of bytes written and any encountered errors.
+func (res Resolutions) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	for addr, key := range res {
+		if len(key) != KeySize {
+			return 0, fmt.Errorf("key for %v must be %v bytes, got %v", addr, KeySize, len(key))
+		}
+		if err := addr.Validate(); err != nil {
+			return 0, fmt.Errorf("error on write resolution: %w", err)
+		}
+		b.Write(addr.ToBytes())
+		b.WriteString(key)
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
@@ -0,0 +1,50 @@
+package geds
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/OmegaRogue/gerte-go"
+)
+
+func TestReadWriteResolutions(t *testing.T) {
+	res := Resolutions{
+		gerte.GertAddress{Upper: 1123, Lower: 1456}: "aaaaaaaaaaaaaaaaaaaa",
+		gerte.GertAddress{Upper: 2345, Lower: 1456}: "bbbbbbbbbbbbbbbbbbbb",
+	}
+	var b bytes.Buffer
+	if _, err := res.WriteTo(&b); err != nil {
+		t.Fatalf("error on write resolutions: %+v", err)
+	}
+	if b.Len() != 2*resolutionSize {
+		t.Errorf("unexpected resolutions size: %v", b.Len())
+	}
+	res2, err := ReadResolutions(&b)
+	if err != nil {
+		t.Fatalf("error on read resolutions: %+v", err)
+	}
+	if len(res2) != len(res) {
+		t.Fatalf("resolutions don't match:\n%+v\n%+v", res, res2)
+	}
+	for addr, key := range res {
+		if res2[addr] != key {
+			t.Errorf("keys for %v don't match: %v != %v", addr, key, res2[addr])
+		}
+	}
+}
+
+func TestReadResolutionsTruncated(t *testing.T) {
+	data := append(gerte.GertAddress{Upper: 1, Lower: 1}.ToBytes(), "short"...)
+	_, err := ReadResolutions(bytes.NewReader(data))
+	if err == nil {
+		t.Error("truncated resolutions were accepted")
+	}
+}
+
+func TestWriteResolutionsBadKey(t *testing.T) {
+	res := Resolutions{gerte.GertAddress{Upper: 1, Lower: 1}: "short"}
+	var b bytes.Buffer
+	if _, err := res.WriteTo(&b); err == nil {
+		t.Error("key with invalid length was accepted")
+	}
+}
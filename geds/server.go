@@ -0,0 +1,416 @@
+// Package geds provides an in-process GEDS relay.
+// It implements the relay side of the GERTe protocol, so gateways built with the gerte package can be tested without the C++ GEDS.
+// More info: https://github.com/GlobalEmpire/GERT
+package geds
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/OmegaRogue/gerte-go"
+)
+
+// DefaultAddr is the address ListenAndServe listens on if Server.Addr is empty
+const DefaultAddr = ":43780"
+
+// DefaultWriteTimeout is the time a write to a gateway may take if Server.WriteTimeout is not set
+const DefaultWriteTimeout = 5 * time.Second
+
+// ErrServerClosed is returned by Serve and ListenAndServe after Close was called
+var ErrServerClosed = errors.New("geds: server closed")
+
+// Server is a GEDS relay.
+// It negotiates the protocol version with connecting gateways, validates registrations against its Resolutions
+// and routes data between the registered gateways.
+// Data for addresses without a registered gateway follows the longest matching route added with Route.
+type Server struct {
+	// Addr is the TCP address to listen on, DefaultAddr if empty
+	Addr string
+	// Version is the newest protocol version of the relay.
+	// Gateways can negotiate older versions of the same major version if a codec is registered for them, see gerte.RegisterCodec.
+	Version gerte.Version
+	// Resolutions holds the addresses and keys gateways can register with
+	Resolutions Resolutions
+	// ErrorLog receives errors from connection handlers, the standard logger is used if nil
+	ErrorLog *log.Logger
+	// WriteTimeout is the time a write to a gateway may take, DefaultWriteTimeout if not set.
+	// A gateway that doesn't read its data in time is disconnected and the sender gets a NO_ROUTE error,
+	// so a stuck gateway can't block the gateways sending to it.
+	WriteTimeout time.Duration
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[*gateway]struct{}
+	gateways  map[gerte.GertAddress]*gateway
+	routes    gerte.RoutingTable
+	closed    bool
+	// wg counts the handlers of the connections in conns
+	wg sync.WaitGroup
+}
+
+// gateway is the relay side of a single gateway connection
+type gateway struct {
+	srv     *Server
+	conn    net.Conn
+	codec   *gerte.ServerCodec
+	writeMu sync.Mutex
+	version gerte.Version
+	// maxData is the largest payload the negotiated version can carry
+	maxData int
+	// address and registered are only accessed while holding srv.mu
+	address    gerte.GertAddress
+	registered bool
+}
+
+// NewServer is the constructor for Server, it assigns the Version and Resolutions
+func NewServer(ver gerte.Version, res Resolutions) *Server {
+	return &Server{
+		Version:     ver,
+		Resolutions: res,
+	}
+}
+
+// ListenAndServe listens on the TCP address addr and serves a relay with the given Resolutions.
+// It always returns a non-nil error.
+func ListenAndServe(addr string, res Resolutions) error {
+	srv := NewServer(gerte.ExtendedVersion, res)
+	srv.Addr = addr
+	return srv.ListenAndServe()
+}
+
+// ListenAndServe listens on srv.Addr and serves incoming gateway connections.
+// It always returns a non-nil error, ErrServerClosed after Close.
+func (srv *Server) ListenAndServe() error {
+	addr := srv.Addr
+	if addr == "" {
+		addr = DefaultAddr
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("error on listen: %w", err)
+	}
+	return srv.Serve(l)
+}
+
+// Serve accepts gateway connections on l and handles each of them in a new goroutine.
+// It always returns a non-nil error, ErrServerClosed after Close.
+func (srv *Server) Serve(l net.Listener) error {
+	if !srv.trackListener(l, true) {
+		l.Close()
+		return ErrServerClosed
+	}
+	defer srv.trackListener(l, false)
+
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			if srv.isClosed() {
+				return ErrServerClosed
+			}
+			return fmt.Errorf("error on accept: %w", err)
+		}
+		if srv.isClosed() {
+			c.Close()
+			return ErrServerClosed
+		}
+		go srv.ServeConn(c)
+	}
+}
+
+// ServeConn handles a single gateway connection and blocks until it is closed.
+// The connection is always closed when ServeConn returns, right away if Close was called before.
+func (srv *Server) ServeConn(c net.Conn) {
+	gw := &gateway{
+		srv:     srv,
+		conn:    c,
+		codec:   gerte.NewServerCodec(c),
+		maxData: gerte.MaxData,
+	}
+	if !srv.trackConn(gw, true) {
+		c.Close()
+		return
+	}
+	defer srv.wg.Done()
+	defer srv.trackConn(gw, false)
+	defer c.Close()
+
+	if err := gw.serve(); err != nil && err != io.EOF && !srv.isClosed() {
+		srv.logf("geds: gateway %v: %v", c.RemoteAddr(), err)
+	}
+}
+
+// Close closes all listeners and gateway connections and waits for their handlers to return.
+// It returns the first error encountered while closing a listener.
+func (srv *Server) Close() error {
+	srv.mu.Lock()
+	srv.closed = true
+	var err error
+	for l := range srv.listeners {
+		if cerr := l.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	for gw := range srv.conns {
+		gw.conn.Close()
+	}
+	srv.mu.Unlock()
+	srv.wg.Wait()
+	return err
+}
+
+// Registered returns whether a gateway is currently registered on addr
+func (srv *Server) Registered(addr gerte.GertAddress) bool {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	_, ok := srv.gateways[addr]
+	return ok
+}
+
+func (srv *Server) trackListener(l net.Listener, add bool) bool {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if add {
+		if srv.closed {
+			return false
+		}
+		if srv.listeners == nil {
+			srv.listeners = make(map[net.Listener]struct{})
+		}
+		srv.listeners[l] = struct{}{}
+		return true
+	}
+	delete(srv.listeners, l)
+	return true
+}
+
+func (srv *Server) trackConn(gw *gateway, add bool) bool {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if add {
+		if srv.closed {
+			return false
+		}
+		if srv.conns == nil {
+			srv.conns = make(map[*gateway]struct{})
+		}
+		srv.conns[gw] = struct{}{}
+		// adding under srv.mu while not closed keeps Add from racing with the Wait in Close
+		srv.wg.Add(1)
+		return true
+	}
+	delete(srv.conns, gw)
+	if gw.registered && srv.gateways[gw.address] == gw {
+		delete(srv.gateways, gw.address)
+	}
+	gw.registered = false
+	return true
+}
+
+func (srv *Server) isClosed() bool {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return srv.closed
+}
+
+func (srv *Server) logf(format string, args ...interface{}) {
+	if srv.ErrorLog != nil {
+		srv.ErrorLog.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+// Route forwards data for the GERTe addresses in prefix to the gateway registered on via,
+// unless a gateway is registered on the target address itself.
+// It replaces an existing route for the same prefix.
+func (srv *Server) Route(prefix gerte.AddressPrefix, via gerte.GertAddress) {
+	srv.routes.Insert(prefix.GERTc(), via)
+}
+
+// RemoveRoute removes the route added for prefix.
+// It returns whether there was one.
+func (srv *Server) RemoveRoute(prefix gerte.AddressPrefix) bool {
+	return srv.routes.Delete(prefix.GERTc())
+}
+
+// register claims addr for gw.
+// It returns the GertError to report to the gateway and whether the registration succeeded.
+func (srv *Server) register(gw *gateway, addr gerte.GertAddress, key string) (gerte.GertError, bool) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if gw.registered {
+		return gerte.ErrorAlreadyRegistered, false
+	}
+	if k, ok := srv.Resolutions[addr]; !ok || k != key {
+		return gerte.ErrorBadKey, false
+	}
+	if _, ok := srv.gateways[addr]; ok {
+		return gerte.ErrorAddressTaken, false
+	}
+	if srv.gateways == nil {
+		srv.gateways = make(map[gerte.GertAddress]*gateway)
+	}
+	srv.gateways[addr] = gw
+	gw.address = addr
+	gw.registered = true
+	return 0, true
+}
+
+// unregister releases the address claimed by gw
+func (srv *Server) unregister(gw *gateway) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if gw.registered && srv.gateways[gw.address] == gw {
+		delete(srv.gateways, gw.address)
+	}
+	gw.registered = false
+}
+
+// route looks up the registered sender and the gateway registered on target, or the one its longest route leads to
+func (srv *Server) route(gw *gateway, target gerte.GertAddress) (source gerte.GertAddress, registered bool, dest *gateway) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	dest = srv.gateways[target]
+	if dest == nil {
+		if _, via, ok := srv.routes.LookupAddress(target); ok {
+			dest = srv.gateways[via.(gerte.GertAddress)]
+		}
+	}
+	return gw.address, gw.registered, dest
+}
+
+// serve runs the protocol for a single gateway
+func (gw *gateway) serve() error {
+	if err := gw.negotiate(); err != nil {
+		return err
+	}
+	for {
+		msg, err := gw.codec.ReadMessage()
+		if err != nil {
+			return err
+		}
+		switch msg := msg.(type) {
+		case *gerte.StateRequest:
+			err = gw.handleState()
+		case *gerte.Register:
+			err = gw.handleRegister(msg)
+		case *gerte.OutboundData:
+			err = gw.handleData(msg)
+		case *gerte.Close:
+			gw.srv.unregister(gw)
+			return gw.writeState(gerte.StateClosed)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// negotiate reads the version of the gateway and answers with CONNECTED or a VERSION error.
+// The frames after CONNECTED use the codec of the negotiated version.
+func (gw *gateway) negotiate() error {
+	version, err := gw.codec.ReadVersion()
+	if err != nil {
+		return fmt.Errorf("error on read version: %w", err)
+	}
+	gw.version = version
+	codec, err := gerte.CodecFor(version)
+	if err == nil && (version.Major != gw.srv.Version.Major || gw.srv.Version.Less(version)) {
+		err = fmt.Errorf("incompatible version: %v", version)
+	}
+	if err != nil {
+		if werr := gw.writeFailure(gerte.ErrorVersion); werr != nil {
+			return werr
+		}
+		return err
+	}
+	if err := gw.writeConnected(); err != nil {
+		return err
+	}
+	gw.codec.SetCodec(codec)
+	gw.maxData = codec.MaxData()
+	return nil
+}
+
+// handleState answers a state request with the current state of the gateway
+func (gw *gateway) handleState() error {
+	gw.srv.mu.Lock()
+	registered := gw.registered
+	gw.srv.mu.Unlock()
+	if registered {
+		return gw.writeState(gerte.StateAssigned)
+	}
+	return gw.writeConnected()
+}
+
+// handleRegister tries to claim the address of a REGISTER command
+func (gw *gateway) handleRegister(msg *gerte.Register) error {
+	code, ok := gw.srv.register(gw, msg.Address, msg.Key)
+	if !ok {
+		return gw.writeFailure(code)
+	}
+	return gw.writeState(gerte.StateAssigned)
+}
+
+// handleData forwards a DATA command to the gateway registered on the target address
+func (gw *gateway) handleData(msg *gerte.OutboundData) error {
+	source, registered, dest := gw.srv.route(gw, msg.Target.GERTe)
+	if !registered {
+		return gw.writeFailure(gerte.ErrorNotRegistered)
+	}
+	if dest == nil {
+		return gw.writeFailure(gerte.ErrorNoRoute)
+	}
+	if len(msg.Data) > dest.maxData {
+		// the target negotiated a version with a shorter length, there is no error code for it
+		return gw.writeFailure(gerte.ErrorNoRoute)
+	}
+
+	inbound := &gerte.InboundData{
+		Source: gerte.GERTc{
+			GERTe: source,
+			GERTi: msg.Source,
+		},
+		Target: msg.Target,
+		Data:   msg.Data,
+	}
+	if err := dest.write(inbound); err != nil {
+		gw.srv.logf("geds: error on forward data to %v: %v", msg.Target.GERTe, err)
+		// the frame may be cut off, so the connection of the target can't be used anymore
+		dest.conn.Close()
+		return gw.writeFailure(gerte.ErrorNoRoute)
+	}
+	return gw.writeState(gerte.StateSent)
+}
+
+func (gw *gateway) writeState(state gerte.GertStatus) error {
+	return gw.write(&gerte.StateReply{Status: gerte.Status{Status: state}})
+}
+
+func (gw *gateway) writeFailure(code gerte.GertError) error {
+	return gw.write(&gerte.StateReply{Status: gerte.Status{Status: gerte.StateFailure, Error: code}})
+}
+
+func (gw *gateway) writeConnected() error {
+	return gw.write(&gerte.StateReply{Status: gerte.Status{Status: gerte.StateConnected, Version: gw.version}})
+}
+
+// write sends a complete message to the gateway within the WriteTimeout of the server,
+// messages from different goroutines are never interleaved
+func (gw *gateway) write(msg gerte.Message) error {
+	timeout := gw.srv.WriteTimeout
+	if timeout <= 0 {
+		timeout = DefaultWriteTimeout
+	}
+	gw.writeMu.Lock()
+	defer gw.writeMu.Unlock()
+	if err := gw.conn.SetWriteDeadline(time.Now().Add(timeout)); err != nil {
+		return fmt.Errorf("error on set write deadline: %w", err)
+	}
+	return gw.codec.WriteMessage(msg)
+}
@@ -0,0 +1,267 @@
+package geds
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/OmegaRogue/gerte-go"
+)
+
+var (
+	requesterAddr = gerte.GertAddress{Upper: 1123, Lower: 1456}
+	targetAddr    = gerte.GertAddress{Upper: 2345, Lower: 1456}
+	testKey       = "aaaaaaaaaaaaaaaaaaaa"
+	testVersion   = gerte.Version{Major: 1, Minor: 1}
+)
+
+func startServer(t *testing.T) (*Server, string) {
+	t.Helper()
+	srv := NewServer(testVersion, Resolutions{
+		requesterAddr: testKey,
+		targetAddr:    testKey,
+	})
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error on listen: %+v", err)
+	}
+	go srv.Serve(l)
+	t.Cleanup(func() {
+		if err := srv.Close(); err != nil {
+			t.Errorf("error on close server: %+v", err)
+		}
+	})
+	return srv, l.Addr().String()
+}
+
+func connect(t *testing.T, addr string) *gerte.Api {
+	t.Helper()
+	con, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("error on tcp dial: %+v", err)
+	}
+	api := gerte.NewApi(testVersion)
+	if err := api.Startup(con); err != nil {
+		t.Fatalf("error on startup: %+v", err)
+	}
+	return api
+}
+
+func TestServer_BasicFlow(t *testing.T) {
+	srv, addr := startServer(t)
+
+	target := connect(t, addr)
+	if _, err := target.Register(targetAddr, testKey); err != nil {
+		t.Fatalf("target errored on register: %+v", err)
+	}
+	requester := connect(t, addr)
+	if _, err := requester.Register(requesterAddr, testKey); err != nil {
+		t.Fatalf("requester errored on register: %+v", err)
+	}
+	if !srv.Registered(requesterAddr) || !srv.Registered(targetAddr) {
+		t.Error("server didn't record registrations")
+	}
+
+	pkt := gerte.Packet{
+		Source: gerte.GERTc{GERTi: gerte.GertAddress{Upper: 2, Lower: 2}},
+		Target: gerte.GERTc{GERTe: targetAddr, GERTi: gerte.GertAddress{Upper: 1, Lower: 1}},
+		Data:   []byte("test"),
+	}
+	if _, err := requester.Transmit(pkt); err != nil {
+		t.Fatalf("requester errored on transmit: %+v", err)
+	}
+
+	cmd, err := target.Parse()
+	if err != nil {
+		t.Fatalf("target errored on parse: %+v", err)
+	}
+	if cmd.Command != gerte.CommandData {
+		t.Fatalf("target received %v instead of data", cmd)
+	}
+	source := gerte.GERTc{GERTe: requesterAddr, GERTi: pkt.Source.GERTi}
+	if cmd.Packet.Source != source || cmd.Packet.Target != pkt.Target || string(cmd.Packet.Data) != string(pkt.Data) {
+		t.Errorf("packets don't match:\n%+v\n%+v", pkt, cmd.Packet)
+	}
+
+	if err := requester.Shutdown(); err != nil {
+		t.Errorf("requester errored on shutdown: %+v", err)
+	}
+	if err := target.Shutdown(); err != nil {
+		t.Errorf("target errored on shutdown: %+v", err)
+	}
+}
+
+func TestServer_Version(t *testing.T) {
+	_, addr := startServer(t)
+	con, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("error on tcp dial: %+v", err)
+	}
+	api := gerte.NewApi(gerte.Version{Major: 2, Minor: 0})
+	if err := api.Startup(con); err == nil {
+		t.Error("incompatible version was accepted")
+	}
+}
+
+func TestServer_Register(t *testing.T) {
+	_, addr := startServer(t)
+
+	api := connect(t, addr)
+	if _, err := api.Register(gerte.GertAddress{Upper: 1, Lower: 1}, testKey); err == nil {
+		t.Error("unknown address was accepted")
+	}
+	if _, err := api.Register(targetAddr, "bbbbbbbbbbbbbbbbbbbb"); err == nil {
+		t.Error("bad key was accepted")
+	}
+	if _, err := api.Register(targetAddr, testKey); err != nil {
+		t.Fatalf("client errored on register: %+v", err)
+	}
+	if _, err := api.Register(requesterAddr, testKey); err == nil {
+		t.Error("second registration was accepted")
+	}
+
+	other := connect(t, addr)
+	if _, err := other.Register(targetAddr, testKey); err == nil {
+		t.Error("taken address was accepted")
+	}
+}
+
+func TestServer_Transmit(t *testing.T) {
+	_, addr := startServer(t)
+
+	api := connect(t, addr)
+	pkt := gerte.Packet{
+		Target: gerte.GERTc{GERTe: targetAddr},
+		Data:   []byte("test"),
+	}
+	if _, err := api.Transmit(pkt); err == nil {
+		t.Error("transmit before register was accepted")
+	}
+	if _, err := api.Register(requesterAddr, testKey); err != nil {
+		t.Fatalf("client errored on register: %+v", err)
+	}
+	if _, err := api.Transmit(pkt); err == nil {
+		t.Error("transmit without route was accepted")
+	}
+}
+
+func TestServer_Route(t *testing.T) {
+	srv, addr := startServer(t)
+	requester := connect(t, addr)
+	target := connect(t, addr)
+	if _, err := requester.Register(requesterAddr, testKey); err != nil {
+		t.Fatalf("requester errored on register: %+v", err)
+	}
+	if _, err := target.Register(targetAddr, testKey); err != nil {
+		t.Fatalf("target errored on register: %+v", err)
+	}
+	if err := target.Receive(nil); err != nil {
+		t.Fatalf("target errored on receive: %+v", err)
+	}
+
+	block, err := gerte.ParseAddressPrefix("3456.*")
+	if err != nil {
+		t.Fatalf("error on parse prefix: %+v", err)
+	}
+	srv.Route(block, targetAddr)
+	pkt := gerte.Packet{
+		Target: gerte.GERTc{GERTe: gerte.GertAddress{Upper: 3456, Lower: 1}, GERTi: gerte.GertAddress{Upper: 1, Lower: 1}},
+		Data:   []byte("test"),
+	}
+	if _, err := requester.Transmit(pkt); err != nil {
+		t.Fatalf("routed transmit failed: %+v", err)
+	}
+	if got := <-target.Packets(); got.Target != pkt.Target || string(got.Data) != "test" {
+		t.Errorf("got %v, want %v", got, pkt)
+	}
+
+	if !srv.RemoveRoute(block) {
+		t.Error("route was not removed")
+	}
+	if _, err := requester.Transmit(pkt); !errors.Is(err, gerte.ErrNoRoute) {
+		t.Errorf("got %+v, want %+v", err, gerte.ErrNoRoute)
+	}
+}
+
+func TestServer_WriteTimeout(t *testing.T) {
+	srv := NewServer(testVersion, Resolutions{requesterAddr: testKey, targetAddr: testKey})
+	srv.WriteTimeout = 50 * time.Millisecond
+	defer srv.Close()
+	connectPipe := func(addr gerte.GertAddress) *gerte.Api {
+		server, client := net.Pipe()
+		go srv.ServeConn(server)
+		api := gerte.NewApi(testVersion)
+		if err := api.Startup(client); err != nil {
+			t.Fatalf("error on startup: %+v", err)
+		}
+		if _, err := api.Register(addr, testKey); err != nil {
+			t.Fatalf("error on register: %+v", err)
+		}
+		return api
+	}
+	requester := connectPipe(requesterAddr)
+	// the target never reads, so writes to it block
+	connectPipe(targetAddr)
+
+	pkt := gerte.Packet{
+		Target: gerte.GERTc{GERTe: targetAddr},
+		Data:   []byte("test"),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := requester.TransmitContext(ctx, pkt); !errors.Is(err, gerte.ErrNoRoute) {
+		t.Errorf("got %+v, want %+v", err, gerte.ErrNoRoute)
+	}
+	if _, err := requester.TransmitContext(ctx, pkt); !errors.Is(err, gerte.ErrNoRoute) {
+		t.Errorf("got %+v after the target was disconnected, want %+v", err, gerte.ErrNoRoute)
+	}
+}
+
+func TestServer_Close(t *testing.T) {
+	srv := NewServer(testVersion, Resolutions{})
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error on listen: %+v", err)
+	}
+	served := make(chan error, 1)
+	go func() {
+		served <- srv.Serve(l)
+	}()
+
+	// connections arriving while the server closes are either served until Close or refused
+	conns := make(chan net.Conn, 16)
+	for i := 0; i < cap(conns); i++ {
+		go func() {
+			server, client := net.Pipe()
+			conns <- client
+			srv.ServeConn(server)
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		go func() {
+			if c, err := net.Dial("tcp", l.Addr().String()); err == nil {
+				c.Close()
+			}
+		}()
+	}
+	if err := srv.Close(); err != nil {
+		t.Errorf("error on close server: %+v", err)
+	}
+	if err := <-served; !errors.Is(err, ErrServerClosed) {
+		t.Errorf("got %+v, want %+v", err, ErrServerClosed)
+	}
+	for i := 0; i < cap(conns); i++ {
+		c := <-conns
+		if _, err := c.Write([]byte{1, 1}); err == nil {
+			t.Error("connection was served after close")
+		}
+	}
+	server, client := net.Pipe()
+	defer client.Close()
+	srv.ServeConn(server)
+	if _, err := server.Write([]byte{0}); err == nil {
+		t.Error("connection accepted after close isn't closed")
+	}
+}
@@ -1,26 +1,72 @@
 package gerte
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
-// GERTc is a 6 byte GERTc Address
+// GERTc is a 6 byte GERTc Address.
+// It implements encoding.TextMarshaler and encoding.TextUnmarshaler with the "XXXX.YYYY:XXXX.YYYY" format printed by String.
 type GERTc struct {
 	GERTe GertAddress
 	GERTi GertAddress
 }
 
-// GertCFromBytes parses bytes to a GERTc Address
-func GertCFromBytes(data []byte) GERTc {
-	return GERTc{
-		GERTe: GertAddress{
-			Upper: (int(data[0]) << 4) | (int(data[1]) >> 4),
-			Lower: ((int(data[1]) & 0x0F) << 8) | int(data[2]),
-		},
-		GERTi: GertAddress{
-			Upper: (int(data[3]) << 4) | (int(data[4]) >> 4),
-			Lower: ((int(data[4]) & 0x0F) << 8) | int(data[5]),
-		},
+// NewGERTc is the validating constructor for GERTc.
+// It returns the GERTc and an error wrapping ErrAddressRange if one of the addresses is invalid.
+func NewGERTc(gertE, gertI GertAddress) (GERTc, error) {
+	addr := GERTc{GERTe: gertE, GERTi: gertI}
+	if err := addr.Validate(); err != nil {
+		return GERTc{}, err
 	}
+	return addr, nil
+}
 
+// Validate checks that the GERTe and GERTi address fit into 12 bit halves.
+// It returns an error wrapping ErrAddressRange otherwise.
+func (addr GERTc) Validate() error {
+	if err := addr.GERTe.Validate(); err != nil {
+		return fmt.Errorf("invalid GERTe address: %w", err)
+	}
+	if err := addr.GERTi.Validate(); err != nil {
+		return fmt.Errorf("invalid GERTi address: %w", err)
+	}
+	return nil
+}
+
+// GertCFromString parses a GERTc Address in the format "XXXX.YYYY:XXXX.YYYY" as printed by GERTc.String.
+// It returns the GERTc and any encountered errors, wrapping ErrInvalidAddress or ErrAddressRange.
+func GertCFromString(address string) (GERTc, error) {
+	parts := strings.Split(address, ":")
+	if len(parts) != 2 {
+		return GERTc{}, fmt.Errorf("%w: %q is not in the format XXXX.YYYY:XXXX.YYYY", ErrInvalidAddress, address)
+	}
+	gertE, err := AddressFromString(parts[0])
+	if err != nil {
+		return GERTc{}, fmt.Errorf("error on parse GERTe address: %w", err)
+	}
+	gertI, err := AddressFromString(parts[1])
+	if err != nil {
+		return GERTc{}, fmt.Errorf("error on parse GERTi address: %w", err)
+	}
+	return GERTc{
+		GERTe: gertE,
+		GERTi: gertI,
+	}, nil
+}
+
+// GertCFromBytes parses bytes to a GERTc Address.
+// It returns the GERTc and any encountered errors, data has to hold at least 6 bytes.
+func GertCFromBytes(data []byte) (GERTc, error) {
+	if err := checkLength(data, 6, "GERTc address"); err != nil {
+		return GERTc{}, err
+	}
+	gertE, _ := AddressFromBytes(data[:3])
+	gertI, _ := AddressFromBytes(data[3:6])
+	return GERTc{
+		GERTe: gertE,
+		GERTi: gertI,
+	}, nil
 }
 
 // ToBytes converts a GERTc to bytes for sending
@@ -28,6 +74,21 @@ func (addr GERTc) ToBytes() []byte {
 	return append(addr.GERTe.ToBytes(), addr.GERTi.ToBytes()...)
 }
 
+// ResolveGERTcAddr parses a GERTc Address in the format "XXXX.YYYY:XXXX.YYYY" as printed by GERTc.String.
+// It returns the GERTc and any encountered errors.
+// The network must be "gert" or empty.
+func ResolveGERTcAddr(network, address string) (GERTc, error) {
+	if network != "" && network != Network {
+		return GERTc{}, fmt.Errorf("unknown network %v", network)
+	}
+	return GertCFromString(address)
+}
+
+// Network returns the name of the network for net.Addr
+func (addr GERTc) Network() string {
+	return Network
+}
+
 // String prints a GERTc Address as a string
 func (addr GERTc) String() string {
 	return fmt.Sprintf("%04v.%04v:%04v.%04v", addr.GERTe.Upper, addr.GERTe.Lower, addr.GERTi.Upper, addr.GERTi.Lower)
@@ -37,3 +98,23 @@ func (addr GERTc) String() string {
 func (addr GERTc) GoString() string {
 	return fmt.Sprintf("[%v]", addr)
 }
+
+// MarshalText encodes the GERTc in the format "XXXX.YYYY:XXXX.YYYY".
+// It returns the text and an error wrapping ErrAddressRange if the address is invalid.
+func (addr GERTc) MarshalText() ([]byte, error) {
+	if err := addr.Validate(); err != nil {
+		return nil, err
+	}
+	return []byte(addr.String()), nil
+}
+
+// UnmarshalText decodes a GERTc in the format "XXXX.YYYY:XXXX.YYYY" like GertCFromString.
+// It returns any encountered errors.
+func (addr *GERTc) UnmarshalText(text []byte) error {
+	parsed, err := GertCFromString(string(text))
+	if err != nil {
+		return err
+	}
+	*addr = parsed
+	return nil
+}
@@ -1,6 +1,9 @@
 package gerte
 
-import "testing"
+import (
+	"errors"
+	"testing"
+)
 
 func TestGertCFromToBytes(t *testing.T) {
 	address := GERTc{
@@ -15,9 +18,73 @@ func TestGertCFromToBytes(t *testing.T) {
 	}
 
 	addr := address.ToBytes()
-	address2 := GertCFromBytes(addr)
+	address2, err := GertCFromBytes(addr)
+	if err != nil {
+		t.Errorf("error on parse address: %+v", err)
+	}
 
 	if address != address2 {
 		t.Error("addresses don't match")
 	}
 }
+
+func TestResolveGERTcAddr(t *testing.T) {
+	address := GERTc{
+		GERTe: GertAddress{
+			Upper: 1123,
+			Lower: 1456,
+		},
+		GERTi: GertAddress{
+			Upper: 12,
+			Lower: 34,
+		},
+	}
+	address2, err := ResolveGERTcAddr("gert", address.String())
+	if err != nil {
+		t.Errorf("error on resolve address: %+v", err)
+	}
+	if address != address2 {
+		t.Error("addresses don't match")
+	}
+	for _, s := range []string{"1123.1456", "1123:1456", "1123.1456:0012.0034:0001.0001", "a.b:c.d"} {
+		if _, err := ResolveGERTcAddr("", s); err == nil {
+			t.Errorf("invalid address %q was resolved", s)
+		}
+	}
+	if _, err := ResolveGERTcAddr("tcp", address.String()); err == nil {
+		t.Error("address for wrong network was resolved")
+	}
+}
+
+func TestGertCFromString(t *testing.T) {
+	addr, err := GertCFromString("1123.1456:0012.0034")
+	want := GERTc{GERTe: GertAddress{Upper: 1123, Lower: 1456}, GERTi: GertAddress{Upper: 12, Lower: 34}}
+	if err != nil || addr != want {
+		t.Errorf("got %v %+v, want %v", addr, err, want)
+	}
+	var text GERTc
+	if err := text.UnmarshalText([]byte(want.String())); err != nil || text != want {
+		t.Errorf("got %v %+v, want %v", text, err, want)
+	}
+	tests := []struct {
+		in   string
+		want error
+	}{
+		{"1123.1456", ErrInvalidAddress},
+		{"1123.1456:", ErrInvalidAddress},
+		{"1123:1456", ErrInvalidAddress},
+		{"1123.1456:0012.0034:0001.0001", ErrInvalidAddress},
+		{"1123.1456:4096.0001", ErrAddressRange},
+	}
+	for _, tt := range tests {
+		if _, err := GertCFromString(tt.in); !errors.Is(err, tt.want) {
+			t.Errorf("GertCFromString(%q): got %+v, want %+v", tt.in, err, tt.want)
+		}
+	}
+	if _, err := NewGERTc(want.GERTe, GertAddress{Lower: 4096}); !errors.Is(err, ErrAddressRange) {
+		t.Errorf("got %+v, want %+v", err, ErrAddressRange)
+	}
+	if _, err := (GERTc{GERTi: GertAddress{Upper: -1}}).MarshalText(); !errors.Is(err, ErrAddressRange) {
+		t.Errorf("got %+v, want %+v", err, ErrAddressRange)
+	}
+}
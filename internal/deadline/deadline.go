@@ -0,0 +1,74 @@
+// Package deadline provides resettable deadlines for blocking reads and writes of the net.Conn like types in this module.
+package deadline
+
+import (
+	"sync"
+	"time"
+)
+
+// ErrTimeout is returned by operations that exceeded a deadline, it implements net.Error
+var ErrTimeout error = timeoutError{}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// Deadline is a resettable deadline.
+// Wait returns a channel that is closed once the deadline passed, setting a new deadline wakes up waiting operations.
+type Deadline struct {
+	mu      sync.Mutex
+	timer   *time.Timer
+	expired chan struct{}
+}
+
+// New is the constructor for Deadline, the deadline is disabled
+func New() *Deadline {
+	return &Deadline{expired: make(chan struct{})}
+}
+
+// Set changes the deadline, the zero time disables it
+func (d *Deadline) Set(t time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.timer != nil && !d.timer.Stop() {
+		// the timer already fired or is about to close the old channel
+		d.expired = make(chan struct{})
+	}
+	d.timer = nil
+	select {
+	case <-d.expired:
+		d.expired = make(chan struct{})
+	default:
+	}
+	if t.IsZero() {
+		return
+	}
+	dur := time.Until(t)
+	if dur <= 0 {
+		close(d.expired)
+		return
+	}
+	expired := d.expired
+	d.timer = time.AfterFunc(dur, func() {
+		close(expired)
+	})
+}
+
+// Wait returns a channel that is closed when the current deadline passed
+func (d *Deadline) Wait() <-chan struct{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.expired
+}
+
+// Expired returns whether the current deadline passed
+func (d *Deadline) Expired() bool {
+	select {
+	case <-d.Wait():
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,80 @@
+package gerte
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Keepalive starts a goroutine that requests the state from the relay every interval to detect dead connections.
+// It returns a function stopping the keepalive and any encountered errors.
+// If the relay doesn't answer within timeout, forgot the registration of the Api, or the query fails otherwise,
+// the connection is closed and the session fails,
+// which stops the receive loop with the error of the query and notifies the hooks added with OnSessionChange.
+// onError is called with the error first if it is not nil.
+// A timeout of zero or less uses interval. The receive loop has to be running, the keepalive stops together with it.
+func (api *Api) Keepalive(interval, timeout time.Duration, onError func(error)) (func(), error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid keepalive interval: %v", interval)
+	}
+	if timeout <= 0 {
+		timeout = interval
+	}
+	api.mu.Lock()
+	receiving, done := api.receiving, api.done
+	api.mu.Unlock()
+	if !receiving {
+		return nil, fmt.Errorf("receive loop is not running")
+	}
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			case <-done:
+				return
+			}
+			if err := api.probe(timeout); err != nil {
+				if isClosed(stop) || isClosed(done) || !api.SessionState().open() {
+					// the session ended while probing
+					return
+				}
+				err = fmt.Errorf("error on keepalive: %w", err)
+				if onError != nil {
+					onError(err)
+				}
+				_ = api.closeSocket(SessionFailed, err)
+				api.notify()
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(stop)
+		})
+	}, nil
+}
+
+// probe requests the state from the relay, waiting at most timeout for the answer.
+// It returns an error wrapping ErrNotRegistered if the relay answers a registered Api with StateConnected.
+func (api *Api) probe(timeout time.Duration) error {
+	registered := api.SessionState() == SessionAssigned
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	status, err := api.StateContext(ctx)
+	if err != nil {
+		return err
+	}
+	if registered && status.Status == StateConnected {
+		// packets to the address aren't delivered anymore, the connection has to be registered again
+		return fmt.Errorf("%w: relay lost the registration", ErrNotRegistered)
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package gerte
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApi_State(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	connected := append([]byte{byte(CommandState), byte(StateConnected)}, Version{Major: 1, Minor: 1}.ToBytes()...)
+	wg := scriptedRelay(t, server,
+		[]byte{byte(CommandState), byte(StateAssigned)},
+		connected,
+	)
+
+	var api Api
+	attach(&api, client, SessionAssigned)
+	status, err := api.State()
+	if err != nil || status.Status != StateAssigned {
+		t.Errorf("got %v %+v, want %v", status, err, StateAssigned)
+	}
+	// the relay forgot the registration
+	status, err = api.State()
+	if err != nil || status.Status != StateConnected || status.Version != (Version{Major: 1, Minor: 1}) {
+		t.Errorf("got %v %+v, want %v", status, err, connected)
+	}
+	if api.SessionState() != SessionConnected {
+		t.Errorf("got state %v, want %v", api.SessionState(), SessionConnected)
+	}
+	wg.Wait()
+
+	api = Api{}
+	if _, err := api.State(); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("got %+v, want %+v", err, ErrNotConnected)
+	}
+}
+
+func TestApi_Keepalive(t *testing.T) {
+	t.Run("KeepaliveAlive", func(t *testing.T) {
+		server, client := net.Pipe()
+		defer server.Close()
+		assigned := []byte{byte(CommandState), byte(StateAssigned)}
+		wg := scriptedRelay(t, server, assigned, assigned, assigned)
+
+		var api Api
+		attach(&api, client, SessionAssigned)
+		if err := api.Receive(func(Packet) {}); err != nil {
+			t.Fatalf("error on receive: %+v", err)
+		}
+		stop, err := api.Keepalive(5*time.Millisecond, 0, func(err error) {
+			t.Errorf("keepalive failed: %+v", err)
+		})
+		if err != nil {
+			t.Fatalf("error on keepalive: %+v", err)
+		}
+		wg.Wait()
+		stop()
+		stop()
+		if api.SessionState() != SessionAssigned {
+			t.Errorf("got state %v, want %v", api.SessionState(), SessionAssigned)
+		}
+	})
+	t.Run("KeepaliveDead", func(t *testing.T) {
+		server, client := net.Pipe()
+		defer server.Close()
+		wg := silentRelay(server)
+
+		var api Api
+		attach(&api, client, SessionAssigned)
+		if err := api.Receive(func(Packet) {}); err != nil {
+			t.Fatalf("error on receive: %+v", err)
+		}
+		failed := make(chan error, 1)
+		if _, err := api.Keepalive(5*time.Millisecond, 10*time.Millisecond, func(err error) {
+			failed <- err
+		}); err != nil {
+			t.Fatalf("error on keepalive: %+v", err)
+		}
+		select {
+		case err := <-failed:
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("got %+v, want %+v", err, context.DeadlineExceeded)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("keepalive didn't detect the dead relay")
+		}
+		<-api.Done()
+		if err := api.Err(); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("receive loop stopped with %+v, want %+v", err, context.DeadlineExceeded)
+		}
+		if api.SessionState() != SessionFailed {
+			t.Errorf("got state %v, want %v", api.SessionState(), SessionFailed)
+		}
+		server.Close()
+		wg.Wait()
+	})
+	t.Run("KeepaliveUnregistered", func(t *testing.T) {
+		server, client := net.Pipe()
+		defer server.Close()
+		connected := append([]byte{byte(CommandState), byte(StateConnected)}, Version{Major: 1, Minor: 1}.ToBytes()...)
+		wg := scriptedRelay(t, server, connected)
+
+		var api Api
+		attach(&api, client, SessionAssigned)
+		if err := api.Receive(func(Packet) {}); err != nil {
+			t.Fatalf("error on receive: %+v", err)
+		}
+		if _, err := api.Keepalive(5*time.Millisecond, 0, nil); err != nil {
+			t.Fatalf("error on keepalive: %+v", err)
+		}
+		<-api.Done()
+		if err := api.Err(); !errors.Is(err, ErrNotRegistered) {
+			t.Errorf("receive loop stopped with %+v, want %+v", err, ErrNotRegistered)
+		}
+		if api.SessionState() != SessionFailed {
+			t.Errorf("got state %v, want %v", api.SessionState(), SessionFailed)
+		}
+		server.Close()
+		wg.Wait()
+	})
+	t.Run("KeepaliveWithoutReceive", func(t *testing.T) {
+		var api Api
+		if _, err := api.Keepalive(time.Second, 0, nil); err == nil {
+			t.Error("keepalive started without receive loop")
+		}
+	})
+}
+
+func TestSupervisor_Keepalive(t *testing.T) {
+	connected := append([]byte{byte(CommandState), byte(StateConnected)}, Version{Major: 1, Minor: 1}.ToBytes()...)
+	assigned := []byte{byte(CommandState), byte(StateAssigned)}
+	relays := make(chan net.Conn, 64)
+	dial := func(ctx context.Context) (net.Conn, error) {
+		server, client := net.Pipe()
+		relays <- server
+		// the relay registers the gateway and stops answering
+		go func() {
+			dat := make([]byte, 1024)
+			for _, reply := range [][]byte{connected, assigned} {
+				if _, err := server.Read(dat); err != nil {
+					return
+				}
+				if _, err := server.Write(reply); err != nil {
+					return
+				}
+			}
+		}()
+		return client, nil
+	}
+
+	sup := NewSupervisor(dial, Version{Major: 1, Minor: 1}, GertAddress{Upper: 1, Lower: 1}, "aaaaaaaaaaaaaaaaaaaa")
+	sup.Backoff = Backoff{Initial: time.Millisecond, Max: time.Millisecond, Multiplier: 1}
+	sup.KeepaliveInterval = 5 * time.Millisecond
+	sup.KeepaliveTimeout = 10 * time.Millisecond
+	disconnected := make(chan error, 8)
+	sup.OnEvent = func(ev ConnEvent) {
+		if ev.State == ConnDisconnected && ev.Err != nil {
+			select {
+			case disconnected <- ev.Err:
+			default:
+			}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- sup.Run(ctx)
+	}()
+	select {
+	case err := <-disconnected:
+		if !strings.Contains(err.Error(), "keepalive") {
+			t.Errorf("unexpected disconnect: %+v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("supervisor didn't detect the dead relay")
+	}
+	cancel()
+	<-stopped
+	close(relays)
+	for relay := range relays {
+		relay.Close()
+	}
+}
@@ -0,0 +1,295 @@
+package gerte
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/OmegaRogue/gerte-go/internal/deadline"
+)
+
+// listenerBacklog is the number of new connections waiting for Accept before packets of further new peers are dropped
+const listenerBacklog = 16
+
+// Listener accepts virtual connections from remote GERTc peers, it implements net.Listener.
+// The first inbound packet from a GERTc without an open connection yields a new PeerConn,
+// all following packets from that GERTc are fed into it.
+type Listener struct {
+	api   *Api
+	local GERTc
+
+	mu     sync.Mutex
+	conns  map[GERTc]*PeerConn
+	accept chan *PeerConn
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// PeerConn is a virtual connection to a remote GERTc accepted by a Listener, it implements net.Conn.
+// Reads return the data of the inbound packets from the peer in order, writes are transmitted as packets of up to Api.MaxData bytes.
+// Packet boundaries are not preserved and packets are dropped if the reader does not keep up, like a datagram would be.
+type PeerConn struct {
+	l      *Listener
+	remote GERTc
+	in     chan []byte
+
+	// rest is the unread data of the last packet, it is only used by Read
+	rest   []byte
+	readMu sync.Mutex
+
+	readDeadline  *deadline.Deadline
+	writeDeadline *deadline.Deadline
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+var (
+	_ net.Listener = (*Listener)(nil)
+	_ net.Conn     = (*PeerConn)(nil)
+)
+
+// Listen starts the receive loop of a registered api and accepts connections from remote peers sending to the GERTi address local.
+// It returns the Listener and any encountered errors.
+// Inbound packets for other GERTi addresses are dropped.
+func (api *Api) Listen(local GertAddress) (net.Listener, error) {
+	addr, _ := api.Registration()
+	l := &Listener{
+		api: api,
+		local: GERTc{
+			GERTe: addr,
+			GERTi: local,
+		},
+		conns:  make(map[GERTc]*PeerConn),
+		accept: make(chan *PeerConn, listenerBacklog),
+		closed: make(chan struct{}),
+	}
+	if err := api.Receive(l.deliver); err != nil {
+		return nil, fmt.Errorf("error on start receive loop: %w", err)
+	}
+	return l, nil
+}
+
+// Accept waits for the next connection from a remote peer.
+// It returns the connection and any encountered errors.
+func (l *Listener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.accept:
+		return c, nil
+	default:
+	}
+	select {
+	case c := <-l.accept:
+		return c, nil
+	case <-l.closed:
+		return nil, l.opError(fmt.Errorf("use of closed network connection"))
+	case <-l.api.Done():
+		err := l.api.Err()
+		if err == nil {
+			err = fmt.Errorf("connection closed")
+		}
+		return nil, l.opError(err)
+	}
+}
+
+// Close stops accepting new connections.
+// It returns any encountered errors.
+// Accepted connections keep working until they are closed, the Api is not shut down.
+func (l *Listener) Close() error {
+	err := l.opError(fmt.Errorf("use of closed network connection"))
+	l.closeOnce.Do(func() {
+		l.mu.Lock()
+		close(l.closed)
+		l.mu.Unlock()
+		err = nil
+	})
+	return err
+}
+
+// Addr returns the GERTc of the Listener
+func (l *Listener) Addr() net.Addr {
+	return l.local
+}
+
+// deliver feeds an inbound packet into the connection of its source, it is called from the receive loop and never blocks.
+// Empty packets are dropped, Read would return them as zero bytes without an error.
+func (l *Listener) deliver(pkt Packet) {
+	if pkt.Target.GERTi != l.local.GERTi || len(pkt.Data) == 0 {
+		return
+	}
+	l.mu.Lock()
+	c, ok := l.conns[pkt.Source]
+	if !ok {
+		if isClosed(l.closed) {
+			l.mu.Unlock()
+			return
+		}
+		c = &PeerConn{
+			l:             l,
+			remote:        pkt.Source,
+			in:            make(chan []byte, packetConnBuffer),
+			readDeadline:  deadline.New(),
+			writeDeadline: deadline.New(),
+			closed:        make(chan struct{}),
+		}
+		select {
+		case l.accept <- c:
+			l.conns[pkt.Source] = c
+		default:
+			l.mu.Unlock()
+			return
+		}
+	}
+	l.mu.Unlock()
+	select {
+	case c.in <- pkt.Data:
+	default:
+	}
+}
+
+func (l *Listener) opError(err error) error {
+	return &net.OpError{
+		Op:   "accept",
+		Net:  Network,
+		Addr: l.local,
+		Err:  err,
+	}
+}
+
+// Read reads data sent by the peer into p.
+// It returns the number of bytes read and any encountered errors, io.EOF once the relay session was shut down.
+func (c *PeerConn) Read(p []byte) (int, error) {
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+	if isClosed(c.closed) {
+		return 0, c.opError("read", fmt.Errorf("use of closed connection"))
+	}
+	if len(c.rest) == 0 {
+		data, err := c.next()
+		if err != nil {
+			return 0, err
+		}
+		c.rest = data
+	}
+	n := copy(p, c.rest)
+	c.rest = c.rest[n:]
+	return n, nil
+}
+
+// next waits for the data of the next inbound packet
+func (c *PeerConn) next() ([]byte, error) {
+	select {
+	case data := <-c.in:
+		return data, nil
+	default:
+	}
+	select {
+	case data := <-c.in:
+		return data, nil
+	case <-c.closed:
+		return nil, c.opError("read", fmt.Errorf("use of closed connection"))
+	case <-c.l.api.Done():
+		select {
+		case data := <-c.in:
+			return data, nil
+		default:
+		}
+		if err := c.l.api.Err(); err != nil {
+			return nil, c.opError("read", err)
+		}
+		return nil, io.EOF
+	case <-c.readDeadline.Wait():
+		return nil, c.opError("read", deadline.ErrTimeout)
+	}
+}
+
+// Write sends p to the peer, split into packets of up to Api.MaxData bytes.
+// It returns the number of bytes sent and any encountered errors, an empty p sends nothing.
+func (c *PeerConn) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	written := 0
+	maxData := c.l.api.MaxData()
+	for {
+		select {
+		case <-c.closed:
+			return written, c.opError("write", fmt.Errorf("use of closed connection"))
+		default:
+		}
+		end := written + maxData
+		if end > len(p) {
+			end = len(p)
+		}
+		err := c.l.api.transmitUntil(Packet{
+			Source: c.l.local,
+			Target: c.remote,
+			Data:   p[written:end],
+		}, c.writeDeadline, c.closed)
+		if err != nil {
+			return written, c.opError("write", err)
+		}
+		written = end
+		if written == len(p) {
+			return written, nil
+		}
+	}
+}
+
+// Close closes the connection, the next packet from the peer yields a new connection if the Listener is still open.
+// It returns any encountered errors.
+func (c *PeerConn) Close() error {
+	err := c.opError("close", fmt.Errorf("use of closed connection"))
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		c.l.mu.Lock()
+		if c.l.conns[c.remote] == c {
+			delete(c.l.conns, c.remote)
+		}
+		c.l.mu.Unlock()
+		err = nil
+	})
+	return err
+}
+
+// LocalAddr returns the GERTc of the Listener
+func (c *PeerConn) LocalAddr() net.Addr {
+	return c.l.local
+}
+
+// RemoteAddr returns the GERTc of the peer
+func (c *PeerConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+// SetDeadline sets the read and write deadlines
+func (c *PeerConn) SetDeadline(t time.Time) error {
+	c.readDeadline.Set(t)
+	c.writeDeadline.Set(t)
+	return nil
+}
+
+// SetReadDeadline sets the deadline for Read, the zero time disables it
+func (c *PeerConn) SetReadDeadline(t time.Time) error {
+	c.readDeadline.Set(t)
+	return nil
+}
+
+// SetWriteDeadline sets the deadline for Write, the zero time disables it
+func (c *PeerConn) SetWriteDeadline(t time.Time) error {
+	c.writeDeadline.Set(t)
+	return nil
+}
+
+func (c *PeerConn) opError(op string, err error) error {
+	return &net.OpError{
+		Op:     op,
+		Net:    Network,
+		Source: c.l.local,
+		Addr:   c.remote,
+		Err:    err,
+	}
+}
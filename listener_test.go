@@ -0,0 +1,136 @@
+package gerte_test
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/OmegaRogue/gerte-go"
+)
+
+func TestApi_Listen(t *testing.T) {
+	addrA := gerte.GertAddress{Upper: 1123, Lower: 1456}
+	addrB := gerte.GertAddress{Upper: 2345, Lower: 1456}
+	srv := startRelay(t, addrA, addrB)
+
+	apiB := registerApi(t, srv, addrB)
+	l, err := apiB.Listen(gerte.GertAddress{Upper: 2, Lower: 2})
+	if err != nil {
+		t.Fatalf("error on listen: %+v", err)
+	}
+	defer apiB.Shutdown()
+	peerA, err := gerte.NewPacketConn(registerApi(t, srv, addrA), gerte.GertAddress{Upper: 1, Lower: 1})
+	if err != nil {
+		t.Fatalf("error on create packet conn: %+v", err)
+	}
+	defer peerA.Close()
+
+	accept := func() net.Conn {
+		t.Helper()
+		c, err := l.Accept()
+		if err != nil {
+			t.Fatalf("error on accept: %+v", err)
+		}
+		return c
+	}
+	send := func(data string) {
+		t.Helper()
+		if _, err := peerA.WriteTo([]byte(data), l.Addr()); err != nil {
+			t.Fatalf("error on write: %+v", err)
+		}
+	}
+	buf := make([]byte, 255)
+
+	t.Run("ListenAccept", func(t *testing.T) {
+		send("hello")
+		send("world")
+		c := accept()
+		defer c.Close()
+		if c.RemoteAddr() != peerA.LocalAddr() {
+			t.Errorf("remote address: got %v, want %v", c.RemoteAddr(), peerA.LocalAddr())
+		}
+		got := make([]byte, 10)
+		if _, err := io.ReadFull(c, got); err != nil {
+			t.Fatalf("error on read: %+v", err)
+		}
+		if string(got) != "helloworld" {
+			t.Errorf("got %q, want %q", got, "helloworld")
+		}
+		// empty packets are skipped instead of reading zero bytes
+		send("")
+		send("again")
+		if n, err := c.Read(buf); err != nil || string(buf[:n]) != "again" {
+			t.Errorf("got %q %+v, want %q", buf[:n], err, "again")
+		}
+
+		// empty writes send no packet, so the peer reads the reply first
+		for _, p := range [][]byte{nil, {}} {
+			if n, err := c.Write(p); n != 0 || err != nil {
+				t.Fatalf("got %v %+v on empty write", n, err)
+			}
+		}
+		if _, err := c.Write([]byte("reply")); err != nil {
+			t.Fatalf("error on write: %+v", err)
+		}
+		n, from, err := peerA.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("error on read: %+v", err)
+		}
+		if string(buf[:n]) != "reply" || from != l.Addr() {
+			t.Errorf("unexpected packet from %v: %q", from, buf[:n])
+		}
+	})
+	t.Run("ListenCloseConn", func(t *testing.T) {
+		send("first")
+		c := accept()
+		if err := c.Close(); err != nil {
+			t.Fatalf("error on close: %+v", err)
+		}
+		if _, err := c.Read(buf); err == nil {
+			t.Error("read on closed connection succeeded")
+		}
+		if err := c.Close(); err == nil {
+			t.Error("second close succeeded")
+		}
+
+		send("second")
+		c = accept()
+		defer c.Close()
+		n, err := c.Read(buf)
+		if err != nil {
+			t.Fatalf("error on read: %+v", err)
+		}
+		if string(buf[:n]) != "second" {
+			t.Errorf("got %q, want %q", buf[:n], "second")
+		}
+	})
+	t.Run("ListenDeadline", func(t *testing.T) {
+		send("ping")
+		c := accept()
+		defer c.Close()
+		if _, err := c.Read(buf); err != nil {
+			t.Fatalf("error on read: %+v", err)
+		}
+		if err := c.SetReadDeadline(time.Now().Add(20 * time.Millisecond)); err != nil {
+			t.Fatalf("error on set deadline: %+v", err)
+		}
+		_, err := c.Read(buf)
+		if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+			t.Errorf("expected timeout, got %+v", err)
+		}
+	})
+	t.Run("ListenClose", func(t *testing.T) {
+		if err := l.Close(); err != nil {
+			t.Fatalf("error on close: %+v", err)
+		}
+		if _, err := l.Accept(); err == nil {
+			t.Error("accept on closed listener succeeded")
+		}
+	})
+	t.Run("ListenTwice", func(t *testing.T) {
+		if _, err := apiB.Listen(gerte.GertAddress{}); err == nil {
+			t.Error("second listen succeeded")
+		}
+	})
+}
@@ -0,0 +1,334 @@
+package gerte
+
+import (
+	"encoding"
+	"fmt"
+)
+
+const (
+	// KeySize is the length of the key used to register a GERTe address
+	KeySize = 20
+	// MaxData is the largest payload of a DATA frame with a one byte length
+	MaxData = 255
+	// MaxExtendedData is the largest payload of a DATA frame with the two byte length of ExtendedVersion
+	MaxExtendedData = 65535
+)
+
+// Message is a single frame of the GERTe protocol.
+// Gateways and relays use different layouts for the same command, so every direction has its own message types:
+// gateways send StateRequest, Register, OutboundData and Close, relays send StateReply, InboundData and Close.
+// The version negotiation at the start of a connection is not a Message, see ClientCodec.WriteVersion and ServerCodec.ReadVersion.
+type Message interface {
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+	// Command returns the command of the Message
+	Command() GertCommand
+}
+
+type (
+	// StateRequest asks the relay for the state of the gateway, it is sent by gateways
+	StateRequest struct{}
+
+	// Register claims Address for the gateway using Key, it is sent by gateways
+	Register struct {
+		Address GertAddress
+		Key     string
+	}
+
+	// OutboundData sends Data from the GERTi address Source behind the gateway to Target, it is sent by gateways.
+	// The relay fills in the GERTe address of the gateway.
+	OutboundData struct {
+		Target GERTc
+		Source GertAddress
+		Data   []byte
+	}
+
+	// InboundData delivers Data sent by Source to Target, it is sent by relays
+	InboundData struct {
+		Source GERTc
+		Target GERTc
+		Data   []byte
+	}
+
+	// StateReply is the answer of the relay to a command, it is sent by relays
+	StateReply struct {
+		Status Status
+	}
+
+	// Close closes the connection gracefully, it is sent by gateways and relays
+	Close struct{}
+)
+
+// NewOutboundData converts the Packet pkt to the OutboundData a gateway sends for it
+func NewOutboundData(pkt Packet) *OutboundData {
+	return &OutboundData{
+		Target: pkt.Target,
+		Source: pkt.Source.GERTi,
+		Data:   pkt.Data,
+	}
+}
+
+// Command returns CommandState
+func (*StateRequest) Command() GertCommand {
+	return CommandState
+}
+
+// MarshalBinary encodes the StateRequest.
+// It returns the frame and any encountered errors.
+func (*StateRequest) MarshalBinary() ([]byte, error) {
+	return []byte{byte(CommandState)}, nil
+}
+
+// UnmarshalBinary decodes a StateRequest from a complete frame.
+// It returns any encountered errors.
+func (*StateRequest) UnmarshalBinary(data []byte) error {
+	return checkFrame(data, CommandState, 1, "state request")
+}
+
+// Command returns CommandRegister
+func (*Register) Command() GertCommand {
+	return CommandRegister
+}
+
+// MarshalBinary encodes the Register message.
+// It returns the frame and any encountered errors, the Key has to be KeySize bytes long and the Address valid.
+func (m *Register) MarshalBinary() ([]byte, error) {
+	if len(m.Key) != KeySize {
+		return nil, fmt.Errorf("invalid key length: %v!=%v", len(m.Key), KeySize)
+	}
+	if err := m.Address.Validate(); err != nil {
+		return nil, err
+	}
+	frame := append([]byte{byte(CommandRegister)}, m.Address.ToBytes()...)
+	return append(frame, m.Key...), nil
+}
+
+// UnmarshalBinary decodes a Register message from a complete frame.
+// It returns any encountered errors.
+func (m *Register) UnmarshalBinary(data []byte) error {
+	if err := checkFrame(data, CommandRegister, 4+KeySize, "register command"); err != nil {
+		return err
+	}
+	addr, err := AddressFromBytes(data[1:4])
+	if err != nil {
+		return fmt.Errorf("error on parse address: %w", err)
+	}
+	m.Address = addr
+	m.Key = string(data[4:])
+	return nil
+}
+
+// Command returns CommandData
+func (*OutboundData) Command() GertCommand {
+	return CommandData
+}
+
+// MarshalBinary encodes the OutboundData with a one byte length.
+// It returns the frame and any encountered errors, Data cannot exceed MaxData bytes.
+func (m *OutboundData) MarshalBinary() ([]byte, error) {
+	return m.marshal(1)
+}
+
+// UnmarshalBinary decodes OutboundData with a one byte length from a complete frame.
+// It returns any encountered errors.
+func (m *OutboundData) UnmarshalBinary(data []byte) error {
+	return m.unmarshal(data, 1)
+}
+
+// marshal encodes the OutboundData with a length of lengthSize bytes
+func (m *OutboundData) marshal(lengthSize int) ([]byte, error) {
+	if err := m.Target.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid target: %w", err)
+	}
+	if err := m.Source.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid source: %w", err)
+	}
+	frame := append([]byte{byte(CommandData)}, m.Target.ToBytes()...)
+	frame = append(frame, m.Source.ToBytes()...)
+	return appendData(frame, m.Data, lengthSize)
+}
+
+// unmarshal decodes OutboundData with a length of lengthSize bytes
+func (m *OutboundData) unmarshal(data []byte, lengthSize int) error {
+	payload, err := frameData(data, 10, lengthSize, "outbound data")
+	if err != nil {
+		return err
+	}
+	m.Target, _ = GertCFromBytes(data[1:7])
+	m.Source, _ = AddressFromBytes(data[7:10])
+	m.Data = append([]byte(nil), payload...)
+	return nil
+}
+
+// Packet converts the OutboundData to a Packet, the GERTe address of the source is left empty
+func (m *OutboundData) Packet() Packet {
+	return Packet{
+		Source: GERTc{GERTi: m.Source},
+		Target: m.Target,
+		Data:   m.Data,
+	}
+}
+
+// Command returns CommandData
+func (*InboundData) Command() GertCommand {
+	return CommandData
+}
+
+// MarshalBinary encodes the InboundData with a one byte length.
+// It returns the frame and any encountered errors, Data cannot exceed MaxData bytes.
+func (m *InboundData) MarshalBinary() ([]byte, error) {
+	return m.marshal(1)
+}
+
+// UnmarshalBinary decodes InboundData with a one byte length from a complete frame.
+// It returns any encountered errors.
+func (m *InboundData) UnmarshalBinary(data []byte) error {
+	return m.unmarshal(data, 1)
+}
+
+// marshal encodes the InboundData with a length of lengthSize bytes
+func (m *InboundData) marshal(lengthSize int) ([]byte, error) {
+	if err := m.Source.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid source: %w", err)
+	}
+	if err := m.Target.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid target: %w", err)
+	}
+	frame := append([]byte{byte(CommandData)}, m.Source.ToBytes()...)
+	frame = append(frame, m.Target.ToBytes()...)
+	return appendData(frame, m.Data, lengthSize)
+}
+
+// unmarshal decodes InboundData with a length of lengthSize bytes
+func (m *InboundData) unmarshal(data []byte, lengthSize int) error {
+	payload, err := frameData(data, 13, lengthSize, "inbound data")
+	if err != nil {
+		return err
+	}
+	m.Source, _ = GertCFromBytes(data[1:7])
+	m.Target, _ = GertCFromBytes(data[7:13])
+	m.Data = append([]byte(nil), payload...)
+	return nil
+}
+
+// Packet converts the InboundData to a Packet
+func (m *InboundData) Packet() Packet {
+	return Packet{
+		Source: m.Source,
+		Target: m.Target,
+		Data:   m.Data,
+	}
+}
+
+// Command returns CommandState
+func (*StateReply) Command() GertCommand {
+	return CommandState
+}
+
+// MarshalBinary encodes the StateReply.
+// It returns the frame and any encountered errors.
+func (m *StateReply) MarshalBinary() ([]byte, error) {
+	frame := []byte{byte(CommandState), byte(m.Status.Status)}
+	switch m.Status.Status {
+	case StateFailure:
+		return append(frame, byte(m.Status.Error)), nil
+	case StateConnected:
+		return append(frame, m.Status.Version.ToBytes()...), nil
+	case StateAssigned, StateClosed, StateSent:
+		return frame, nil
+	}
+	return nil, fmt.Errorf("state didn't match any known state: %v", byte(m.Status.Status))
+}
+
+// UnmarshalBinary decodes a StateReply from a complete frame.
+// It returns any encountered errors.
+func (m *StateReply) UnmarshalBinary(data []byte) error {
+	if err := checkLength(data, 2, "state reply"); err != nil {
+		return err
+	}
+	status, err := StatusFromBytes(data[1:])
+	if err != nil {
+		return fmt.Errorf("error on parse status: %w", err)
+	}
+	size := 2
+	switch status.Status {
+	case StateFailure:
+		size = 3
+	case StateConnected:
+		size = 4
+	}
+	if err := checkFrame(data, CommandState, size, "state reply"); err != nil {
+		return err
+	}
+	m.Status = status
+	return nil
+}
+
+// Command returns CommandClose
+func (*Close) Command() GertCommand {
+	return CommandClose
+}
+
+// MarshalBinary encodes the Close message.
+// It returns the frame and any encountered errors.
+func (*Close) MarshalBinary() ([]byte, error) {
+	return []byte{byte(CommandClose)}, nil
+}
+
+// UnmarshalBinary decodes a Close message from a complete frame.
+// It returns any encountered errors.
+func (*Close) UnmarshalBinary(data []byte) error {
+	return checkFrame(data, CommandClose, 1, "close command")
+}
+
+// checkFrame returns an error if data is not a frame of cmd that is exactly n bytes long, what names the message
+func checkFrame(data []byte, cmd GertCommand, n int, what string) error {
+	if err := checkLength(data, n, what); err != nil {
+		return err
+	}
+	if GertCommand(data[0]) != cmd {
+		return fmt.Errorf("invalid command for %v: %v", what, data[0])
+	}
+	if len(data) > n {
+		return fmt.Errorf("%v trailing bytes after %v", len(data)-n, what)
+	}
+	return nil
+}
+
+// appendData appends the length of data as a big endian number of lengthSize bytes and data itself to frame
+func appendData(frame, data []byte, lengthSize int) ([]byte, error) {
+	if limit := maxData(lengthSize); len(data) > limit {
+		return nil, fmt.Errorf("data cannot exceed %v bytes", limit)
+	}
+	for i := lengthSize - 1; i >= 0; i-- {
+		frame = append(frame, byte(len(data)>>(8*i)))
+	}
+	return append(frame, data...), nil
+}
+
+// frameData returns the payload of the DATA frame data, whose length of lengthSize bytes follows a header of headerSize bytes
+func frameData(data []byte, headerSize, lengthSize int, what string) ([]byte, error) {
+	start := headerSize + lengthSize
+	if err := checkLength(data, start, what+" header"); err != nil {
+		return nil, err
+	}
+	n := dataLength(data[headerSize:start])
+	if err := checkFrame(data, CommandData, start+n, what); err != nil {
+		return nil, err
+	}
+	return data[start:], nil
+}
+
+// dataLength parses the big endian length of a DATA frame
+func dataLength(b []byte) int {
+	n := 0
+	for _, c := range b {
+		n = n<<8 | int(c)
+	}
+	return n
+}
+
+// maxData returns the largest payload a length of lengthSize bytes can describe
+func maxData(lengthSize int) int {
+	return 1<<(8*uint(lengthSize)) - 1
+}
@@ -0,0 +1,197 @@
+package gerte
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestMessage_MarshalBinary(t *testing.T) {
+	source := GERTc{GERTe: GertAddress{Upper: 2345, Lower: 1456}, GERTi: GertAddress{Upper: 1, Lower: 1}}
+	target := GERTc{GERTe: GertAddress{Upper: 1123, Lower: 1456}, GERTi: GertAddress{Upper: 2, Lower: 2}}
+	tests := []struct {
+		name  string
+		msg   Message
+		empty Message
+		frame []byte
+	}{
+		{"StateRequest", &StateRequest{}, new(StateRequest), []byte{0}},
+		{"Register", &Register{Address: target.GERTe, Key: "aaaaaaaaaaaaaaaaaaaa"}, new(Register),
+			append([]byte{1, 0x46, 0x35, 0xB0}, "aaaaaaaaaaaaaaaaaaaa"...)},
+		{"OutboundData", &OutboundData{Target: target, Source: source.GERTi, Data: []byte("hi")}, new(OutboundData),
+			[]byte{2, 0x46, 0x35, 0xB0, 0x00, 0x20, 0x02, 0x00, 0x10, 0x01, 2, 'h', 'i'}},
+		{"InboundData", &InboundData{Source: source, Target: target, Data: []byte("hi")}, new(InboundData),
+			[]byte{2, 0x92, 0x95, 0xB0, 0x00, 0x10, 0x01, 0x46, 0x35, 0xB0, 0x00, 0x20, 0x02, 2, 'h', 'i'}},
+		{"StateReplyFailure", &StateReply{Status: Status{Status: StateFailure, Size: 2, Error: ErrorNoRoute}}, new(StateReply),
+			[]byte{0, 0, 4}},
+		{"StateReplyConnected", &StateReply{Status: Status{Status: StateConnected, Size: 4, Version: Version{Major: 1, Minor: 1}}}, new(StateReply),
+			[]byte{0, 1, 1, 1}},
+		{"StateReplySent", &StateReply{Status: Status{Status: StateSent, Size: 1}}, new(StateReply), []byte{0, 4}},
+		{"Close", &Close{}, new(Close), []byte{3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame, err := tt.msg.MarshalBinary()
+			if err != nil {
+				t.Fatalf("error on marshal: %+v", err)
+			}
+			if !bytes.Equal(frame, tt.frame) {
+				t.Errorf("got frame %v, want %v", frame, tt.frame)
+			}
+			if err := tt.empty.UnmarshalBinary(frame); err != nil {
+				t.Fatalf("error on unmarshal: %+v", err)
+			}
+			if !reflect.DeepEqual(tt.empty, tt.msg) {
+				t.Errorf("got %+v, want %+v", tt.empty, tt.msg)
+			}
+			if err := tt.empty.UnmarshalBinary(frame[:len(frame)-1]); err == nil && len(frame) > 1 {
+				t.Error("truncated frame was unmarshaled")
+			}
+			if err := tt.empty.UnmarshalBinary(append(frame, 0)); err == nil {
+				t.Error("frame with trailing bytes was unmarshaled")
+			}
+		})
+	}
+	t.Run("Invalid", func(t *testing.T) {
+		if _, err := (&Register{Key: "short"}).MarshalBinary(); err == nil {
+			t.Error("register with short key was marshaled")
+		}
+		if _, err := (&OutboundData{Data: make([]byte, 256)}).MarshalBinary(); err == nil {
+			t.Error("data with 256 bytes was marshaled")
+		}
+		if _, err := (&StateReply{Status: Status{Status: 9}}).MarshalBinary(); err == nil {
+			t.Error("invalid state was marshaled")
+		}
+		if err := new(Close).UnmarshalBinary([]byte{0}); err == nil {
+			t.Error("state request was unmarshaled as close")
+		}
+	})
+}
+
+func TestCodec(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+	clientCodec := NewClientCodec(client)
+	serverCodec := NewServerCodec(server)
+
+	gatewayMessages := []Message{
+		&StateRequest{},
+		&Register{Address: GertAddress{Upper: 1, Lower: 1}, Key: "aaaaaaaaaaaaaaaaaaaa"},
+		&OutboundData{Target: GERTc{GERTe: GertAddress{Upper: 2, Lower: 2}}, Data: []byte("hello")},
+		&Close{},
+	}
+	relayMessages := []Message{
+		&StateReply{Status: Status{Status: StateAssigned, Size: 1}},
+		&InboundData{Source: GERTc{GERTe: GertAddress{Upper: 2, Lower: 2}}, Data: []byte("hello")},
+		&Close{},
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		if err := clientCodec.WriteVersion(Version{Major: 1, Minor: 1}); err != nil {
+			errs <- err
+			return
+		}
+		for _, m := range gatewayMessages {
+			if err := clientCodec.WriteMessage(m); err != nil {
+				errs <- err
+				return
+			}
+		}
+		errs <- nil
+	}()
+	ver, err := serverCodec.ReadVersion()
+	if err != nil || ver != (Version{Major: 1, Minor: 1}) {
+		t.Fatalf("got version %v %+v", ver, err)
+	}
+	for _, want := range gatewayMessages {
+		got, err := serverCodec.ReadMessage()
+		if err != nil {
+			t.Fatalf("server errored on read: %+v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("server got %+v, want %+v", got, want)
+		}
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("client errored on write: %+v", err)
+	}
+
+	go func() {
+		for _, m := range relayMessages {
+			if err := serverCodec.WriteMessage(m); err != nil {
+				errs <- err
+				return
+			}
+		}
+		errs <- nil
+	}()
+	for _, want := range relayMessages {
+		got, err := clientCodec.ReadMessage()
+		if err != nil {
+			t.Fatalf("client errored on read: %+v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("client got %+v, want %+v", got, want)
+		}
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("server errored on write: %+v", err)
+	}
+
+	if err := clientCodec.WriteMessage(&InboundData{}); err == nil {
+		t.Error("client codec wrote a relay message")
+	}
+	if err := serverCodec.WriteMessage(&Register{}); err == nil {
+		t.Error("server codec wrote a gateway message")
+	}
+	go server.Write(append([]byte{byte(CommandRegister), 0, 0, 0}, make([]byte, KeySize)...))
+	if _, err := clientCodec.ReadMessage(); !errors.Is(err, ErrInvalidResponse) {
+		t.Errorf("got %+v, want %+v", err, ErrInvalidResponse)
+	}
+}
+
+func TestFrameCodec_Extended(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 1000)
+	target := GERTc{GERTe: GertAddress{Upper: 1123, Lower: 1456}, GERTi: GertAddress{Upper: 2, Lower: 2}}
+	messages := []Message{
+		&OutboundData{Target: target, Source: GertAddress{Upper: 1, Lower: 1}, Data: data},
+		&InboundData{Source: target, Target: target, Data: data},
+	}
+	for _, m := range messages {
+		if _, err := DefaultCodec.Marshal(m); err == nil {
+			t.Errorf("%T with %v bytes was marshaled with a one byte length", m, len(data))
+		}
+		frame, err := ExtendedCodec.Marshal(m)
+		if err != nil {
+			t.Fatalf("error on marshal %T: %+v", m, err)
+		}
+		if n := dataLength(frame[len(frame)-len(data)-2 : len(frame)-len(data)]); n != len(data) {
+			t.Errorf("%T has length %v, want %v", m, n, len(data))
+		}
+
+		dec := NewDecoder(bytes.NewReader(frame))
+		dec.SetCodec(ExtendedCodec)
+		read := dec.ReadFrame
+		if _, ok := m.(*OutboundData); ok {
+			read = dec.readGatewayFrame
+		}
+		got, err := read()
+		if err != nil || !bytes.Equal(got, frame) {
+			t.Fatalf("got frame of %v bytes %+v, want %v bytes", len(got), err, len(frame))
+		}
+		empty := reflect.New(reflect.TypeOf(m).Elem()).Interface().(Message)
+		if err := ExtendedCodec.Unmarshal(frame, empty); err != nil || !reflect.DeepEqual(empty, m) {
+			t.Errorf("%T doesn't round trip: %+v", m, err)
+		}
+		if err := DefaultCodec.Unmarshal(frame, empty); err == nil {
+			t.Errorf("%T with a two byte length was unmarshaled with a one byte length", m)
+		}
+	}
+	if ExtendedCodec.MaxData() != MaxExtendedData || DefaultCodec.MaxData() != MaxData {
+		t.Errorf("got max data %v and %v", ExtendedCodec.MaxData(), DefaultCodec.MaxData())
+	}
+}
@@ -0,0 +1,73 @@
+package gerte
+
+import "context"
+
+type (
+	// ReceiveFunc processes an inbound Packet.
+	// It returns the Packet to deliver and false if the Packet has to be dropped.
+	ReceiveFunc func(pkt Packet) (Packet, bool)
+
+	// InboundMiddleware wraps the processing of inbound packets.
+	// It can inspect or modify a Packet before or after calling next, or drop it by returning false without calling next.
+	InboundMiddleware func(next ReceiveFunc) ReceiveFunc
+
+	// TransmitFunc sends a Packet like Api.TransmitContext.
+	// It returns a bool whether the operation was successful and any encountered errors.
+	TransmitFunc func(ctx context.Context, pkt Packet) (bool, error)
+
+	// OutboundMiddleware wraps the transmission of packets.
+	// It can inspect or modify a Packet before calling next, or short-circuit the transmission by returning without calling next.
+	OutboundMiddleware func(next TransmitFunc) TransmitFunc
+)
+
+// ChainInbound composes middleware into a single InboundMiddleware, the first one is the outermost
+func ChainInbound(middleware ...InboundMiddleware) InboundMiddleware {
+	return func(next ReceiveFunc) ReceiveFunc {
+		for i := len(middleware) - 1; i >= 0; i-- {
+			next = middleware[i](next)
+		}
+		return next
+	}
+}
+
+// ChainOutbound composes middleware into a single OutboundMiddleware, the first one is the outermost
+func ChainOutbound(middleware ...OutboundMiddleware) OutboundMiddleware {
+	return func(next TransmitFunc) TransmitFunc {
+		for i := len(middleware) - 1; i >= 0; i-- {
+			next = middleware[i](next)
+		}
+		return next
+	}
+}
+
+// UseInbound appends middleware to the processing of inbound packets.
+// It applies to the packets returned by Parse and to the packets delivered by the receive loop,
+// packets dropped by the middleware are skipped.
+// Middleware added first is the outermost.
+func (api *Api) UseInbound(middleware ...InboundMiddleware) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.inbound = append(api.inbound[:len(api.inbound):len(api.inbound)], middleware...)
+}
+
+// UseOutbound appends middleware to the transmission of packets with Transmit and TransmitContext.
+// Middleware added first is the outermost.
+func (api *Api) UseOutbound(middleware ...OutboundMiddleware) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.outbound = append(api.outbound[:len(api.outbound):len(api.outbound)], middleware...)
+}
+
+// receivePacket runs pkt through the inbound middleware.
+// It returns the Packet to deliver and false if it was dropped.
+func (api *Api) receivePacket(pkt Packet) (Packet, bool) {
+	api.mu.Lock()
+	inbound := api.inbound
+	api.mu.Unlock()
+	if len(inbound) == 0 {
+		return pkt, true
+	}
+	return ChainInbound(inbound...)(func(pkt Packet) (Packet, bool) {
+		return pkt, true
+	})(pkt)
+}
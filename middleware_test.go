@@ -0,0 +1,137 @@
+package gerte_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/OmegaRogue/gerte-go"
+)
+
+// tag returns inbound middleware appending name to the data of every packet after calling next
+func tag(name string) gerte.InboundMiddleware {
+	return func(next gerte.ReceiveFunc) gerte.ReceiveFunc {
+		return func(pkt gerte.Packet) (gerte.Packet, bool) {
+			pkt.Data = append(append([]byte{}, pkt.Data...), name...)
+			return next(pkt)
+		}
+	}
+}
+
+// dropData returns inbound middleware dropping packets containing data
+func dropData(data string) gerte.InboundMiddleware {
+	return func(next gerte.ReceiveFunc) gerte.ReceiveFunc {
+		return func(pkt gerte.Packet) (gerte.Packet, bool) {
+			if bytes.Contains(pkt.Data, []byte(data)) {
+				return gerte.Packet{}, false
+			}
+			return next(pkt)
+		}
+	}
+}
+
+func TestChainInbound(t *testing.T) {
+	chain := gerte.ChainInbound(tag("a"), tag("b"), tag("c"))
+	pkt, ok := chain(func(pkt gerte.Packet) (gerte.Packet, bool) {
+		return pkt, true
+	})(gerte.Packet{Data: []byte("-")})
+	if !ok || string(pkt.Data) != "-abc" {
+		t.Errorf("got %q %v, want %q true", pkt.Data, ok, "-abc")
+	}
+}
+
+func TestApi_UseInbound(t *testing.T) {
+	addrA := gerte.GertAddress{Upper: 1123, Lower: 1456}
+	addrB := gerte.GertAddress{Upper: 2345, Lower: 1456}
+	srv := startRelay(t, addrA, addrB)
+	apiA := registerApi(t, srv, addrA)
+	defer apiA.Shutdown()
+	target := gerte.GERTc{GERTe: addrB}
+	send := func(data ...string) {
+		for _, d := range data {
+			if _, err := apiA.Transmit(gerte.Packet{Target: target, Data: []byte(d)}); err != nil {
+				t.Errorf("error on transmit: %+v", err)
+			}
+		}
+	}
+
+	t.Run("UseInboundParse", func(t *testing.T) {
+		apiB := registerApi(t, srv, addrB)
+		defer apiB.Shutdown()
+		apiB.UseInbound(dropData("drop"), tag("!"))
+		go send("drop me", "keep")
+		cmd, err := apiB.Parse()
+		if err != nil {
+			t.Fatalf("error on parse: %+v", err)
+		}
+		if string(cmd.Packet.Data) != "keep!" {
+			t.Errorf("got %q, want %q", cmd.Packet.Data, "keep!")
+		}
+	})
+	t.Run("UseInboundReceive", func(t *testing.T) {
+		apiB := registerApi(t, srv, addrB)
+		defer apiB.Shutdown()
+		apiB.UseInbound(dropData("drop"))
+		apiB.UseInbound(tag("!"))
+		if err := apiB.Receive(nil); err != nil {
+			t.Fatalf("error on receive: %+v", err)
+		}
+		send("drop me", "keep")
+		pkt := <-apiB.Packets()
+		if string(pkt.Data) != "keep!" {
+			t.Errorf("got %q, want %q", pkt.Data, "keep!")
+		}
+	})
+}
+
+func TestApi_UseOutbound(t *testing.T) {
+	addrA := gerte.GertAddress{Upper: 1123, Lower: 1456}
+	addrB := gerte.GertAddress{Upper: 2345, Lower: 1456}
+	srv := startRelay(t, addrA, addrB)
+	apiA := registerApi(t, srv, addrA)
+	defer apiA.Shutdown()
+	apiB := registerApi(t, srv, addrB)
+	defer apiB.Shutdown()
+	if err := apiB.Receive(nil); err != nil {
+		t.Fatalf("error on receive: %+v", err)
+	}
+
+	errBlocked := errors.New("blocked")
+	var sent []string
+	apiA.UseOutbound(func(next gerte.TransmitFunc) gerte.TransmitFunc {
+		return func(ctx context.Context, pkt gerte.Packet) (bool, error) {
+			ok, err := next(ctx, pkt)
+			if ok {
+				sent = append(sent, string(pkt.Data))
+			}
+			return ok, err
+		}
+	}, func(next gerte.TransmitFunc) gerte.TransmitFunc {
+		return func(ctx context.Context, pkt gerte.Packet) (bool, error) {
+			if string(pkt.Data) == "secret" {
+				return false, errBlocked
+			}
+			pkt.Data = bytes.ToUpper(pkt.Data)
+			return next(ctx, pkt)
+		}
+	})
+
+	target := gerte.GERTc{GERTe: addrB}
+	if _, err := apiA.Transmit(gerte.Packet{Target: target, Data: []byte("secret")}); err != errBlocked {
+		t.Errorf("got %+v, want %+v", err, errBlocked)
+	}
+	ok, err := apiA.Transmit(gerte.Packet{Target: target, Data: []byte("hello")})
+	if err != nil || !ok {
+		t.Fatalf("error on transmit: %v %+v", ok, err)
+	}
+	pkt := <-apiB.Packets()
+	if string(pkt.Data) != "HELLO" {
+		t.Errorf("got %q, want %q", pkt.Data, "HELLO")
+	}
+	// the outer middleware sees the packet before the inner one modified it
+	if !reflect.DeepEqual(sent, []string{"hello"}) {
+		t.Errorf("got %q, want %q", sent, []string{"hello"})
+	}
+}
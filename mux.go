@@ -0,0 +1,144 @@
+package gerte
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+type (
+	// Handler responds to an inbound Packet
+	Handler interface {
+		ServePacket(w ResponseWriter, pkt Packet)
+	}
+
+	// HandlerFunc adapts an ordinary function to a Handler
+	HandlerFunc func(w ResponseWriter, pkt Packet)
+
+	// ResponseWriter replies to the source of an inbound Packet
+	ResponseWriter interface {
+		// Write transmits data as a single packet from the target of the inbound Packet back to its source.
+		// It returns the number of bytes sent and any encountered errors.
+		Write(data []byte) (int, error)
+		// WriteContext is Write with a context controlling the transmission
+		WriteContext(ctx context.Context, data []byte) (int, error)
+		// LocalAddr returns the GERTc the inbound Packet was sent to
+		LocalAddr() GERTc
+		// RemoteAddr returns the GERTc the inbound Packet was sent from
+		RemoteAddr() GERTc
+	}
+
+	// ServeMux dispatches inbound packets to the Handler registered for their target GERTc.
+	// Patterns are GERTc prefixes in the text form of GERTcPrefix: an exact GERTc like "1123.1456:0001.0001",
+	// every GERTi address of a GERTe gateway like "1123.1456:*", a block of gateways like "1123.*",
+	// a CIDR-like prefix like "1123.1456:0000.0000/30" or every packet with the wildcard "*".
+	// The longest pattern containing the target takes precedence, so exact patterns win over gateway patterns and the wildcard.
+	ServeMux struct {
+		// mu makes checking and adding a registration atomic, routes is safe for concurrent lookups on its own
+		mu     sync.Mutex
+		routes RoutingTable
+	}
+
+	// response is the ResponseWriter passed to handlers by Serve
+	response struct {
+		api *Api
+		pkt Packet
+	}
+)
+
+// ServePacket calls f(w, pkt)
+func (f HandlerFunc) ServePacket(w ResponseWriter, pkt Packet) {
+	f(w, pkt)
+}
+
+// NewServeMux is the constructor for ServeMux
+func NewServeMux() *ServeMux {
+	return new(ServeMux)
+}
+
+// Handle registers the handler for the given pattern.
+// It panics if the pattern is invalid or already registered, like http.ServeMux.Handle.
+func (mux *ServeMux) Handle(pattern string, handler Handler) {
+	if handler == nil {
+		panic("gerte: nil handler")
+	}
+	prefix, err := ParseGERTcPrefix(pattern)
+	if err != nil {
+		panic(fmt.Sprintf("gerte: invalid pattern %q: %v", pattern, err))
+	}
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	if _, ok := mux.routes.Get(prefix); ok {
+		panic(fmt.Sprintf("gerte: multiple registrations for %v", prefix))
+	}
+	mux.routes.Insert(prefix, handler)
+}
+
+// HandleFunc registers the handler function for the given pattern
+func (mux *ServeMux) HandleFunc(pattern string, handler func(w ResponseWriter, pkt Packet)) {
+	if handler == nil {
+		panic("gerte: nil handler")
+	}
+	mux.Handle(pattern, HandlerFunc(handler))
+}
+
+// Handler returns the Handler to use for pkt.
+// It returns nil if no pattern matches the target of pkt.
+func (mux *ServeMux) Handler(pkt Packet) Handler {
+	_, h, ok := mux.routes.Lookup(pkt.Target)
+	if !ok {
+		return nil
+	}
+	return h.(Handler)
+}
+
+// ServePacket dispatches pkt to the Handler registered for its target, it is dropped if none matches
+func (mux *ServeMux) ServePacket(w ResponseWriter, pkt Packet) {
+	if h := mux.Handler(pkt); h != nil {
+		h.ServePacket(w, pkt)
+	}
+}
+
+// Serve starts the receive loop of a registered api and calls handler for every inbound packet.
+// It blocks until the receive loop stops and returns the error that stopped it, nil after Shutdown.
+// Every packet is handled in its own goroutine, so handlers may reply and packets may be handled out of order.
+func Serve(api *Api, handler Handler) error {
+	if handler == nil {
+		return fmt.Errorf("nil handler")
+	}
+	err := api.Receive(func(pkt Packet) {
+		go handler.ServePacket(&response{api: api, pkt: pkt}, pkt)
+	})
+	if err != nil {
+		return fmt.Errorf("error on start receive loop: %w", err)
+	}
+	<-api.Done()
+	return api.Err()
+}
+
+func (w *response) Write(data []byte) (int, error) {
+	return w.WriteContext(context.Background(), data)
+}
+
+func (w *response) WriteContext(ctx context.Context, data []byte) (int, error) {
+	ok, err := w.api.TransmitContext(ctx, Packet{
+		Source: w.pkt.Target,
+		Target: w.pkt.Source,
+		Data:   data,
+	})
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, fmt.Errorf("packet to %v was not sent", w.pkt.Source)
+	}
+	return len(data), nil
+}
+
+func (w *response) LocalAddr() GERTc {
+	return w.pkt.Target
+}
+
+func (w *response) RemoteAddr() GERTc {
+	return w.pkt.Source
+}
@@ -0,0 +1,138 @@
+package gerte_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/OmegaRogue/gerte-go"
+)
+
+// reply returns a handler answering every packet with name
+func reply(name string) gerte.HandlerFunc {
+	return func(w gerte.ResponseWriter, pkt gerte.Packet) {
+		w.Write([]byte(name))
+	}
+}
+
+func TestServeMux(t *testing.T) {
+	mux := gerte.NewServeMux()
+	mux.Handle("1123.1456:0001.0001", reply("exact"))
+	mux.Handle("1123.1456:*", reply("gateway"))
+	mux.Handle("1123.*", reply("block"))
+	mux.HandleFunc("*", reply("wildcard"))
+
+	tests := []struct {
+		name   string
+		target gerte.GERTc
+		want   string
+	}{
+		{"MuxExact", gerte.GERTc{GERTe: gerte.GertAddress{Upper: 1123, Lower: 1456}, GERTi: gerte.GertAddress{Upper: 1, Lower: 1}}, "exact"},
+		{"MuxGateway", gerte.GERTc{GERTe: gerte.GertAddress{Upper: 1123, Lower: 1456}, GERTi: gerte.GertAddress{Upper: 2, Lower: 2}}, "gateway"},
+		{"MuxBlock", gerte.GERTc{GERTe: gerte.GertAddress{Upper: 1123, Lower: 1}}, "block"},
+		{"MuxWildcard", gerte.GERTc{GERTe: gerte.GertAddress{Upper: 2345, Lower: 1456}}, "wildcard"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &recorder{}
+			mux.ServePacket(w, gerte.Packet{Target: tt.target})
+			if w.buf.String() != tt.want {
+				t.Errorf("got %q, want %q", w.buf.String(), tt.want)
+			}
+		})
+	}
+
+	t.Run("MuxNoMatch", func(t *testing.T) {
+		mux := gerte.NewServeMux()
+		mux.Handle("1123.1456:0001.0001", reply("exact"))
+		if h := mux.Handler(gerte.Packet{}); h != nil {
+			t.Errorf("unexpected handler %v", h)
+		}
+	})
+	for name, pattern := range map[string]string{
+		"MuxInvalid":   "1123",
+		"MuxInvalidGW": "1123:*",
+		"MuxDuplicate": "1123.1456:*",
+		"MuxBlockDup":  "1123.0000/12",
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("pattern %q was accepted", pattern)
+				}
+			}()
+			mux.Handle(pattern, reply(name))
+		})
+	}
+}
+
+// recorder is a ResponseWriter recording the written data
+type recorder struct {
+	buf bytes.Buffer
+}
+
+func (w *recorder) Write(data []byte) (int, error) {
+	return w.buf.Write(data)
+}
+
+func (w *recorder) WriteContext(_ context.Context, data []byte) (int, error) {
+	return w.buf.Write(data)
+}
+
+func (w *recorder) LocalAddr() gerte.GERTc {
+	return gerte.GERTc{}
+}
+
+func (w *recorder) RemoteAddr() gerte.GERTc {
+	return gerte.GERTc{}
+}
+
+func TestServe(t *testing.T) {
+	addrA := gerte.GertAddress{Upper: 1123, Lower: 1456}
+	addrB := gerte.GertAddress{Upper: 2345, Lower: 1456}
+	srv := startRelay(t, addrA, addrB)
+
+	mux := gerte.NewServeMux()
+	mux.Handle("2345.1456:0001.0001", reply("one"))
+	mux.HandleFunc("2345.1456:*", func(w gerte.ResponseWriter, pkt gerte.Packet) {
+		w.Write(append([]byte("echo "), pkt.Data...))
+	})
+	apiB := registerApi(t, srv, addrB)
+	served := make(chan error, 1)
+	go func() {
+		served <- gerte.Serve(apiB, mux)
+	}()
+
+	peerA, err := gerte.NewPacketConn(registerApi(t, srv, addrA), gerte.GertAddress{Upper: 5, Lower: 5})
+	if err != nil {
+		t.Fatalf("error on create packet conn: %+v", err)
+	}
+	defer peerA.Close()
+
+	buf := make([]byte, 255)
+	for _, tt := range []struct {
+		target gerte.GERTc
+		want   string
+	}{
+		{gerte.GERTc{GERTe: addrB, GERTi: gerte.GertAddress{Upper: 1, Lower: 1}}, "one"},
+		{gerte.GERTc{GERTe: addrB, GERTi: gerte.GertAddress{Upper: 2, Lower: 2}}, "echo ping"},
+	} {
+		if _, err := peerA.WriteTo([]byte("ping"), tt.target); err != nil {
+			t.Fatalf("error on write: %+v", err)
+		}
+		n, from, err := peerA.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("error on read: %+v", err)
+		}
+		if string(buf[:n]) != tt.want || from != tt.target {
+			t.Errorf("got %q from %v, want %q from %v", buf[:n], from, tt.want, tt.target)
+		}
+	}
+
+	if err := apiB.Shutdown(); err != nil {
+		t.Fatalf("error on shutdown: %+v", err)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("error on serve: %+v", err)
+	}
+}
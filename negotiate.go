@@ -0,0 +1,92 @@
+package gerte
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// VersionPolicy restricts the protocol versions an Api negotiates with a relay.
+// Min and Max are inclusive, the Patch is ignored because it is never sent.
+// The zero VersionPolicy accepts no version besides the one the Api requests.
+type VersionPolicy struct {
+	Min Version
+	Max Version
+}
+
+// Accepts returns whether ver lies between Min and Max
+func (p VersionPolicy) Accepts(ver Version) bool {
+	ver = ver.wire()
+	return !ver.Less(p.Min.wire()) && !p.Max.wire().Less(ver)
+}
+
+// Versions returns the versions accepted by the policy that have a registered FrameCodec, newest first
+func (p VersionPolicy) Versions() []Version {
+	var versions []Version
+	for _, ver := range SupportedVersions() {
+		if p.Accepts(ver) {
+			versions = append(versions, ver)
+		}
+	}
+	return versions
+}
+
+// String prints a VersionPolicy to a Human-readable string
+func (p VersionPolicy) String() string {
+	return fmt.Sprintf("%v-%v", p.Min.wire(), p.Max.wire())
+}
+
+// accepts returns whether the relay may answer a request for requested with ver.
+// A relay can choose an older version accepted by p, but never a newer one or one without a FrameCodec.
+// Without a policy only requested is accepted.
+func (p VersionPolicy) accepts(requested, ver Version) error {
+	if requested.wire().Less(ver.wire()) {
+		return fmt.Errorf("%w: relay negotiated %v, newer than the requested %v", ErrVersion, ver.wire(), requested.wire())
+	}
+	if p == (VersionPolicy{}) && ver.wire() != requested.wire() {
+		return fmt.Errorf("%w: relay negotiated %v instead of the requested %v", ErrVersion, ver.wire(), requested.wire())
+	}
+	if p != (VersionPolicy{}) && !p.Accepts(ver) {
+		return fmt.Errorf("%w: relay negotiated %v, outside of %v", ErrVersion, ver.wire(), p)
+	}
+	if _, err := CodecFor(ver); err != nil {
+		return fmt.Errorf("relay negotiated unsupported version: %w", err)
+	}
+	return nil
+}
+
+// Negotiate connects to the relay with the newest version of Policy and falls back to older ones.
+// It returns any encountered errors, wrapping ErrVersion if the relay rejected every version.
+// Relays close the connection after rejecting a version, so every attempt uses a new connection opened by dial.
+// Without a Policy only Version is requested, falling back to DefaultVersion if Version is ExtendedVersion.
+// Errors other than a rejected version are returned immediately.
+func (api *Api) Negotiate(ctx context.Context, dial func(ctx context.Context) (net.Conn, error)) error {
+	versions := []Version{api.Version}
+	if api.Version.wire() == ExtendedVersion.wire() {
+		versions = append(versions, DefaultVersion)
+	}
+	if api.Policy != (VersionPolicy{}) {
+		versions = api.Policy.Versions()
+		if len(versions) == 0 {
+			return fmt.Errorf("%w: no supported version in %v", ErrVersion, api.Policy)
+		}
+	}
+	var err error
+	for _, ver := range versions {
+		c, dialErr := dial(ctx)
+		if dialErr != nil {
+			return fmt.Errorf("error on dial: %w", dialErr)
+		}
+		api.Version = ver
+		err = api.StartupContext(ctx, c)
+		if err == nil {
+			return nil
+		}
+		c.Close()
+		if !errors.Is(err, ErrVersion) {
+			return err
+		}
+	}
+	return err
+}
@@ -0,0 +1,136 @@
+package gerte_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/OmegaRogue/gerte-go"
+	"github.com/OmegaRogue/gerte-go/geds"
+)
+
+// pipeDialer dials srv over net.Pipe and counts the connections
+func pipeDialer(srv *geds.Server, dials *int) func(context.Context) (net.Conn, error) {
+	return func(context.Context) (net.Conn, error) {
+		*dials++
+		server, client := net.Pipe()
+		go srv.ServeConn(server)
+		return client, nil
+	}
+}
+
+func TestApi_Negotiate(t *testing.T) {
+	addr := gerte.GertAddress{Upper: 1123, Lower: 1456}
+
+	t.Run("Fallback", func(t *testing.T) {
+		newer := gerte.Version{Major: 1, Minor: 9}
+		gerte.RegisterCodec(newer, gerte.DefaultCodec)
+		defer gerte.RegisterCodec(newer, nil)
+		srv := startRelay(t, addr)
+
+		api := gerte.NewApi(testVersion)
+		api.Policy = gerte.VersionPolicy{Min: gerte.Version{Major: 1, Minor: 0}, Max: newer}
+		dials := 0
+		if err := api.Negotiate(context.Background(), pipeDialer(srv, &dials)); err != nil {
+			t.Fatalf("error on negotiate: %+v", err)
+		}
+		defer api.Shutdown()
+		// the relay rejects newer and ExtendedVersion before accepting its own version
+		if api.Version != testVersion || dials != 3 {
+			t.Errorf("negotiated %v after %v dials, want %v after 3", api.Version, dials, testVersion)
+		}
+		if _, err := api.Register(addr, testKey); err != nil {
+			t.Errorf("error on register: %+v", err)
+		}
+	})
+	t.Run("Extended", func(t *testing.T) {
+		addrB := gerte.GertAddress{Upper: 2345, Lower: 1456}
+		addrC := gerte.GertAddress{Upper: 3456, Lower: 1456}
+		srv := geds.NewServer(gerte.ExtendedVersion, geds.Resolutions{addr: testKey, addrB: testKey, addrC: testKey})
+		defer srv.Close()
+		policy := gerte.VersionPolicy{Min: testVersion, Max: gerte.ExtendedVersion}
+		connect := func(addr gerte.GertAddress, policy gerte.VersionPolicy) *gerte.Api {
+			api := gerte.NewApi(testVersion)
+			api.Policy = policy
+			dials := 0
+			if err := api.Negotiate(context.Background(), pipeDialer(srv, &dials)); err != nil {
+				t.Fatalf("error on negotiate: %+v", err)
+			}
+			if _, err := api.Register(addr, testKey); err != nil {
+				t.Fatalf("error on register: %+v", err)
+			}
+			return api
+		}
+		sender := connect(addr, policy)
+		defer sender.Shutdown()
+		receiver := connect(addrB, policy)
+		defer receiver.Shutdown()
+		legacy := connect(addrC, gerte.VersionPolicy{})
+		defer legacy.Shutdown()
+		if sender.Version != gerte.ExtendedVersion || sender.MaxData() != gerte.MaxExtendedData || legacy.MaxData() != gerte.MaxData {
+			t.Fatalf("negotiated %v with %v bytes of data", sender.Version, sender.MaxData())
+		}
+		if err := receiver.Receive(nil); err != nil {
+			t.Fatalf("error on receive: %+v", err)
+		}
+
+		pkt := gerte.Packet{
+			Source: gerte.GERTc{GERTe: addr, GERTi: gerte.GertAddress{Upper: 1, Lower: 1}},
+			Target: gerte.GERTc{GERTe: addrB, GERTi: gerte.GertAddress{Upper: 2, Lower: 2}},
+			Data:   bytes.Repeat([]byte("x"), 1000),
+		}
+		if ok, err := sender.Transmit(pkt); !ok || err != nil {
+			t.Fatalf("error on transmit: %+v", err)
+		}
+		if got := <-receiver.Packets(); !bytes.Equal(got.Data, pkt.Data) {
+			t.Errorf("got %v bytes, want %v", len(got.Data), len(pkt.Data))
+		}
+
+		pkt.Target.GERTe = addrC
+		if _, err := sender.Transmit(pkt); !errors.Is(err, gerte.ErrNoRoute) {
+			t.Errorf("got %+v, want %+v for a target without the extension", err, gerte.ErrNoRoute)
+		}
+		if _, err := legacy.Transmit(pkt); err == nil {
+			t.Error("packet exceeding MaxData was sent without the extension")
+		}
+	})
+	t.Run("NoVersion", func(t *testing.T) {
+		api := gerte.NewApi(testVersion)
+		api.Policy = gerte.VersionPolicy{Min: gerte.Version{Major: 2, Minor: 0}, Max: gerte.Version{Major: 2, Minor: 9}}
+		dials := 0
+		err := api.Negotiate(context.Background(), pipeDialer(startRelay(t), &dials))
+		if !errors.Is(err, gerte.ErrVersion) || dials != 0 {
+			t.Errorf("got %+v after %v dials, want %+v without dialing", err, dials, gerte.ErrVersion)
+		}
+	})
+	t.Run("Rejected", func(t *testing.T) {
+		api := gerte.NewApi(gerte.Version{Major: 2, Minor: 0})
+		dials := 0
+		err := api.Negotiate(context.Background(), pipeDialer(startRelay(t), &dials))
+		if !errors.Is(err, gerte.ErrVersion) || dials != 1 {
+			t.Errorf("got %+v after %v dials, want %+v after 1", err, dials, gerte.ErrVersion)
+		}
+		if want := "incompatible version during negotiation: relay rejected 2.0.0"; err == nil || err.Error() != want {
+			t.Errorf("got message %q, want %q", err, want)
+		}
+		if api.SessionState() != gerte.SessionFailed {
+			t.Errorf("got session state %v, want %v", api.SessionState(), gerte.SessionFailed)
+		}
+	})
+	t.Run("NewerReply", func(t *testing.T) {
+		server, client := net.Pipe()
+		defer server.Close()
+		go func() {
+			dat := make([]byte, 2)
+			if _, err := server.Read(dat); err == nil {
+				server.Write([]byte{byte(gerte.CommandState), byte(gerte.StateConnected), 1, 2})
+			}
+		}()
+		api := gerte.NewApi(testVersion)
+		if err := api.Startup(client); !errors.Is(err, gerte.ErrVersion) {
+			t.Errorf("got %+v, want %+v", err, gerte.ErrVersion)
+		}
+	})
+}
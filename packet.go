@@ -11,22 +11,32 @@ type Packet struct {
 	Data   []byte
 }
 
-// PacketFromBytes parses bytes to a GERT Packet
+// PacketFromBytes parses bytes to a GERT Packet.
+// It returns the Packet and any encountered errors.
+// data holds the source and target GERTc, the length of the payload and the payload, bytes after the payload are ignored.
 func PacketFromBytes(data []byte) (Packet, error) {
-	source := GertCFromBytes(data[:6])
-	target := GertCFromBytes(data[6:12])
+	if err := checkLength(data, 13, "packet header"); err != nil {
+		return Packet{}, err
+	}
+	length := int(data[12])
+	if err := checkLength(data[13:], length, "packet data"); err != nil {
+		return Packet{}, err
+	}
+	source, _ := GertCFromBytes(data[:6])
+	target, _ := GertCFromBytes(data[6:12])
 
 	return Packet{
 		Source: source,
 		Target: target,
-		Data:   data[13:],
+		Data:   data[13 : 13+length],
 	}, nil
 }
 
-// ToBytes converts a Packet to bytes for sending
+// ToBytes converts a Packet to bytes for sending, the layout of OutboundData without the command byte.
+// It uses the one byte length of the versions before ExtendedVersion, so the Data cannot exceed MaxData bytes.
 func (pkt Packet) ToBytes() ([]byte, error) {
-	if len(pkt.Data) > 255 {
-		return nil, fmt.Errorf("data cannot exceed 255 bytes")
+	if len(pkt.Data) > MaxData {
+		return nil, fmt.Errorf("data cannot exceed %v bytes", MaxData)
 	}
 	addressPart := append(pkt.Target.ToBytes(), pkt.Source.GERTi.ToBytes()...)
 	dataPart := append([]byte{byte(len(pkt.Data))}, pkt.Data...)
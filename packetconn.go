@@ -0,0 +1,206 @@
+package gerte
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/OmegaRogue/gerte-go/internal/deadline"
+)
+
+// PacketConn adapts a registered Api to net.PacketConn.
+// Peers are addressed by their GERTc, writes become Transmit calls and reads return the inbound packets sent to the local GERTi address.
+type PacketConn struct {
+	api   *Api
+	local GERTc
+	in    chan Packet
+
+	readDeadline  *deadline.Deadline
+	writeDeadline *deadline.Deadline
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+var _ net.PacketConn = (*PacketConn)(nil)
+
+// packetConnBuffer is the number of unread packets a PacketConn holds before dropping new ones
+const packetConnBuffer = 64
+
+// NewPacketConn is the constructor for PacketConn, it takes over the receive loop of api.
+// It returns the PacketConn and any encountered errors.
+// The local address of the PacketConn is the registered address of api together with the GERTi address local,
+// inbound packets for other GERTi addresses are dropped.
+func NewPacketConn(api *Api, local GertAddress) (*PacketConn, error) {
+	addr, _ := api.Registration()
+	pc := &PacketConn{
+		api: api,
+		local: GERTc{
+			GERTe: addr,
+			GERTi: local,
+		},
+		in:            make(chan Packet, packetConnBuffer),
+		readDeadline:  deadline.New(),
+		writeDeadline: deadline.New(),
+		closed:        make(chan struct{}),
+	}
+	if err := api.Receive(pc.deliver); err != nil {
+		return nil, fmt.Errorf("error on start receive loop: %w", err)
+	}
+	return pc, nil
+}
+
+// deliver queues an inbound packet, it is dropped if nobody keeps up with reading like a datagram would be
+func (pc *PacketConn) deliver(pkt Packet) {
+	if pkt.Target.GERTi != pc.local.GERTi {
+		return
+	}
+	select {
+	case pc.in <- pkt:
+	default:
+	}
+}
+
+// ReadFrom reads the data of the next inbound packet into p.
+// It returns the number of bytes copied, the GERTc the packet was sent from and any encountered errors.
+// If p is too short for the data, the rest of the packet is discarded.
+func (pc *PacketConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	pkt, err := pc.ReadPacket()
+	if err != nil {
+		return 0, nil, err
+	}
+	return copy(p, pkt.Data), pkt.Source, nil
+}
+
+// ReadPacket waits for the next inbound packet.
+// It returns the Packet and any encountered errors.
+func (pc *PacketConn) ReadPacket() (Packet, error) {
+	select {
+	case pkt := <-pc.in:
+		return pkt, nil
+	default:
+	}
+	select {
+	case pkt := <-pc.in:
+		return pkt, nil
+	case <-pc.closed:
+		return Packet{}, pc.opError("read", nil, fmt.Errorf("use of closed connection"))
+	case <-pc.api.Done():
+		err := pc.api.Err()
+		if err == nil {
+			err = fmt.Errorf("connection closed")
+		}
+		return Packet{}, pc.opError("read", nil, err)
+	case <-pc.readDeadline.Wait():
+		return Packet{}, pc.opError("read", nil, deadline.ErrTimeout)
+	}
+}
+
+// WriteTo sends p to the GERTc addr.
+// It returns the number of bytes sent and any encountered errors.
+func (pc *PacketConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	var target GERTc
+	switch a := addr.(type) {
+	case GERTc:
+		target = a
+	case *GERTc:
+		target = *a
+	default:
+		return 0, pc.opError("write", addr, fmt.Errorf("invalid address type %T", addr))
+	}
+	select {
+	case <-pc.closed:
+		return 0, pc.opError("write", addr, fmt.Errorf("use of closed connection"))
+	default:
+	}
+
+	err := pc.api.transmitUntil(Packet{
+		Source: pc.local,
+		Target: target,
+		Data:   p,
+	}, pc.writeDeadline, pc.closed)
+	if err != nil {
+		return 0, pc.opError("write", addr, err)
+	}
+	return len(p), nil
+}
+
+// Close shuts down the underlying Api.
+// It returns any encountered errors.
+func (pc *PacketConn) Close() error {
+	err := fmt.Errorf("use of closed connection")
+	pc.closeOnce.Do(func() {
+		close(pc.closed)
+		err = pc.api.Shutdown()
+	})
+	return err
+}
+
+// LocalAddr returns the GERTc of the PacketConn
+func (pc *PacketConn) LocalAddr() net.Addr {
+	return pc.local
+}
+
+// SetDeadline sets the read and write deadlines
+func (pc *PacketConn) SetDeadline(t time.Time) error {
+	pc.readDeadline.Set(t)
+	pc.writeDeadline.Set(t)
+	return nil
+}
+
+// SetReadDeadline sets the deadline for ReadFrom and ReadPacket, the zero time disables it
+func (pc *PacketConn) SetReadDeadline(t time.Time) error {
+	pc.readDeadline.Set(t)
+	return nil
+}
+
+// SetWriteDeadline sets the deadline for WriteTo, the zero time disables it
+func (pc *PacketConn) SetWriteDeadline(t time.Time) error {
+	pc.writeDeadline.Set(t)
+	return nil
+}
+
+func (pc *PacketConn) opError(op string, addr net.Addr, err error) error {
+	return &net.OpError{
+		Op:     op,
+		Net:    Network,
+		Source: pc.local,
+		Addr:   addr,
+		Err:    err,
+	}
+}
+
+// transmitUntil transmits pkt until the deadline passes or closed is closed.
+// It returns any encountered errors, a net.Error with Timeout set if the deadline passed.
+// Deadlines set while the transmission is running apply to it, like net.Conn requires.
+func (api *Api) transmitUntil(pkt Packet, dl *deadline.Deadline, closed <-chan struct{}) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-dl.Wait():
+				// the deadline may have been moved while it passed, wait for the current one then
+				if dl.Expired() {
+					cancel()
+					return
+				}
+			case <-closed:
+				cancel()
+				return
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	_, err := api.TransmitContext(ctx, pkt)
+	if err != nil && ctx.Err() != nil && dl.Expired() {
+		return deadline.ErrTimeout
+	}
+	return err
+}
@@ -0,0 +1,161 @@
+package gerte_test
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/OmegaRogue/gerte-go"
+	"github.com/OmegaRogue/gerte-go/geds"
+)
+
+var (
+	testKey     = "aaaaaaaaaaaaaaaaaaaa"
+	testVersion = gerte.Version{Major: 1, Minor: 1}
+)
+
+// startRelay serves a geds relay for addrs until the test ends
+func startRelay(t *testing.T, addrs ...gerte.GertAddress) *geds.Server {
+	t.Helper()
+	res := make(geds.Resolutions)
+	for _, addr := range addrs {
+		res[addr] = testKey
+	}
+	srv := geds.NewServer(testVersion, res)
+	t.Cleanup(func() {
+		srv.Close()
+	})
+	return srv
+}
+
+// registerApi connects a new Api to srv and registers addr
+func registerApi(t *testing.T, srv *geds.Server, addr gerte.GertAddress) *gerte.Api {
+	t.Helper()
+	server, client := net.Pipe()
+	go srv.ServeConn(server)
+	api := gerte.NewApi(testVersion)
+	if err := api.Startup(client); err != nil {
+		t.Fatalf("error on startup: %+v", err)
+	}
+	if _, err := api.Register(addr, testKey); err != nil {
+		t.Fatalf("error on register: %+v", err)
+	}
+	return api
+}
+
+func TestPacketConn(t *testing.T) {
+	addrA := gerte.GertAddress{Upper: 1123, Lower: 1456}
+	addrB := gerte.GertAddress{Upper: 2345, Lower: 1456}
+	srv := startRelay(t, addrA, addrB)
+
+	connA, err := gerte.NewPacketConn(registerApi(t, srv, addrA), gerte.GertAddress{Upper: 1, Lower: 1})
+	if err != nil {
+		t.Fatalf("error on create packet conn: %+v", err)
+	}
+	defer connA.Close()
+	connB, err := gerte.NewPacketConn(registerApi(t, srv, addrB), gerte.GertAddress{Upper: 2, Lower: 2})
+	if err != nil {
+		t.Fatalf("error on create packet conn: %+v", err)
+	}
+	defer connB.Close()
+
+	var pc net.PacketConn = connA
+	if _, err := pc.WriteTo([]byte("ping"), connB.LocalAddr()); err != nil {
+		t.Fatalf("error on write: %+v", err)
+	}
+	buf := make([]byte, 255)
+	n, from, err := connB.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("error on read: %+v", err)
+	}
+	if string(buf[:n]) != "ping" || from != connA.LocalAddr() {
+		t.Errorf("unexpected packet from %v: %q", from, buf[:n])
+	}
+
+	if _, err := connB.WriteTo([]byte("pong"), from); err != nil {
+		t.Fatalf("error on write: %+v", err)
+	}
+	n, from, err = connA.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("error on read: %+v", err)
+	}
+	if string(buf[:n]) != "pong" || from != connB.LocalAddr() {
+		t.Errorf("unexpected packet from %v: %q", from, buf[:n])
+	}
+
+	if err := connA.SetReadDeadline(time.Now().Add(20 * time.Millisecond)); err != nil {
+		t.Fatalf("error on set deadline: %+v", err)
+	}
+	_, _, err = connA.ReadFrom(buf)
+	if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+		t.Errorf("expected timeout, got %+v", err)
+	}
+	if _, err := connA.WriteTo([]byte("ping"), addrB); err == nil {
+		t.Error("write to invalid address type succeeded")
+	}
+}
+
+// silentApi registers an Api with a relay that never answers after the registration
+func silentApi(t *testing.T) *gerte.Api {
+	t.Helper()
+	server, client := net.Pipe()
+	t.Cleanup(func() {
+		server.Close()
+	})
+	go func() {
+		dat := make([]byte, 1024)
+		if _, err := io.ReadFull(server, dat[:2]); err != nil {
+			return
+		}
+		server.Write([]byte{byte(gerte.CommandState), byte(gerte.StateConnected), 1, 1})
+		if _, err := io.ReadFull(server, dat[:4+gerte.KeySize]); err != nil {
+			return
+		}
+		server.Write([]byte{byte(gerte.CommandState), byte(gerte.StateAssigned)})
+		for {
+			if _, err := server.Read(dat); err != nil {
+				return
+			}
+		}
+	}()
+	api := gerte.NewApi(testVersion)
+	if err := api.Startup(client); err != nil {
+		t.Fatalf("error on startup: %+v", err)
+	}
+	if _, err := api.Register(gerte.GertAddress{Upper: 1123, Lower: 1456}, testKey); err != nil {
+		t.Fatalf("error on register: %+v", err)
+	}
+	return api
+}
+
+func TestPacketConn_WriteDeadline(t *testing.T) {
+	pc, err := gerte.NewPacketConn(silentApi(t), gerte.GertAddress{Upper: 1, Lower: 1})
+	if err != nil {
+		t.Fatalf("error on create packet conn: %+v", err)
+	}
+	target := gerte.GERTc{GERTe: gerte.GertAddress{Upper: 2345, Lower: 1456}}
+
+	// a deadline set while the write is blocked ends it
+	time.AfterFunc(20*time.Millisecond, func() {
+		pc.SetWriteDeadline(time.Now().Add(10 * time.Millisecond))
+	})
+	_, err = pc.WriteTo([]byte("ping"), target)
+	if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+		t.Errorf("expected timeout, got %+v", err)
+	}
+
+	// a deadline moved while the write is blocked replaces the earlier one
+	start := time.Now()
+	pc.SetWriteDeadline(start.Add(20 * time.Millisecond))
+	time.AfterFunc(10*time.Millisecond, func() {
+		pc.SetWriteDeadline(start.Add(100 * time.Millisecond))
+	})
+	_, err = pc.WriteTo([]byte("ping"), target)
+	if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+		t.Errorf("expected timeout, got %+v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("write timed out after %v, before the moved deadline", elapsed)
+	}
+}
@@ -0,0 +1,86 @@
+package gerte
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// DefaultMaxInFlight is the number of DATA commands TransmitAsync keeps in flight if MaxInFlight is not set
+const DefaultMaxInFlight = 32
+
+// Transmission is the pending result of a Packet sent with TransmitAsync or queued on a SendQueue
+type Transmission struct {
+	done chan struct{}
+	ok   bool
+	err  error
+}
+
+// TransmitAsync sends pkt without waiting for the reply of the relay, so several DATA commands can be in flight at once.
+// It returns the Transmission carrying the result and any errors encountered before the command was written.
+// Packets are written in the order TransmitAsync is called and the relay replies in the same order.
+// TransmitAsync waits while MaxInFlight commands are waiting for their replies.
+// The receive loop has to be running; ctx bounds the whole transmission like TransmitContext,
+// and the Packet passes the middleware added with UseOutbound.
+func (api *Api) TransmitAsync(ctx context.Context, pkt Packet) (*Transmission, error) {
+	api.mu.Lock()
+	receiving, done, inFlight, outbound := api.receiving, api.done, api.inFlight, api.outbound
+	api.mu.Unlock()
+	if !receiving {
+		return nil, fmt.Errorf("receive loop is not running")
+	}
+	select {
+	case inFlight <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-done:
+		return nil, fmt.Errorf("receive loop stopped")
+	}
+
+	t := &Transmission{done: make(chan struct{})}
+	written := make(chan struct{})
+	var once sync.Once
+	signal := func() {
+		once.Do(func() {
+			close(written)
+		})
+	}
+	send := TransmitFunc(func(ctx context.Context, pkt Packet) (bool, error) {
+		return api.transmitNotify(ctx, pkt, signal)
+	})
+	if len(outbound) > 0 {
+		send = ChainOutbound(outbound...)(send)
+	}
+	go func() {
+		ok, err := send(ctx, pkt)
+		// middleware may have answered without writing a command
+		signal()
+		<-inFlight
+		t.complete(ok, err)
+	}()
+	<-written
+	return t, nil
+}
+
+// Done returns a channel that is closed once the result of the Transmission is known
+func (t *Transmission) Done() <-chan struct{} {
+	return t.done
+}
+
+// Wait waits for the result of the Transmission.
+// It returns a bool whether the Packet was sent and any encountered errors, ctx.Err() if ctx ended first.
+// The Transmission itself is not canceled when ctx ends.
+func (t *Transmission) Wait(ctx context.Context) (bool, error) {
+	select {
+	case <-t.done:
+		return t.ok, t.err
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
+// complete records the result of the Transmission
+func (t *Transmission) complete(ok bool, err error) {
+	t.ok, t.err = ok, err
+	close(t.done)
+}
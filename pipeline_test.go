@@ -0,0 +1,216 @@
+package gerte_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/OmegaRogue/gerte-go"
+	"github.com/OmegaRogue/gerte-go/geds"
+)
+
+func TestApi_TransmitAsync(t *testing.T) {
+	addrA := gerte.GertAddress{Upper: 1123, Lower: 1456}
+	addrB := gerte.GertAddress{Upper: 2345, Lower: 1456}
+	srv := startRelay(t, addrA, addrB)
+	target := gerte.GERTc{GERTe: addrB}
+	unknown := gerte.GERTc{GERTe: gerte.GertAddress{Upper: 3456, Lower: 1456}}
+	ctx := context.Background()
+
+	apiB := registerApi(t, srv, addrB)
+	defer apiB.Shutdown()
+	if err := apiB.Receive(nil); err != nil {
+		t.Fatalf("error on receive: %+v", err)
+	}
+	apiA := registerApi(t, srv, addrA)
+	defer apiA.Shutdown()
+
+	t.Run("TransmitAsyncNotReceiving", func(t *testing.T) {
+		if _, err := apiA.TransmitAsync(ctx, gerte.Packet{Target: target}); err == nil {
+			t.Error("transmit without receive loop succeeded")
+		}
+	})
+
+	apiA.MaxInFlight = 4
+	if err := apiA.Receive(nil); err != nil {
+		t.Fatalf("error on receive: %+v", err)
+	}
+
+	t.Run("TransmitAsyncOrdered", func(t *testing.T) {
+		const count = 50
+		transmissions := make([]*gerte.Transmission, count)
+		for i := range transmissions {
+			to := target
+			if i%3 == 0 {
+				to = unknown
+			}
+			tr, err := apiA.TransmitAsync(ctx, gerte.Packet{Target: to, Data: []byte(fmt.Sprint(i))})
+			if err != nil {
+				t.Fatalf("error on transmit %v: %+v", i, err)
+			}
+			transmissions[i] = tr
+		}
+		for i, tr := range transmissions {
+			ok, err := tr.Wait(ctx)
+			if i%3 == 0 {
+				if ok || err == nil || !errors.Is(err, gerte.ErrNoRoute) {
+					t.Errorf("transmission %v to unknown gateway: got %v %v", i, ok, err)
+				}
+				continue
+			}
+			if !ok || err != nil {
+				t.Errorf("transmission %v: got %v %+v", i, ok, err)
+			}
+			pkt := <-apiB.Packets()
+			if string(pkt.Data) != fmt.Sprint(i) {
+				t.Errorf("got packet %q, want %q", pkt.Data, fmt.Sprint(i))
+			}
+		}
+	})
+	t.Run("TransmitAsyncMiddleware", func(t *testing.T) {
+		apiA.UseOutbound(func(next gerte.TransmitFunc) gerte.TransmitFunc {
+			return func(ctx context.Context, pkt gerte.Packet) (bool, error) {
+				if string(pkt.Data) == "skip" {
+					return false, nil
+				}
+				return next(ctx, pkt)
+			}
+		})
+		tr, err := apiA.TransmitAsync(ctx, gerte.Packet{Target: target, Data: []byte("skip")})
+		if err != nil {
+			t.Fatalf("error on transmit: %+v", err)
+		}
+		if ok, err := tr.Wait(ctx); ok || err != nil {
+			t.Errorf("got %v %+v, want false <nil>", ok, err)
+		}
+	})
+}
+
+// delayConn delays everything written to it by delay, while keeping later writes flowing like a link with latency
+type delayConn struct {
+	net.Conn
+	delay  time.Duration
+	writes chan delayedWrite
+
+	mu     sync.Mutex
+	closed bool
+}
+
+type delayedWrite struct {
+	at   time.Time
+	data []byte
+}
+
+func newDelayConn(c net.Conn, delay time.Duration) *delayConn {
+	dc := &delayConn{Conn: c, delay: delay, writes: make(chan delayedWrite, 1024)}
+	go func() {
+		for w := range dc.writes {
+			time.Sleep(time.Until(w.at))
+			if _, err := dc.Conn.Write(w.data); err != nil {
+				return
+			}
+		}
+	}()
+	return dc
+}
+
+func (dc *delayConn) Write(p []byte) (int, error) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	if dc.closed {
+		return 0, io.ErrClosedPipe
+	}
+	dc.writes <- delayedWrite{at: time.Now().Add(dc.delay), data: append([]byte{}, p...)}
+	return len(p), nil
+}
+
+func (dc *delayConn) Close() error {
+	dc.mu.Lock()
+	if !dc.closed {
+		dc.closed = true
+		close(dc.writes)
+	}
+	dc.mu.Unlock()
+	return dc.Conn.Close()
+}
+
+// benchmarkTransmit transmits b.N packets through a relay whose replies are delayed by latency
+func benchmarkTransmit(b *testing.B, latency time.Duration, async bool) {
+	addrA := gerte.GertAddress{Upper: 1123, Lower: 1456}
+	addrB := gerte.GertAddress{Upper: 2345, Lower: 1456}
+	srv := geds.NewServer(testVersion, geds.Resolutions{addrA: testKey, addrB: testKey})
+	defer srv.Close()
+	connect := func(addr gerte.GertAddress, delay time.Duration) *gerte.Api {
+		server, client := net.Pipe()
+		var relaySide net.Conn = server
+		if delay > 0 {
+			relaySide = newDelayConn(server, delay)
+		}
+		go srv.ServeConn(relaySide)
+		api := gerte.NewApi(testVersion)
+		if err := api.Startup(client); err != nil {
+			b.Fatalf("error on startup: %+v", err)
+		}
+		if _, err := api.Register(addr, testKey); err != nil {
+			b.Fatalf("error on register: %+v", err)
+		}
+		if err := api.Receive(func(gerte.Packet) {}); err != nil {
+			b.Fatalf("error on receive: %+v", err)
+		}
+		return api
+	}
+	apiB := connect(addrB, 0)
+	defer apiB.Shutdown()
+	apiA := connect(addrA, latency)
+	defer apiA.Shutdown()
+
+	ctx := context.Background()
+	pkt := gerte.Packet{Target: gerte.GERTc{GERTe: addrB}, Data: make([]byte, 64)}
+	b.ResetTimer()
+	if !async {
+		for i := 0; i < b.N; i++ {
+			if _, err := apiA.TransmitContext(ctx, pkt); err != nil {
+				b.Fatalf("error on transmit: %+v", err)
+			}
+		}
+		return
+	}
+	transmissions := make(chan *gerte.Transmission, gerte.DefaultMaxInFlight)
+	go func() {
+		defer close(transmissions)
+		for i := 0; i < b.N; i++ {
+			tr, err := apiA.TransmitAsync(ctx, pkt)
+			if err != nil {
+				b.Errorf("error on transmit: %+v", err)
+				return
+			}
+			transmissions <- tr
+		}
+	}()
+	for tr := range transmissions {
+		if _, err := tr.Wait(ctx); err != nil {
+			b.Fatalf("error on transmit: %+v", err)
+		}
+	}
+}
+
+func BenchmarkApi_Transmit(b *testing.B) {
+	benchmarkTransmit(b, 0, false)
+}
+
+func BenchmarkApi_TransmitAsync(b *testing.B) {
+	benchmarkTransmit(b, 0, true)
+}
+
+func BenchmarkApi_TransmitLatency(b *testing.B) {
+	benchmarkTransmit(b, time.Millisecond, false)
+}
+
+func BenchmarkApi_TransmitAsyncLatency(b *testing.B) {
+	benchmarkTransmit(b, time.Millisecond, true)
+}
@@ -0,0 +1,271 @@
+package gerte
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// AddressBits is the number of bits of a GertAddress, the Upper half comes first
+	AddressBits = 24
+	// GERTcBits is the number of bits of a GERTc, the GERTe address comes first
+	GERTcBits = 2 * AddressBits
+)
+
+type (
+	// AddressPrefix is a block of GERTe addresses sharing their first Bits bits, like an IP network in CIDR notation.
+	// Its text form is "*" for every address, "1123.*" for every address with the upper half 1123,
+	// "1123.1456" for a single address and "1123.1456/N" for any other number of bits.
+	AddressPrefix struct {
+		Addr GertAddress
+		Bits int
+	}
+
+	// GERTcPrefix is a block of GERTc addresses sharing their first Bits bits, the GERTe address comes first.
+	// Its text form is "*", "1123.*", "1123.1456:*" for every GERTi address behind a gateway, "1123.1456:0001.*",
+	// "1123.1456:0001.0001" for a single address and "1123.1456:0001.0001/N" for any other number of bits.
+	GERTcPrefix struct {
+		Addr GERTc
+		Bits int
+	}
+)
+
+// NewAddressPrefix is the validating constructor for AddressPrefix, the bits of addr after the prefix are cleared.
+// It returns the AddressPrefix and any encountered errors, bits has to be between 0 and AddressBits.
+func NewAddressPrefix(addr GertAddress, bits int) (AddressPrefix, error) {
+	if err := addr.Validate(); err != nil {
+		return AddressPrefix{}, err
+	}
+	if bits < 0 || bits > AddressBits {
+		return AddressPrefix{}, fmt.Errorf("%w: prefix length %v is not between 0 and %v", ErrAddressRange, bits, AddressBits)
+	}
+	return AddressPrefix{
+		Addr: addressFromKey(maskKey(addressKey(addr), bits, AddressBits)),
+		Bits: bits,
+	}, nil
+}
+
+// ParseAddressPrefix parses an AddressPrefix in one of the text forms String prints.
+// It returns the AddressPrefix and any encountered errors, wrapping ErrInvalidAddress or ErrAddressRange.
+func ParseAddressPrefix(s string) (AddressPrefix, error) {
+	addr, bits, err := splitPrefixLength(s)
+	if err != nil {
+		return AddressPrefix{}, err
+	}
+	switch {
+	case bits >= 0:
+	case addr == "*":
+		return AddressPrefix{}, nil
+	case strings.HasSuffix(addr, ".*"):
+		upper, err := parseAddressPart(strings.TrimSuffix(addr, ".*"))
+		if err != nil {
+			return AddressPrefix{}, fmt.Errorf("error on parse upper String: %w", err)
+		}
+		return AddressPrefix{Addr: GertAddress{Upper: upper}, Bits: AddressBits / 2}, nil
+	default:
+		bits = AddressBits
+	}
+	gertE, err := AddressFromString(addr)
+	if err != nil {
+		return AddressPrefix{}, err
+	}
+	return NewAddressPrefix(gertE, bits)
+}
+
+// Contains returns whether addr lies in the prefix
+func (p AddressPrefix) Contains(addr GertAddress) bool {
+	return addr.Validate() == nil && maskKey(addressKey(addr), p.Bits, AddressBits) == addressKey(p.Addr)
+}
+
+// ContainsPrefix returns whether every address of other lies in the prefix
+func (p AddressPrefix) ContainsPrefix(other AddressPrefix) bool {
+	return other.Bits >= p.Bits && p.Contains(other.Addr)
+}
+
+// GERTc returns the prefix of every GERTc whose GERTe address lies in the prefix
+func (p AddressPrefix) GERTc() GERTcPrefix {
+	return GERTcPrefix{Addr: GERTc{GERTe: p.Addr}, Bits: p.Bits}
+}
+
+// String prints an AddressPrefix in its shortest text form
+func (p AddressPrefix) String() string {
+	switch p.Bits {
+	case 0:
+		return "*"
+	case AddressBits / 2:
+		return fmt.Sprintf("%04v.*", p.Addr.Upper)
+	case AddressBits:
+		return p.Addr.String()
+	}
+	return fmt.Sprintf("%v/%v", p.Addr, p.Bits)
+}
+
+// MarshalText encodes the AddressPrefix like String.
+// It returns the text and any encountered errors.
+func (p AddressPrefix) MarshalText() ([]byte, error) {
+	if _, err := NewAddressPrefix(p.Addr, p.Bits); err != nil {
+		return nil, err
+	}
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText decodes an AddressPrefix like ParseAddressPrefix.
+// It returns any encountered errors.
+func (p *AddressPrefix) UnmarshalText(text []byte) error {
+	parsed, err := ParseAddressPrefix(string(text))
+	if err != nil {
+		return err
+	}
+	*p = parsed
+	return nil
+}
+
+// NewGERTcPrefix is the validating constructor for GERTcPrefix, the bits of addr after the prefix are cleared.
+// It returns the GERTcPrefix and any encountered errors, bits has to be between 0 and GERTcBits.
+func NewGERTcPrefix(addr GERTc, bits int) (GERTcPrefix, error) {
+	if err := addr.Validate(); err != nil {
+		return GERTcPrefix{}, err
+	}
+	if bits < 0 || bits > GERTcBits {
+		return GERTcPrefix{}, fmt.Errorf("%w: prefix length %v is not between 0 and %v", ErrAddressRange, bits, GERTcBits)
+	}
+	return GERTcPrefix{
+		Addr: gertcFromKey(maskKey(gertcKey(addr), bits, GERTcBits)),
+		Bits: bits,
+	}, nil
+}
+
+// ParseGERTcPrefix parses a GERTcPrefix in one of the text forms String prints.
+// GERTe prefixes without a GERTi part like "1123.1456" or "1123.1456/20" are parsed like ParseAddressPrefix,
+// they contain every GERTc behind the gateways.
+// It returns the GERTcPrefix and any encountered errors, wrapping ErrInvalidAddress or ErrAddressRange.
+func ParseGERTcPrefix(s string) (GERTcPrefix, error) {
+	addr, bits, err := splitPrefixLength(s)
+	if err != nil {
+		return GERTcPrefix{}, err
+	}
+	if !strings.Contains(addr, ":") {
+		gertE, err := ParseAddressPrefix(s)
+		if err != nil {
+			return GERTcPrefix{}, fmt.Errorf("error on parse GERTe prefix: %w", err)
+		}
+		return gertE.GERTc(), nil
+	}
+	if bits >= 0 {
+		gertC, err := GertCFromString(addr)
+		if err != nil {
+			return GERTcPrefix{}, err
+		}
+		return NewGERTcPrefix(gertC, bits)
+	}
+	parts := strings.Split(addr, ":")
+	if len(parts) != 2 {
+		return GERTcPrefix{}, fmt.Errorf("%w: %q is not a GERTc prefix", ErrInvalidAddress, s)
+	}
+	gertE, err := ParseAddressPrefix(parts[0])
+	if err != nil {
+		return GERTcPrefix{}, fmt.Errorf("error on parse GERTe prefix: %w", err)
+	}
+	if gertE.Bits != AddressBits {
+		return GERTcPrefix{}, fmt.Errorf("%w: %q has a GERTi part after a GERTe prefix", ErrInvalidAddress, s)
+	}
+	gertI, err := ParseAddressPrefix(parts[1])
+	if err != nil {
+		return GERTcPrefix{}, fmt.Errorf("error on parse GERTi prefix: %w", err)
+	}
+	return GERTcPrefix{
+		Addr: GERTc{GERTe: gertE.Addr, GERTi: gertI.Addr},
+		Bits: AddressBits + gertI.Bits,
+	}, nil
+}
+
+// Contains returns whether addr lies in the prefix
+func (p GERTcPrefix) Contains(addr GERTc) bool {
+	return addr.Validate() == nil && maskKey(gertcKey(addr), p.Bits, GERTcBits) == gertcKey(p.Addr)
+}
+
+// ContainsPrefix returns whether every address of other lies in the prefix
+func (p GERTcPrefix) ContainsPrefix(other GERTcPrefix) bool {
+	return other.Bits >= p.Bits && p.Contains(other.Addr)
+}
+
+// String prints a GERTcPrefix in its shortest text form
+func (p GERTcPrefix) String() string {
+	switch p.Bits {
+	case 0:
+		return "*"
+	case AddressBits / 2:
+		return fmt.Sprintf("%04v.*", p.Addr.GERTe.Upper)
+	case AddressBits:
+		return fmt.Sprintf("%v:*", p.Addr.GERTe)
+	case AddressBits + AddressBits/2:
+		return fmt.Sprintf("%v:%04v.*", p.Addr.GERTe, p.Addr.GERTi.Upper)
+	case GERTcBits:
+		return p.Addr.String()
+	}
+	return fmt.Sprintf("%v/%v", p.Addr, p.Bits)
+}
+
+// MarshalText encodes the GERTcPrefix like String.
+// It returns the text and any encountered errors.
+func (p GERTcPrefix) MarshalText() ([]byte, error) {
+	if _, err := NewGERTcPrefix(p.Addr, p.Bits); err != nil {
+		return nil, err
+	}
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText decodes a GERTcPrefix like ParseGERTcPrefix.
+// It returns any encountered errors.
+func (p *GERTcPrefix) UnmarshalText(text []byte) error {
+	parsed, err := ParseGERTcPrefix(string(text))
+	if err != nil {
+		return err
+	}
+	*p = parsed
+	return nil
+}
+
+// splitPrefixLength splits the "/N" suffix off s.
+// It returns the rest of s, N or -1 without a suffix and any encountered errors.
+func splitPrefixLength(s string) (string, int, error) {
+	i := strings.LastIndex(s, "/")
+	if i < 0 {
+		return s, -1, nil
+	}
+	bits, err := strconv.Atoi(s[i+1:])
+	if err != nil || strings.Trim(s[i+1:], "0123456789") != "" {
+		return "", 0, fmt.Errorf("%w: invalid prefix length in %q", ErrInvalidAddress, s)
+	}
+	return s[:i], bits, nil
+}
+
+// addressKey returns the 24 bits of a valid address as a number
+func addressKey(addr GertAddress) uint64 {
+	return uint64(addr.Upper)<<(AddressBits/2) | uint64(addr.Lower)
+}
+
+func addressFromKey(key uint64) GertAddress {
+	return GertAddress{
+		Upper: int(key >> (AddressBits / 2) & MaxAddressPart),
+		Lower: int(key & MaxAddressPart),
+	}
+}
+
+// gertcKey returns the 48 bits of a valid GERTc as a number
+func gertcKey(addr GERTc) uint64 {
+	return addressKey(addr.GERTe)<<AddressBits | addressKey(addr.GERTi)
+}
+
+func gertcFromKey(key uint64) GERTc {
+	return GERTc{
+		GERTe: addressFromKey(key >> AddressBits),
+		GERTi: addressFromKey(key),
+	}
+}
+
+// maskKey clears the bits of a key of width bits after the first bits
+func maskKey(key uint64, bits, width int) uint64 {
+	return key &^ (1<<uint(width-bits) - 1)
+}
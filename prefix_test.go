@@ -0,0 +1,131 @@
+package gerte
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseAddressPrefix(t *testing.T) {
+	tests := []struct {
+		in   string
+		want AddressPrefix
+		text string
+	}{
+		{"*", AddressPrefix{}, "*"},
+		{"1123.*", AddressPrefix{Addr: GertAddress{Upper: 1123}, Bits: 12}, "1123.*"},
+		{"1123.1456", AddressPrefix{Addr: GertAddress{Upper: 1123, Lower: 1456}, Bits: 24}, "1123.1456"},
+		{"1123.1456/16", AddressPrefix{Addr: GertAddress{Upper: 1123, Lower: 1280}, Bits: 16}, "1123.1280/16"},
+		{"1123.1456/12", AddressPrefix{Addr: GertAddress{Upper: 1123}, Bits: 12}, "1123.*"},
+		{"4095.4095/0", AddressPrefix{}, "*"},
+	}
+	for _, tt := range tests {
+		got, err := ParseAddressPrefix(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseAddressPrefix(%q): got %v %+v, want %v", tt.in, got, err, tt.want)
+		}
+		if got.String() != tt.text {
+			t.Errorf("got %q, want %q", got.String(), tt.text)
+		}
+	}
+	for in, want := range map[string]error{
+		"":             ErrInvalidAddress,
+		"1123":         ErrInvalidAddress,
+		"1123.*.*":     ErrInvalidAddress,
+		"*.1456":       ErrInvalidAddress,
+		"1123.1456/":   ErrInvalidAddress,
+		"1123.1456/-1": ErrInvalidAddress,
+		"1123.*/12":    ErrInvalidAddress,
+		"1123.1456/25": ErrAddressRange,
+		"4096.*":       ErrAddressRange,
+	} {
+		if _, err := ParseAddressPrefix(in); !errors.Is(err, want) {
+			t.Errorf("ParseAddressPrefix(%q): got %+v, want %+v", in, err, want)
+		}
+	}
+}
+
+func TestAddressPrefix_Contains(t *testing.T) {
+	block, _ := ParseAddressPrefix("1123.*")
+	cidr, _ := ParseAddressPrefix("1123.1456/20")
+	all, _ := ParseAddressPrefix("*")
+	inside := GertAddress{Upper: 1123, Lower: 1460}
+	outside := GertAddress{Upper: 1124, Lower: 1456}
+	if !block.Contains(inside) || !cidr.Contains(inside) || !all.Contains(outside) {
+		t.Error("prefix doesn't contain an address inside")
+	}
+	if block.Contains(outside) || cidr.Contains(GertAddress{Upper: 1123, Lower: 1472}) {
+		t.Error("prefix contains an address outside")
+	}
+	if block.Contains(GertAddress{Upper: 1123, Lower: 4096}) {
+		t.Error("prefix contains an invalid address")
+	}
+	if !all.ContainsPrefix(block) || !block.ContainsPrefix(cidr) || cidr.ContainsPrefix(block) {
+		t.Error("prefix containment is wrong")
+	}
+}
+
+func TestParseGERTcPrefix(t *testing.T) {
+	gateway := GertAddress{Upper: 1123, Lower: 1456}
+	tests := []struct {
+		in   string
+		want GERTcPrefix
+		text string
+	}{
+		{"*", GERTcPrefix{}, "*"},
+		{"1123.*", GERTcPrefix{Addr: GERTc{GERTe: GertAddress{Upper: 1123}}, Bits: 12}, "1123.*"},
+		{"1123.1456", GERTcPrefix{Addr: GERTc{GERTe: gateway}, Bits: 24}, "1123.1456:*"},
+		{"1123.1456:*", GERTcPrefix{Addr: GERTc{GERTe: gateway}, Bits: 24}, "1123.1456:*"},
+		{"1123.1456:0001.*", GERTcPrefix{Addr: GERTc{GERTe: gateway, GERTi: GertAddress{Upper: 1}}, Bits: 36}, "1123.1456:0001.*"},
+		{"1123.1456:0001.0001", GERTcPrefix{Addr: GERTc{GERTe: gateway, GERTi: GertAddress{Upper: 1, Lower: 1}}, Bits: 48}, "1123.1456:0001.0001"},
+		{"1123.1456:0001.0003/47", GERTcPrefix{Addr: GERTc{GERTe: gateway, GERTi: GertAddress{Upper: 1, Lower: 2}}, Bits: 47}, "1123.1456:0001.0002/47"},
+	}
+	for _, tt := range tests {
+		got, err := ParseGERTcPrefix(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseGERTcPrefix(%q): got %v %+v, want %v", tt.in, got, err, tt.want)
+		}
+		if got.String() != tt.text {
+			t.Errorf("got %q, want %q", got.String(), tt.text)
+		}
+		again, err := ParseGERTcPrefix(got.String())
+		if err != nil || again != got {
+			t.Errorf("%v doesn't round trip: %v %+v", got, again, err)
+		}
+	}
+	for _, in := range []string{"1123", "1123:*", "1123.*:*", "*:*", "1123.1456:0001.0001:0001.0001", "1123.1456/30", "1123.1456:0001.0001/49"} {
+		if _, err := ParseGERTcPrefix(in); err == nil {
+			t.Errorf("ParseGERTcPrefix(%q) was accepted", in)
+		}
+	}
+
+	prefix := GERTcPrefix{Addr: GERTc{GERTe: gateway}, Bits: 24}
+	if !prefix.Contains(GERTc{GERTe: gateway, GERTi: GertAddress{Upper: 7, Lower: 7}}) || prefix.Contains(GERTc{}) {
+		t.Error("GERTc prefix containment is wrong")
+	}
+}
+
+func TestPrefix_MarshalText(t *testing.T) {
+	type config struct {
+		Block  AddressPrefix
+		Routes []GERTcPrefix
+	}
+	in := config{
+		Block:  AddressPrefix{Addr: GertAddress{Upper: 1123}, Bits: 12},
+		Routes: []GERTcPrefix{{}, {Addr: GERTc{GERTe: GertAddress{Upper: 1123, Lower: 1456}}, Bits: 24}},
+	}
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatalf("error on marshal: %+v", err)
+	}
+	if want := `{"Block":"1123.*","Routes":["*","1123.1456:*"]}`; string(data) != want {
+		t.Errorf("got %s, want %s", data, want)
+	}
+	var out config
+	if err := json.Unmarshal(data, &out); err != nil || out.Block != in.Block || len(out.Routes) != 2 || out.Routes[1] != in.Routes[1] {
+		t.Errorf("got %+v %+v, want %+v", out, err, in)
+	}
+	if _, err := json.Marshal(AddressPrefix{Bits: 30}); !errors.Is(err, ErrAddressRange) {
+		t.Errorf("got %+v, want %+v", err, ErrAddressRange)
+	}
+}
@@ -1,33 +1,71 @@
 package gerte
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 )
 
-// PrettyPrint prints a GERT Message into a human readable string
+// PrettyPrint prints a GERT Message into a human readable string.
+// STATE commands are printed as the StateReply of a relay, the other commands with the layout gateways send,
+// so DATA commands are read as OutboundData with the one byte length of DefaultCodec.
+// Use PrettyPrintFrame for frames of other versions or DATA sent by a relay.
+// It returns the string and any encountered errors, messages that are too short return an error wrapping ErrTooShort.
 func PrettyPrint(data []byte) (string, error) {
+	if err := checkLength(data, 1, "command"); err != nil {
+		return "", err
+	}
+	return PrettyPrintFrame(data, DefaultCodec, data[0] != byte(CommandState))
+}
 
-	switch data[0] {
-	case byte(CommandState):
-		state, err := StatusFromBytes(data[1:])
-		if err != nil {
-			return "", fmt.Errorf("error while parsing status data: %+v", err)
-		}
-
-		return fmt.Sprintf("%#v%#v", CommandState, state), nil
-	case byte(CommandRegister):
-		addr := AddressFromBytes(data[1:4])
-		key := string(data[4:24])
-		return fmt.Sprintf("%#v%#v[%v]", CommandRegister, addr, key), nil
-	case byte(CommandData):
-		source := GertCFromBytes(data[1:7])
-		target := AddressFromBytes(data[7:10])
-		length := data[10]
-		dat := data[11 : 11+length]
-		return fmt.Sprintf("[DATA]%#v%#v[%v][%v]", source, target, length, string(dat)), nil
-	case byte(CommandClose):
-		return fmt.Sprintf("%#v", CommandClose), nil
+// PrettyPrintFrame prints the first frame of data into a human readable string, reading it with the layout of codec.
+// The frame is read like ServerCodec does if fromGateway is true and like ClientCodec does otherwise.
+// It returns the string and any encountered errors, frames that are too short return an error wrapping ErrTooShort.
+func PrettyPrintFrame(data []byte, codec FrameCodec, fromGateway bool) (string, error) {
+	rw := readWriter{bytes.NewReader(data), ioutil.Discard}
+	var m Message
+	var err error
+	if fromGateway {
+		server := NewServerCodec(rw)
+		server.SetCodec(codec)
+		m, err = server.ReadMessage()
+	} else {
+		client := NewClientCodec(rw)
+		client.SetCodec(codec)
+		m, err = client.ReadMessage()
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", fmt.Errorf("%w for frame: %v", ErrTooShort, err)
+	}
+	if err != nil {
+		return "", err
+	}
+	return PrettyPrintMessage(m), nil
+}
 
+// PrettyPrintMessage prints a Message into a human readable string naming its addresses
+func PrettyPrintMessage(m Message) string {
+	switch m := m.(type) {
+	case *StateRequest:
+		return fmt.Sprintf("%#v", CommandState)
+	case *StateReply:
+		return fmt.Sprintf("%#v%#v", CommandState, m.Status)
+	case *Register:
+		return fmt.Sprintf("%#v%#v[%v]", CommandRegister, m.Address, m.Key)
+	case *OutboundData:
+		return fmt.Sprintf("%#v[target %v][source %v][%v][%v]", CommandData, m.Target, m.Source, len(m.Data), string(m.Data))
+	case *InboundData:
+		return fmt.Sprintf("%#v[source %v][target %v][%v][%v]", CommandData, m.Source, m.Target, len(m.Data), string(m.Data))
+	case *Close:
+		return fmt.Sprintf("%#v", CommandClose)
 	}
-	return "[nil]", fmt.Errorf("no valid command: %v", data[0])
+	return "[nil]"
+}
+
+// readWriter reads from a fixed input and discards everything written
+type readWriter struct {
+	io.Reader
+	io.Writer
 }
@@ -0,0 +1,50 @@
+package gerte
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPrettyPrintFrame(t *testing.T) {
+	source := GERTc{GERTe: GertAddress{Upper: 2345, Lower: 1456}, GERTi: GertAddress{Upper: 1, Lower: 1}}
+	target := GERTc{GERTe: GertAddress{Upper: 1123, Lower: 1456}, GERTi: GertAddress{Upper: 2, Lower: 2}}
+	outbound := &OutboundData{Target: target, Source: source.GERTi, Data: []byte("hi")}
+	inbound := &InboundData{Source: source, Target: target, Data: []byte("hi")}
+	tests := []struct {
+		name        string
+		msg         Message
+		codec       FrameCodec
+		fromGateway bool
+		want        string
+	}{
+		{"Outbound", outbound, DefaultCodec, true, "[DATA][target 1123.1456:0002.0002][source 0001.0001][2][hi]"},
+		{"OutboundExtended", outbound, ExtendedCodec, true, "[DATA][target 1123.1456:0002.0002][source 0001.0001][2][hi]"},
+		{"Inbound", inbound, DefaultCodec, false, "[DATA][source 2345.1456:0001.0001][target 1123.1456:0002.0002][2][hi]"},
+		{"InboundExtended", inbound, ExtendedCodec, false, "[DATA][source 2345.1456:0001.0001][target 1123.1456:0002.0002][2][hi]"},
+		{"StateRequest", &StateRequest{}, DefaultCodec, true, "[STATE]"},
+		{"StateReply", &StateReply{Status: Status{Status: StateFailure, Error: ErrorNoRoute}}, DefaultCodec, false, "[STATE][FAILURE][NO_ROUTE]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame, err := tt.codec.Marshal(tt.msg)
+			if err != nil {
+				t.Fatalf("error on marshal: %+v", err)
+			}
+			// trailing bytes after the frame are ignored
+			got, err := PrettyPrintFrame(append(frame, 0, 0), tt.codec, tt.fromGateway)
+			if err != nil {
+				t.Fatalf("error on pretty print: %+v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if _, err := PrettyPrintFrame(frame[:len(frame)-1], tt.codec, tt.fromGateway); len(frame) > 1 && !errors.Is(err, ErrTooShort) {
+				t.Errorf("got %+v for a truncated frame, want %+v", err, ErrTooShort)
+			}
+		})
+	}
+	frame, _ := outbound.MarshalBinary()
+	if got, err := PrettyPrint(frame); err != nil || got != tests[0].want {
+		t.Errorf("got %q %+v, want %q", got, err, tests[0].want)
+	}
+}
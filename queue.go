@@ -0,0 +1,308 @@
+package gerte
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// QueuePolicy decides what happens to a Packet queued on a full SendQueue
+type QueuePolicy byte
+
+const (
+	// QueueBlock waits until there is room in the queue
+	QueueBlock QueuePolicy = iota
+	// QueueDrop drops the Packet, its Transmission fails with ErrQueueFull.
+	// A queued Packet of lower priority is dropped instead if there is one.
+	QueueDrop
+	// QueueError rejects the Packet, Enqueue returns ErrQueueFull
+	QueueError
+)
+
+// Priority is the priority class of a queued Packet, lower values are sent first
+type Priority byte
+
+const (
+	// PriorityControl is for control traffic that must not wait behind data
+	PriorityControl Priority = iota
+	// PriorityNormal is the default priority
+	PriorityNormal
+	// PriorityBulk is for bulk data that may wait
+	PriorityBulk
+
+	priorityCount
+)
+
+var (
+	// ErrQueueFull is returned when a Packet doesn't fit into a SendQueue, it is temporary
+	ErrQueueFull error = &temporaryError{msg: "send queue is full"}
+	// ErrQueueClosed is returned when using a closed SendQueue, and for packets still queued when it was closed
+	ErrQueueClosed = errors.New("send queue is closed")
+)
+
+type (
+	// SendQueue is a bounded outbound queue in front of an Api.
+	// Producers queue packets without waiting for the relay, a single worker transmits them one at a time,
+	// packets of a higher priority class first and packets of the same class in order.
+	SendQueue struct {
+		capacity int
+		policy   QueuePolicy
+		transmit TransmitFunc
+
+		mu       sync.Mutex
+		queues   [priorityCount][]*queuedPacket
+		depth    int
+		dropped  uint64
+		rejected uint64
+		sent     uint64
+		failed   uint64
+		// space is closed and replaced whenever a packet leaves the queue
+		space chan struct{}
+		// ready is signaled whenever a packet enters the queue
+		ready  chan struct{}
+		closed chan struct{}
+		done   chan struct{}
+	}
+
+	// QueueStats is a snapshot of the counters of a SendQueue
+	QueueStats struct {
+		// Depth is the number of queued packets
+		Depth int
+		// DepthByPriority is the number of queued packets of every priority class
+		DepthByPriority [priorityCount]int
+		// Dropped is the number of packets dropped because of QueueDrop
+		Dropped uint64
+		// Rejected is the number of packets rejected because of QueueError
+		Rejected uint64
+		// Sent is the number of packets transmitted successfully
+		Sent uint64
+		// Failed is the number of packets whose transmission failed or whose context ended while queued
+		Failed uint64
+	}
+
+	// queuedPacket is a Packet waiting in a SendQueue
+	queuedPacket struct {
+		ctx context.Context
+		pkt Packet
+		t   *Transmission
+	}
+)
+
+// NewSendQueue is the constructor for SendQueue, it transmits the queued packets with api.TransmitContext.
+// capacity is the number of packets the queue holds, policy decides what happens when it is full.
+// The queue has to be closed with Close to stop its worker.
+func NewSendQueue(api *Api, capacity int, policy QueuePolicy) *SendQueue {
+	return newSendQueue(api.TransmitContext, capacity, policy)
+}
+
+// newSendQueue creates a SendQueue transmitting the queued packets with transmit
+func newSendQueue(transmit TransmitFunc, capacity int, policy QueuePolicy) *SendQueue {
+	if capacity < 1 {
+		capacity = 1
+	}
+	q := &SendQueue{
+		capacity: capacity,
+		policy:   policy,
+		transmit: transmit,
+		space:    make(chan struct{}),
+		ready:    make(chan struct{}, 1),
+		closed:   make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+// Enqueue queues pkt with the priority prio.
+// It returns the Transmission carrying the result and any encountered errors, ErrQueueFull if the queue is full and the policy is QueueError.
+// ctx bounds waiting for room in the queue with QueueBlock and the transmission itself.
+func (q *SendQueue) Enqueue(ctx context.Context, pkt Packet, prio Priority) (*Transmission, error) {
+	if prio >= priorityCount {
+		return nil, fmt.Errorf("invalid priority %v", prio)
+	}
+	item := &queuedPacket{
+		ctx: ctx,
+		pkt: pkt,
+		t:   &Transmission{done: make(chan struct{})},
+	}
+	q.mu.Lock()
+	for {
+		if isClosed(q.closed) {
+			q.mu.Unlock()
+			return nil, ErrQueueClosed
+		}
+		if q.depth < q.capacity {
+			break
+		}
+		switch q.policy {
+		case QueueDrop:
+			victim := q.evict(prio)
+			if victim == nil {
+				q.dropped++
+				q.mu.Unlock()
+				item.t.complete(false, ErrQueueFull)
+				return item.t, nil
+			}
+			q.dropped++
+			victim.t.complete(false, ErrQueueFull)
+			continue
+		case QueueError:
+			q.rejected++
+			q.mu.Unlock()
+			return nil, ErrQueueFull
+		}
+		space := q.space
+		q.mu.Unlock()
+		select {
+		case <-space:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-q.closed:
+			return nil, ErrQueueClosed
+		}
+		q.mu.Lock()
+	}
+	q.queues[prio] = append(q.queues[prio], item)
+	q.depth++
+	q.mu.Unlock()
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+	return item.t, nil
+}
+
+// Transmit queues pkt with PriorityNormal and waits for the result, so the SendQueue can be used as a TransmitFunc.
+// It returns a bool whether the Packet was sent and any encountered errors.
+func (q *SendQueue) Transmit(ctx context.Context, pkt Packet) (bool, error) {
+	t, err := q.Enqueue(ctx, pkt, PriorityNormal)
+	if err != nil {
+		return false, err
+	}
+	return t.Wait(ctx)
+}
+
+// Depth returns the number of queued packets
+func (q *SendQueue) Depth() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.depth
+}
+
+// Dropped returns the number of packets dropped because of QueueDrop
+func (q *SendQueue) Dropped() uint64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.dropped
+}
+
+// Stats returns a snapshot of the counters of the queue
+func (q *SendQueue) Stats() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	stats := QueueStats{
+		Depth:    q.depth,
+		Dropped:  q.dropped,
+		Rejected: q.rejected,
+		Sent:     q.sent,
+		Failed:   q.failed,
+	}
+	for prio, queue := range q.queues {
+		stats.DepthByPriority[prio] = len(queue)
+	}
+	return stats
+}
+
+// Close stops the worker after the packet it is transmitting, packets still queued fail with ErrQueueClosed.
+// It returns any encountered errors.
+func (q *SendQueue) Close() error {
+	q.mu.Lock()
+	if isClosed(q.closed) {
+		q.mu.Unlock()
+		return ErrQueueClosed
+	}
+	close(q.closed)
+	var left []*queuedPacket
+	for prio := range q.queues {
+		left = append(left, q.queues[prio]...)
+		q.queues[prio] = nil
+	}
+	q.depth = 0
+	q.mu.Unlock()
+	for _, item := range left {
+		item.t.complete(false, ErrQueueClosed)
+	}
+	<-q.done
+	return nil
+}
+
+// run transmits queued packets until the queue is closed
+func (q *SendQueue) run() {
+	defer close(q.done)
+	for {
+		item := q.next()
+		if item == nil {
+			select {
+			case <-q.ready:
+				continue
+			case <-q.closed:
+				return
+			}
+		}
+		var ok bool
+		err := item.ctx.Err()
+		if err == nil {
+			ok, err = q.transmit(item.ctx, item.pkt)
+		}
+		q.mu.Lock()
+		if ok && err == nil {
+			q.sent++
+		} else {
+			q.failed++
+		}
+		q.mu.Unlock()
+		item.t.complete(ok, err)
+	}
+}
+
+// next removes the first packet of the highest priority class from the queue.
+// It returns nil if the queue is empty.
+func (q *SendQueue) next() *queuedPacket {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for prio := range q.queues {
+		if len(q.queues[prio]) == 0 {
+			continue
+		}
+		item := q.queues[prio][0]
+		q.queues[prio][0] = nil
+		q.queues[prio] = q.queues[prio][1:]
+		q.removed()
+		return item
+	}
+	return nil
+}
+
+// evict removes the newest packet of the lowest priority class below prio from the queue, q.mu must be held.
+// It returns nil if there is none.
+func (q *SendQueue) evict(prio Priority) *queuedPacket {
+	for p := priorityCount - 1; p > prio; p-- {
+		queue := q.queues[p]
+		if len(queue) == 0 {
+			continue
+		}
+		item := queue[len(queue)-1]
+		q.queues[p] = queue[:len(queue)-1]
+		q.removed()
+		return item
+	}
+	return nil
+}
+
+// removed accounts for a packet leaving the queue and wakes up blocked producers, q.mu must be held
+func (q *SendQueue) removed() {
+	q.depth--
+	close(q.space)
+	q.space = make(chan struct{})
+}
@@ -0,0 +1,157 @@
+package gerte
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gatedTransmit records the data of transmitted packets, every transmission waits for a value on gate
+type gatedTransmit struct {
+	gate chan struct{}
+
+	mu   sync.Mutex
+	sent []string
+}
+
+func (g *gatedTransmit) transmit(ctx context.Context, pkt Packet) (bool, error) {
+	select {
+	case <-g.gate:
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.sent = append(g.sent, string(pkt.Data))
+	return true, nil
+}
+
+// busyQueue returns a queue whose worker is blocked transmitting a first packet, so queued packets stay queued
+func busyQueue(t *testing.T, capacity int, policy QueuePolicy) (*SendQueue, *gatedTransmit) {
+	g := &gatedTransmit{gate: make(chan struct{})}
+	q := newSendQueue(g.transmit, capacity, policy)
+	if _, err := q.Enqueue(context.Background(), Packet{Data: []byte("busy")}, PriorityNormal); err != nil {
+		t.Fatalf("error on enqueue: %+v", err)
+	}
+	for q.Depth() != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	return q, g
+}
+
+func enqueue(t *testing.T, q *SendQueue, data string, prio Priority) *Transmission {
+	t.Helper()
+	tr, err := q.Enqueue(context.Background(), Packet{Data: []byte(data)}, prio)
+	if err != nil {
+		t.Fatalf("error on enqueue %v: %+v", data, err)
+	}
+	return tr
+}
+
+func TestSendQueue_Priority(t *testing.T) {
+	q, g := busyQueue(t, 10, QueueBlock)
+	defer q.Close()
+	enqueue(t, q, "bulk1", PriorityBulk)
+	enqueue(t, q, "normal1", PriorityNormal)
+	enqueue(t, q, "control", PriorityControl)
+	enqueue(t, q, "normal2", PriorityNormal)
+	last := enqueue(t, q, "bulk2", PriorityBulk)
+
+	stats := q.Stats()
+	if stats.Depth != 5 || stats.DepthByPriority != [priorityCount]int{1, 2, 2} {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	close(g.gate)
+	if ok, err := last.Wait(context.Background()); !ok || err != nil {
+		t.Fatalf("error on transmit: %v %+v", ok, err)
+	}
+	want := []string{"busy", "control", "normal1", "normal2", "bulk1", "bulk2"}
+	if !reflect.DeepEqual(g.sent, want) {
+		t.Errorf("got %v, want %v", g.sent, want)
+	}
+	if stats := q.Stats(); stats.Sent != 6 || stats.Depth != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestSendQueue_Policy(t *testing.T) {
+	ctx := context.Background()
+	t.Run("PolicyBlock", func(t *testing.T) {
+		q, g := busyQueue(t, 1, QueueBlock)
+		defer q.Close()
+		enqueue(t, q, "queued", PriorityNormal)
+		timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		if _, err := q.Enqueue(timeout, Packet{}, PriorityNormal); err != context.DeadlineExceeded {
+			t.Errorf("got %+v, want %+v", err, context.DeadlineExceeded)
+		}
+		blocked := make(chan *Transmission)
+		go func() {
+			tr, _ := q.Enqueue(ctx, Packet{Data: []byte("blocked")}, PriorityNormal)
+			blocked <- tr
+		}()
+		g.gate <- struct{}{}
+		close(g.gate)
+		tr := <-blocked
+		if ok, err := tr.Wait(ctx); !ok || err != nil {
+			t.Errorf("got %v %+v, want true <nil>", ok, err)
+		}
+	})
+	t.Run("PolicyDrop", func(t *testing.T) {
+		q, g := busyQueue(t, 2, QueueDrop)
+		defer q.Close()
+		enqueue(t, q, "normal1", PriorityNormal)
+		normal := enqueue(t, q, "normal2", PriorityNormal)
+		dropped := enqueue(t, q, "dropped", PriorityNormal)
+		if _, err := dropped.Wait(ctx); err != ErrQueueFull {
+			t.Errorf("got %+v, want %+v", err, ErrQueueFull)
+		}
+		control := enqueue(t, q, "control", PriorityControl)
+		if _, err := normal.Wait(ctx); err != ErrQueueFull {
+			t.Errorf("evicted packet: got %+v, want %+v", err, ErrQueueFull)
+		}
+		if q.Dropped() != 2 {
+			t.Errorf("got %v drops, want 2", q.Dropped())
+		}
+		close(g.gate)
+		if ok, err := control.Wait(ctx); !ok || err != nil {
+			t.Errorf("got %v %+v, want true <nil>", ok, err)
+		}
+	})
+	t.Run("PolicyError", func(t *testing.T) {
+		q, g := busyQueue(t, 1, QueueError)
+		defer q.Close()
+		defer close(g.gate)
+		enqueue(t, q, "queued", PriorityNormal)
+		if _, err := q.Enqueue(ctx, Packet{}, PriorityControl); err != ErrQueueFull {
+			t.Errorf("got %+v, want %+v", err, ErrQueueFull)
+		}
+		if stats := q.Stats(); stats.Rejected != 1 {
+			t.Errorf("unexpected stats %+v", stats)
+		}
+	})
+}
+
+func TestSendQueue_Close(t *testing.T) {
+	q, g := busyQueue(t, 5, QueueBlock)
+	queued := enqueue(t, q, "queued", PriorityNormal)
+	closed := make(chan error)
+	go func() {
+		closed <- q.Close()
+	}()
+	if _, err := queued.Wait(context.Background()); err != ErrQueueClosed {
+		t.Errorf("got %+v, want %+v", err, ErrQueueClosed)
+	}
+	close(g.gate)
+	if err := <-closed; err != nil {
+		t.Fatalf("error on close: %+v", err)
+	}
+	if _, err := q.Enqueue(context.Background(), Packet{}, PriorityNormal); err != ErrQueueClosed {
+		t.Errorf("got %+v, want %+v", err, ErrQueueClosed)
+	}
+	if err := q.Close(); err != ErrQueueClosed {
+		t.Errorf("got %+v, want %+v", err, ErrQueueClosed)
+	}
+}
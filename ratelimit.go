@@ -0,0 +1,351 @@
+package gerte
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned when a Packet exceeds a rate limit and the RateLimiter rejects it.
+// It is temporary, see IsTemporary.
+var ErrRateLimited error = &temporaryError{msg: "rate limit exceeded"}
+
+// LimitMode decides what happens to a Packet exceeding a rate limit
+type LimitMode byte
+
+const (
+	// LimitWait delays the Packet until the limits allow it
+	LimitWait LimitMode = iota
+	// LimitReject fails the Packet with ErrRateLimited
+	LimitReject
+)
+
+type (
+	// TokenBucket is a token bucket rate limiter.
+	// It holds up to burst tokens and refills rate tokens per second, every Packet takes one token.
+	// A rate of zero or less disables the limit.
+	TokenBucket struct {
+		mu     sync.Mutex
+		rate   float64
+		burst  int
+		tokens float64
+		last   time.Time
+		now    func() time.Time
+	}
+
+	// RateLimiter limits the packets sent to every destination and overall with token buckets.
+	// Limits can be set for a target GERTc, for all GERTi addresses behind a GERTe gateway, and globally,
+	// a Packet has to pass all limits that apply to it.
+	// All limits can be changed at runtime.
+	RateLimiter struct {
+		mu           sync.Mutex
+		mode         LimitMode
+		global       *TokenBucket
+		targets      map[GERTc]*TokenBucket
+		gateways     map[GertAddress]*TokenBucket
+		defaultRate  float64
+		defaultBurst int
+		// defaultBuckets are the buckets created for gateways without a limit of their own
+		defaultBuckets map[GertAddress]*TokenBucket
+		// swept is the time defaultBuckets were last checked for full buckets
+		swept time.Time
+		now   func() time.Time
+	}
+)
+
+// NewTokenBucket is the constructor for TokenBucket, the bucket starts full
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	return newTokenBucket(rate, burst, time.Now)
+}
+
+func newTokenBucket(rate float64, burst int, now func() time.Time) *TokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &TokenBucket{
+		rate:   rate,
+		burst:  burst,
+		tokens: float64(burst),
+		last:   now(),
+		now:    now,
+	}
+}
+
+// Allow takes a token if one is available.
+// It returns whether a token was taken.
+func (b *TokenBucket) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.rate <= 0 {
+		return true
+	}
+	b.advance()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Wait takes a token, waiting until one is available.
+// It returns ctx.Err() if ctx ends first, the token is given back then.
+func (b *TokenBucket) Wait(ctx context.Context) error {
+	return waitReservations(ctx, []*TokenBucket{b})
+}
+
+// SetLimit changes the rate and burst of the bucket, tokens above the new burst are discarded
+func (b *TokenBucket) SetLimit(rate float64, burst int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance()
+	if burst < 1 {
+		burst = 1
+	}
+	b.rate = rate
+	b.burst = burst
+	if b.tokens > float64(burst) {
+		b.tokens = float64(burst)
+	}
+}
+
+// Limit returns the rate and burst of the bucket
+func (b *TokenBucket) Limit() (float64, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.rate, b.burst
+}
+
+// advance refills the tokens for the time passed since the last call, b.mu must be held
+func (b *TokenBucket) advance() {
+	now := b.now()
+	if b.rate > 0 {
+		b.tokens = math.Min(float64(b.burst), b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+}
+
+// reserve takes a token even if none is available.
+// It returns the time until the token is available.
+func (b *TokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.rate <= 0 {
+		return 0
+	}
+	b.advance()
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// full returns whether the bucket holds burst tokens, a full bucket limits the same as a new one
+func (b *TokenBucket) full() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance()
+	return b.tokens >= float64(b.burst)
+}
+
+// refund gives back a token taken by reserve or Allow
+func (b *TokenBucket) refund() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.rate <= 0 {
+		return
+	}
+	b.advance()
+	b.tokens = math.Min(float64(b.burst), b.tokens+1)
+}
+
+// waitReservations takes a token from every bucket and waits until all of them are available.
+// It returns ctx.Err() if ctx ends first, the tokens are given back then.
+func waitReservations(ctx context.Context, buckets []*TokenBucket) error {
+	var delay time.Duration
+	for _, b := range buckets {
+		if d := b.reserve(); d > delay {
+			delay = d
+		}
+	}
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		for _, b := range buckets {
+			b.refund()
+		}
+		return ctx.Err()
+	}
+}
+
+// NewRateLimiter is the constructor for RateLimiter, mode decides what happens to packets exceeding a limit.
+// The RateLimiter starts without any limits.
+func NewRateLimiter(mode LimitMode) *RateLimiter {
+	return &RateLimiter{
+		mode:           mode,
+		targets:        make(map[GERTc]*TokenBucket),
+		gateways:       make(map[GertAddress]*TokenBucket),
+		defaultBuckets: make(map[GertAddress]*TokenBucket),
+		now:            time.Now,
+	}
+}
+
+// SetMode changes what happens to packets exceeding a limit
+func (l *RateLimiter) SetMode(mode LimitMode) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.mode = mode
+}
+
+// SetGlobalLimit limits all packets together to rate packets per second with bursts of up to burst packets.
+// A rate of zero or less removes the limit.
+func (l *RateLimiter) SetGlobalLimit(rate float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.global = l.update(l.global, rate, burst)
+}
+
+// SetGatewayLimit limits the packets to all GERTi addresses behind the GERTe gateway addr.
+// A rate of zero or less removes the limit.
+func (l *RateLimiter) SetGatewayLimit(addr GertAddress, rate float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if b := l.update(l.gateways[addr], rate, burst); b != nil {
+		l.gateways[addr] = b
+	} else {
+		delete(l.gateways, addr)
+	}
+}
+
+// SetTargetLimit limits the packets to the GERTc addr.
+// A rate of zero or less removes the limit.
+func (l *RateLimiter) SetTargetLimit(addr GERTc, rate float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if b := l.update(l.targets[addr], rate, burst); b != nil {
+		l.targets[addr] = b
+	} else {
+		delete(l.targets, addr)
+	}
+}
+
+// SetDefaultGatewayLimit limits the packets to every GERTe gateway without a limit set by SetGatewayLimit,
+// every gateway gets a bucket of its own.
+// A rate of zero or less removes the limit.
+func (l *RateLimiter) SetDefaultGatewayLimit(rate float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.defaultRate, l.defaultBurst = rate, burst
+	for addr, b := range l.defaultBuckets {
+		if rate <= 0 {
+			delete(l.defaultBuckets, addr)
+			continue
+		}
+		b.SetLimit(rate, burst)
+	}
+}
+
+// Allow takes a token from every limit that applies to target.
+// It returns whether all limits allowed the Packet, no tokens are taken otherwise.
+func (l *RateLimiter) Allow(target GERTc) bool {
+	buckets := l.buckets(target)
+	for i, b := range buckets {
+		if !b.Allow() {
+			for _, taken := range buckets[:i] {
+				taken.refund()
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// Wait takes a token from every limit that applies to target, waiting until all of them are available.
+// It returns ctx.Err() if ctx ends first.
+func (l *RateLimiter) Wait(ctx context.Context, target GERTc) error {
+	return waitReservations(ctx, l.buckets(target))
+}
+
+// Middleware returns OutboundMiddleware applying the limits to every transmitted Packet, to be added with Api.UseOutbound.
+// Depending on the mode, packets exceeding a limit are delayed or fail with ErrRateLimited.
+func (l *RateLimiter) Middleware() OutboundMiddleware {
+	return func(next TransmitFunc) TransmitFunc {
+		return func(ctx context.Context, pkt Packet) (bool, error) {
+			l.mu.Lock()
+			mode := l.mode
+			l.mu.Unlock()
+			if mode == LimitReject {
+				if !l.Allow(pkt.Target) {
+					return false, ErrRateLimited
+				}
+			} else if err := l.Wait(ctx, pkt.Target); err != nil {
+				return false, err
+			}
+			return next(ctx, pkt)
+		}
+	}
+}
+
+// buckets returns the buckets of all limits that apply to target
+func (l *RateLimiter) buckets(target GERTc) []*TokenBucket {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var buckets []*TokenBucket
+	if b, ok := l.targets[target]; ok {
+		buckets = append(buckets, b)
+	}
+	if b, ok := l.gateways[target.GERTe]; ok {
+		buckets = append(buckets, b)
+	} else if l.defaultRate > 0 {
+		l.evictDefault()
+		b, ok := l.defaultBuckets[target.GERTe]
+		if !ok {
+			b = newTokenBucket(l.defaultRate, l.defaultBurst, l.now)
+			l.defaultBuckets[target.GERTe] = b
+		}
+		buckets = append(buckets, b)
+	}
+	if l.global != nil {
+		buckets = append(buckets, l.global)
+	}
+	return buckets
+}
+
+// evictDefault removes the default buckets that refilled completely, so gateways no longer sent to don't keep a bucket.
+// The buckets are checked at most once per time a bucket takes to refill, l.mu must be held.
+func (l *RateLimiter) evictDefault() {
+	burst := l.defaultBurst
+	if burst < 1 {
+		burst = 1
+	}
+	now := l.now()
+	if now.Sub(l.swept) < time.Duration(float64(burst)/l.defaultRate*float64(time.Second)) {
+		return
+	}
+	l.swept = now
+	for addr, b := range l.defaultBuckets {
+		if b.full() {
+			delete(l.defaultBuckets, addr)
+		}
+	}
+}
+
+// update changes the limit of b, creating it if necessary, l.mu must be held.
+// It returns the bucket, nil if the limit was removed.
+func (l *RateLimiter) update(b *TokenBucket, rate float64, burst int) *TokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+	if b == nil {
+		return newTokenBucket(rate, burst, l.now)
+	}
+	b.SetLimit(rate, burst)
+	return b
+}
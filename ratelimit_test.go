@@ -0,0 +1,221 @@
+package gerte
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock for token buckets that only moves when told to
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestTokenBucket(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	b := newTokenBucket(10, 3, clock.Now)
+
+	t.Run("TokenBucketBurst", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			if !b.Allow() {
+				t.Fatalf("packet %v of the burst was not allowed", i)
+			}
+		}
+		if b.Allow() {
+			t.Error("packet after the burst was allowed")
+		}
+	})
+	t.Run("TokenBucketRefill", func(t *testing.T) {
+		clock.Advance(100 * time.Millisecond)
+		if !b.Allow() {
+			t.Error("refilled token was not allowed")
+		}
+		if b.Allow() {
+			t.Error("packet without a token was allowed")
+		}
+		clock.Advance(time.Hour)
+		for i := 0; i < 3; i++ {
+			if !b.Allow() {
+				t.Fatalf("packet %v of the burst was not allowed", i)
+			}
+		}
+		if b.Allow() {
+			t.Error("bucket refilled above its burst")
+		}
+	})
+	t.Run("TokenBucketReserve", func(t *testing.T) {
+		if d := b.reserve(); d != 100*time.Millisecond {
+			t.Errorf("got delay %v, want %v", d, 100*time.Millisecond)
+		}
+		if d := b.reserve(); d != 200*time.Millisecond {
+			t.Errorf("got delay %v, want %v", d, 200*time.Millisecond)
+		}
+		b.refund()
+		b.refund()
+	})
+	t.Run("TokenBucketSetLimit", func(t *testing.T) {
+		b.SetLimit(0, 1)
+		for i := 0; i < 100; i++ {
+			if !b.Allow() {
+				t.Fatal("unlimited bucket did not allow a packet")
+			}
+		}
+		b.SetLimit(1, 1)
+		clock.Advance(time.Second)
+		if !b.Allow() || b.Allow() {
+			t.Error("bucket did not apply the new limit")
+		}
+	})
+}
+
+func TestTokenBucket_Wait(t *testing.T) {
+	b := NewTokenBucket(100, 1)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := b.Wait(context.Background()); err != nil {
+			t.Fatalf("error on wait: %+v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 15*time.Millisecond {
+		t.Errorf("3 packets at 100/s with a burst of 1 took only %v", elapsed)
+	}
+
+	b = NewTokenBucket(1, 1)
+	b.Allow()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := b.Wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("got %+v, want %+v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	gatewayA := GertAddress{Upper: 1, Lower: 1}
+	gatewayB := GertAddress{Upper: 2, Lower: 2}
+	targetA1 := GERTc{GERTe: gatewayA, GERTi: GertAddress{Upper: 1}}
+	targetA2 := GERTc{GERTe: gatewayA, GERTi: GertAddress{Upper: 2}}
+	targetB := GERTc{GERTe: gatewayB}
+
+	newLimiter := func() *RateLimiter {
+		l := NewRateLimiter(LimitReject)
+		l.now = clock.Now
+		return l
+	}
+	allowed := func(l *RateLimiter, target GERTc, n int) int {
+		count := 0
+		for i := 0; i < n; i++ {
+			if l.Allow(target) {
+				count++
+			}
+		}
+		return count
+	}
+
+	t.Run("RateLimiterTarget", func(t *testing.T) {
+		l := newLimiter()
+		l.SetTargetLimit(targetA1, 1, 2)
+		if got := allowed(l, targetA1, 5); got != 2 {
+			t.Errorf("got %v packets to the limited target, want 2", got)
+		}
+		if got := allowed(l, targetA2, 5); got != 5 {
+			t.Errorf("got %v packets to an unlimited target, want 5", got)
+		}
+	})
+	t.Run("RateLimiterGateway", func(t *testing.T) {
+		l := newLimiter()
+		l.SetGatewayLimit(gatewayA, 1, 3)
+		if got := allowed(l, targetA1, 2) + allowed(l, targetA2, 2); got != 3 {
+			t.Errorf("got %v packets to the limited gateway, want 3", got)
+		}
+		if got := allowed(l, targetB, 5); got != 5 {
+			t.Errorf("got %v packets to an unlimited gateway, want 5", got)
+		}
+	})
+	t.Run("RateLimiterDefaultGateway", func(t *testing.T) {
+		l := newLimiter()
+		l.SetDefaultGatewayLimit(1, 2)
+		if got := allowed(l, targetA1, 5); got != 2 {
+			t.Errorf("got %v packets to gateway A, want 2", got)
+		}
+		if got := allowed(l, targetB, 5); got != 2 {
+			t.Errorf("got %v packets to gateway B, want 2", got)
+		}
+		l.SetDefaultGatewayLimit(0, 0)
+		if got := allowed(l, targetB, 5); got != 5 {
+			t.Errorf("got %v packets after removing the limit, want 5", got)
+		}
+	})
+	t.Run("RateLimiterDefaultGatewayEvict", func(t *testing.T) {
+		l := newLimiter()
+		l.SetDefaultGatewayLimit(1, 2)
+		for i := 0; i < 100; i++ {
+			allowed(l, GERTc{GERTe: GertAddress{Upper: i}}, 1)
+		}
+		clock.Advance(time.Second)
+		allowed(l, targetA1, 2)
+		// after the refill time only the bucket of gateway A is still in use
+		clock.Advance(time.Second)
+		allowed(l, targetB, 1)
+		l.mu.Lock()
+		n := len(l.defaultBuckets)
+		l.mu.Unlock()
+		if n != 2 {
+			t.Errorf("got %v default buckets, want 2", n)
+		}
+		if got := allowed(l, targetA1, 5); got != 1 {
+			t.Errorf("got %v packets to gateway A, want 1", got)
+		}
+	})
+	t.Run("RateLimiterGlobal", func(t *testing.T) {
+		l := newLimiter()
+		l.SetGlobalLimit(1, 4)
+		l.SetTargetLimit(targetA1, 1, 1)
+		if got := allowed(l, targetA1, 3); got != 1 {
+			t.Errorf("got %v packets to the limited target, want 1", got)
+		}
+		// the packets rejected by the target limit didn't use up global tokens
+		if got := allowed(l, targetB, 5); got != 3 {
+			t.Errorf("got %v packets overall, want 3", got)
+		}
+	})
+	t.Run("RateLimiterMiddleware", func(t *testing.T) {
+		l := newLimiter()
+		l.SetTargetLimit(targetA1, 1, 1)
+		transmit := l.Middleware()(func(ctx context.Context, pkt Packet) (bool, error) {
+			return true, nil
+		})
+		ctx := context.Background()
+		if ok, err := transmit(ctx, Packet{Target: targetA1}); !ok || err != nil {
+			t.Errorf("got %v %+v, want true <nil>", ok, err)
+		}
+		if _, err := transmit(ctx, Packet{Target: targetA1}); err != ErrRateLimited {
+			t.Errorf("got %+v, want %+v", err, ErrRateLimited)
+		}
+
+		l.SetMode(LimitWait)
+		timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		if _, err := transmit(timeout, Packet{Target: targetA1}); err != context.DeadlineExceeded {
+			t.Errorf("got %+v, want %+v", err, context.DeadlineExceeded)
+		}
+		clock.Advance(time.Second)
+		if ok, err := transmit(ctx, Packet{Target: targetA1}); !ok || err != nil {
+			t.Errorf("got %v %+v, want true <nil>", ok, err)
+		}
+	})
+}
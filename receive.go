@@ -0,0 +1,144 @@
+package gerte
+
+import (
+	"fmt"
+	"net"
+)
+
+// packetBuffer is the number of inbound packets Packets can hold before the receive loop blocks
+const packetBuffer = 64
+
+// Receive starts the receive loop, a goroutine that reads every frame sent by the relay.
+// It returns any encountered errors.
+// STATE replies are matched to the Register, Transmit or Shutdown call waiting for them, in the order the commands were sent.
+// Inbound DATA packets pass the middleware added with UseInbound and are passed to handler, or delivered on Packets if handler is nil.
+// The handler is called from the receive loop, so it must not block and must not wait for a reply from the relay.
+// Once the receive loop is running, Parse can no longer be used.
+func (api *Api) Receive(handler func(Packet)) error {
+	// wait for synchronous requests still reading their replies
+	api.sendMu.Lock()
+	defer api.sendMu.Unlock()
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if api.socket == nil {
+		return ErrNotConnected
+	}
+	if api.receiving {
+		return fmt.Errorf("receive loop already running")
+	}
+	api.receiving = true
+	api.closing = false
+	api.recvErr = nil
+	api.pending = nil
+	api.done = make(chan struct{})
+	maxInFlight := api.MaxInFlight
+	if maxInFlight <= 0 {
+		maxInFlight = DefaultMaxInFlight
+	}
+	api.inFlight = make(chan struct{}, maxInFlight)
+	if handler == nil {
+		api.packets = make(chan Packet, packetBuffer)
+		packets := api.packets
+		done := api.done
+		handler = func(pkt Packet) {
+			select {
+			case packets <- pkt:
+			case <-done:
+			}
+		}
+	} else {
+		api.packets = nil
+	}
+	go api.receiveLoop(api.reader(api.socket), handler)
+	return nil
+}
+
+// Packets returns the channel inbound DATA packets are delivered on if Receive was started without a handler.
+// It returns nil otherwise.
+// The channel is closed when the receive loop stops.
+func (api *Api) Packets() <-chan Packet {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	return api.packets
+}
+
+// Done returns a channel that is closed when the receive loop stops.
+// It returns nil if Receive was never called.
+func (api *Api) Done() <-chan struct{} {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	return api.done
+}
+
+// Err returns the error that stopped the receive loop.
+// It returns nil while the loop is running or if it was stopped by Shutdown.
+func (api *Api) Err() error {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	return api.recvErr
+}
+
+// receiveLoop reads frames with dec until the connection fails or is closed
+func (api *Api) receiveLoop(dec *Decoder, handler func(Packet)) {
+	for {
+		cmd, err := dec.Decode()
+		if err != nil {
+			api.stopReceiving(fmt.Errorf("error on read data: %w", err))
+			return
+		}
+		switch cmd.Command {
+		case CommandState:
+			api.mu.Lock()
+			if len(api.pending) > 0 {
+				reply := api.pending[0]
+				api.pending = api.pending[1:]
+				reply <- cmd
+			}
+			api.mu.Unlock()
+		case CommandData:
+			if pkt, ok := api.receivePacket(cmd.Packet); ok {
+				handler(pkt)
+			}
+		case CommandClose:
+			err := api.closeSocket(SessionClosed, nil)
+			if err != nil {
+				api.stopReceiving(fmt.Errorf("error while closing socket: %w", err))
+				return
+			}
+			api.stopReceiving(ErrClosedByRelay)
+			return
+		case CommandRegister:
+			api.stopReceiving(fmt.Errorf("%w: geds returned command register", ErrInvalidResponse))
+			return
+		}
+	}
+}
+
+// stopReceiving records the error that ended the receive loop and wakes up everyone waiting for it.
+// If the connection is still open, it is closed and the session fails.
+func (api *Api) stopReceiving(err error) {
+	api.mu.Lock()
+	var c net.Conn
+	if api.closing {
+		err = nil
+	} else if api.socket == nil && api.state == SessionFailed && api.cause != nil {
+		// the connection was failed on purpose, for example by Keepalive
+		err = api.cause
+	} else if api.socket != nil {
+		c = api.socket
+		api.socket = nil
+		api.setState(SessionFailed, err)
+	}
+	api.recvErr = err
+	api.receiving = false
+	api.pending = nil
+	close(api.done)
+	if api.packets != nil {
+		close(api.packets)
+	}
+	api.mu.Unlock()
+	if c != nil {
+		_ = c.Close()
+	}
+	api.notify()
+}
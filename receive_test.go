@@ -0,0 +1,160 @@
+package gerte
+
+import (
+	"bufio"
+	"net"
+	"sync"
+	"testing"
+)
+
+func inboundFrame(pkt Packet) []byte {
+	frame := []byte{byte(CommandData)}
+	frame = append(frame, pkt.Source.ToBytes()...)
+	frame = append(frame, pkt.Target.ToBytes()...)
+	frame = append(frame, byte(len(pkt.Data)))
+	return append(frame, pkt.Data...)
+}
+
+func TestApi_Receive(t *testing.T) {
+	t.Run("Data before Reply", ReceiveDataBeforeReply)
+	t.Run("Packets Channel", ReceivePacketsChannel)
+	t.Run("Closed by Relay", ReceiveClosedByRelay)
+}
+func ReceiveDataBeforeReply(t *testing.T) {
+	server, client := net.Pipe()
+	inbound := Packet{
+		Source: GERTc{GERTe: GertAddress{Upper: 2345, Lower: 1456}, GERTi: GertAddress{Upper: 1, Lower: 1}},
+		Target: GERTc{GERTe: GertAddress{Upper: 1123, Lower: 1456}},
+		Data:   []byte("incoming"),
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r := bufio.NewReader(server)
+		dat := make([]byte, 1024)
+		_, err := r.Read(dat)
+		if err != nil {
+			t.Errorf("server errored on read: %+v", err)
+		}
+		_, err = server.Write(inboundFrame(inbound))
+		if err != nil {
+			t.Errorf("server errored on write data: %+v", err)
+		}
+		_, err = server.Write([]byte{byte(CommandState), byte(StateSent)})
+		if err != nil {
+			t.Errorf("server errored on write state: %+v", err)
+		}
+		_, err = r.Read(dat)
+		if err != nil {
+			t.Errorf("server errored on read: %+v", err)
+		}
+		_, err = server.Write([]byte{byte(CommandState), byte(StateClosed)})
+		if err != nil {
+			t.Errorf("server errored on write state: %+v", err)
+		}
+		server.Close()
+	}()
+
+	var api Api
+	attach(&api, client, SessionAssigned)
+	received := make(chan Packet, 1)
+	err := api.Receive(func(pkt Packet) {
+		received <- pkt
+	})
+	if err != nil {
+		t.Fatalf("client errored on receive: %+v", err)
+	}
+	_, err = api.Transmit(Packet{
+		Target: inbound.Source,
+		Data:   []byte("hello world!"),
+	})
+	if err != nil {
+		t.Errorf("client errored on transmit: %+v", err)
+	}
+	pkt := <-received
+	if pkt.Source != inbound.Source || pkt.Target != inbound.Target || string(pkt.Data) != string(inbound.Data) {
+		t.Errorf("packets don't match:\n%+v\n%+v", inbound, pkt)
+	}
+	err = api.Shutdown()
+	if err != nil {
+		t.Errorf("client errored on shutdown: %+v", err)
+	}
+	<-api.Done()
+	if err := api.Err(); err != nil {
+		t.Errorf("receive loop errored after shutdown: %+v", err)
+	}
+	wg.Wait()
+}
+func ReceivePacketsChannel(t *testing.T) {
+	server, client := net.Pipe()
+	inbound := Packet{
+		Source: GERTc{GERTe: GertAddress{Upper: 2345, Lower: 1456}},
+		Target: GERTc{GERTe: GertAddress{Upper: 1123, Lower: 1456}},
+		Data:   []byte("incoming"),
+	}
+	go func() {
+		_, err := server.Write(inboundFrame(inbound))
+		if err != nil {
+			t.Errorf("server errored on write data: %+v", err)
+		}
+		server.Close()
+	}()
+
+	var api Api
+	attach(&api, client, SessionAssigned)
+	err := api.Receive(nil)
+	if err != nil {
+		t.Fatalf("client errored on receive: %+v", err)
+	}
+	if err := api.Receive(nil); err == nil {
+		t.Error("second receive loop was started")
+	}
+	if _, err := api.Parse(); err == nil {
+		t.Error("parse succeeded while receive loop is running")
+	}
+	pkt, ok := <-api.Packets()
+	if !ok {
+		t.Fatal("packets closed before delivering data")
+	}
+	if pkt.Source != inbound.Source {
+		t.Errorf("packets don't match:\n%+v\n%+v", inbound, pkt)
+	}
+	if _, ok := <-api.Packets(); ok {
+		t.Error("packets not closed after receive loop stopped")
+	}
+	if api.Err() == nil {
+		t.Error("receive loop stopped without error")
+	}
+	client.Close()
+}
+func ReceiveClosedByRelay(t *testing.T) {
+	server, client := net.Pipe()
+	go func() {
+		dat := make([]byte, 1024)
+		_, err := server.Read(dat)
+		if err != nil {
+			t.Errorf("server errored on read: %+v", err)
+		}
+		_, err = server.Write([]byte{byte(CommandClose)})
+		if err != nil {
+			t.Errorf("server errored on write close: %+v", err)
+		}
+		server.Close()
+	}()
+
+	var api Api
+	attach(&api, client, SessionAssigned)
+	err := api.Receive(func(Packet) {})
+	if err != nil {
+		t.Fatalf("client errored on receive: %+v", err)
+	}
+	_, err = api.Transmit(Packet{Data: []byte("hello world!")})
+	if err == nil {
+		t.Error("transmit succeeded after relay closed the connection")
+	}
+	<-api.Done()
+	if api.Err() == nil {
+		t.Error("receive loop stopped without error")
+	}
+}
@@ -0,0 +1,123 @@
+package gerte
+
+import (
+	"sort"
+	"sync"
+)
+
+// RoutingTable maps GERTc prefixes to values and looks up the longest prefix containing an address.
+// GERTe blocks are added as the GERTc prefix of every address behind them, see AddressPrefix.GERTc,
+// so gateway dispatch and relay forwarding can share one table.
+// A RoutingTable is safe for concurrent use by multiple goroutines, the zero value is an empty table.
+type RoutingTable struct {
+	mu sync.RWMutex
+	// routes holds the values by prefix length and masked address
+	routes map[int]map[uint64]interface{}
+	// lengths are the prefix lengths in routes, longest first
+	lengths []int
+}
+
+// NewRoutingTable is the constructor for RoutingTable
+func NewRoutingTable() *RoutingTable {
+	return new(RoutingTable)
+}
+
+// Insert adds a route for prefix, replacing the value of an existing route for the same prefix.
+// It returns whether a route was replaced.
+// The prefix has to be valid, like the ones returned by NewGERTcPrefix and ParseGERTcPrefix.
+func (t *RoutingTable) Insert(prefix GERTcPrefix, value interface{}) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.routes == nil {
+		t.routes = make(map[int]map[uint64]interface{})
+	}
+	routes, ok := t.routes[prefix.Bits]
+	if !ok {
+		routes = make(map[uint64]interface{})
+		t.routes[prefix.Bits] = routes
+		t.lengths = append(t.lengths, prefix.Bits)
+		sort.Sort(sort.Reverse(sort.IntSlice(t.lengths)))
+	}
+	key := maskKey(gertcKey(prefix.Addr), prefix.Bits, GERTcBits)
+	_, replaced := routes[key]
+	routes[key] = value
+	return replaced
+}
+
+// Delete removes the route for prefix.
+// It returns whether a route was removed.
+func (t *RoutingTable) Delete(prefix GERTcPrefix) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	routes, ok := t.routes[prefix.Bits]
+	if !ok {
+		return false
+	}
+	key := maskKey(gertcKey(prefix.Addr), prefix.Bits, GERTcBits)
+	if _, ok := routes[key]; !ok {
+		return false
+	}
+	delete(routes, key)
+	if len(routes) == 0 {
+		delete(t.routes, prefix.Bits)
+		for i, bits := range t.lengths {
+			if bits == prefix.Bits {
+				t.lengths = append(t.lengths[:i:i], t.lengths[i+1:]...)
+				break
+			}
+		}
+	}
+	return true
+}
+
+// Get returns the value of the route for exactly prefix and whether there is one
+func (t *RoutingTable) Get(prefix GERTcPrefix) (interface{}, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	value, ok := t.routes[prefix.Bits][maskKey(gertcKey(prefix.Addr), prefix.Bits, GERTcBits)]
+	return value, ok
+}
+
+// Lookup finds the route with the longest prefix containing addr.
+// It returns the prefix and value of the route and whether one was found.
+func (t *RoutingTable) Lookup(addr GERTc) (GERTcPrefix, interface{}, bool) {
+	return t.lookup(addr, GERTcBits)
+}
+
+// LookupAddress finds the route with the longest prefix containing every GERTc behind the GERTe address addr,
+// routes that depend on the GERTi address are ignored.
+// It returns the prefix and value of the route and whether one was found.
+func (t *RoutingTable) LookupAddress(addr GertAddress) (GERTcPrefix, interface{}, bool) {
+	return t.lookup(GERTc{GERTe: addr}, AddressBits)
+}
+
+// lookup finds the longest route for addr that is at most maxBits long
+func (t *RoutingTable) lookup(addr GERTc, maxBits int) (GERTcPrefix, interface{}, bool) {
+	if addr.Validate() != nil {
+		return GERTcPrefix{}, nil, false
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	key := gertcKey(addr)
+	for _, bits := range t.lengths {
+		if bits > maxBits {
+			continue
+		}
+		masked := maskKey(key, bits, GERTcBits)
+		if value, ok := t.routes[bits][masked]; ok {
+			return GERTcPrefix{Addr: gertcFromKey(masked), Bits: bits}, value, true
+		}
+	}
+	return GERTcPrefix{}, nil, false
+}
+
+// Len returns the number of routes
+func (t *RoutingTable) Len() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	n := 0
+	for _, routes := range t.routes {
+		n += len(routes)
+	}
+	return n
+}
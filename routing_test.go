@@ -0,0 +1,81 @@
+package gerte
+
+import "testing"
+
+func TestRoutingTable(t *testing.T) {
+	table := NewRoutingTable()
+	routes := map[string]string{
+		"*":                   "default",
+		"1123.*":              "block",
+		"1123.1456:*":         "gateway",
+		"1123.1456:0001.0001": "exact",
+		"1123.1456/20":        "cidr",
+	}
+	for pattern, value := range routes {
+		prefix, err := ParseGERTcPrefix(pattern)
+		if err != nil {
+			t.Fatalf("error on parse %q: %+v", pattern, err)
+		}
+		if table.Insert(prefix, value) {
+			t.Errorf("route for %v was replaced", prefix)
+		}
+	}
+	if table.Len() != len(routes) {
+		t.Errorf("got %v routes, want %v", table.Len(), len(routes))
+	}
+
+	gateway := GertAddress{Upper: 1123, Lower: 1456}
+	tests := []struct {
+		name string
+		addr GERTc
+		want string
+	}{
+		{"RouteExact", GERTc{GERTe: gateway, GERTi: GertAddress{Upper: 1, Lower: 1}}, "exact"},
+		{"RouteGateway", GERTc{GERTe: gateway, GERTi: GertAddress{Upper: 1, Lower: 2}}, "gateway"},
+		{"RouteCIDR", GERTc{GERTe: GertAddress{Upper: 1123, Lower: 1460}}, "cidr"},
+		{"RouteBlock", GERTc{GERTe: GertAddress{Upper: 1123, Lower: 1}}, "block"},
+		{"RouteDefault", GERTc{GERTe: GertAddress{Upper: 2345, Lower: 1456}}, "default"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefix, value, ok := table.Lookup(tt.addr)
+			if !ok || value != tt.want {
+				t.Fatalf("got %v %v, want %v", value, ok, tt.want)
+			}
+			if !prefix.Contains(tt.addr) {
+				t.Errorf("matched prefix %v doesn't contain %v", prefix, tt.addr)
+			}
+		})
+	}
+
+	t.Run("RouteAddress", func(t *testing.T) {
+		// the exact route depends on the GERTi address, so the gateway route is the longest for a GERTe address
+		if _, value, ok := table.LookupAddress(gateway); !ok || value != "gateway" {
+			t.Errorf("got %v %v, want gateway", value, ok)
+		}
+	})
+	t.Run("RouteDelete", func(t *testing.T) {
+		gatewayPrefix := GERTcPrefix{Addr: GERTc{GERTe: gateway}, Bits: 24}
+		if !table.Delete(gatewayPrefix) || table.Delete(gatewayPrefix) {
+			t.Error("route was not deleted exactly once")
+		}
+		if _, value, _ := table.LookupAddress(gateway); value != "cidr" {
+			t.Errorf("got %v, want cidr", value)
+		}
+		if _, ok := table.Get(gatewayPrefix); ok {
+			t.Error("deleted route was found")
+		}
+		if !table.Insert(GERTcPrefix{}, "replaced") {
+			t.Error("default route was not replaced")
+		}
+		if _, value, _ := table.Lookup(GERTc{GERTe: GertAddress{Upper: 1}}); value != "replaced" {
+			t.Errorf("got %v, want replaced", value)
+		}
+	})
+	t.Run("RouteEmpty", func(t *testing.T) {
+		var empty RoutingTable
+		if _, _, ok := empty.Lookup(GERTc{}); ok || empty.Delete(GERTcPrefix{}) || empty.Len() != 0 {
+			t.Error("empty table has routes")
+		}
+	})
+}
@@ -0,0 +1,212 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/rpc"
+	"sync"
+
+	"github.com/OmegaRogue/gerte-go"
+)
+
+type (
+	// clientCodec implements rpc.ClientCodec, every request is sent with Node.Call and bodies are encoded as JSON
+	clientCodec struct {
+		node   *Node
+		target gerte.GERTc
+
+		ctx     context.Context
+		cancel  context.CancelFunc
+		results chan clientResult
+		body    []byte
+	}
+
+	// clientResult is the outcome of a call of the net/rpc client
+	clientResult struct {
+		seq    uint64
+		method string
+		body   []byte
+		err    error
+	}
+
+	// serverCodec implements rpc.ServerCodec, it receives the requests for all methods without a handler of the Node
+	serverCodec struct {
+		ctx      context.Context
+		cancel   context.CancelFunc
+		requests chan serverRequest
+
+		mu      sync.Mutex
+		seq     uint64
+		pending map[uint64]chan serverReply
+		body    []byte
+	}
+
+	// serverRequest is a request waiting to be read by the net/rpc server
+	serverRequest struct {
+		method string
+		body   []byte
+		reply  chan serverReply
+	}
+
+	// serverReply is the response written by the net/rpc server
+	serverReply struct {
+		body []byte
+		err  error
+	}
+)
+
+// NewClientCodec returns a codec for the net/rpc client that calls the methods of the Node at target through n.
+// Request and response bodies are encoded as JSON and have to fit into a single packet.
+func NewClientCodec(n *Node, target gerte.GERTc) rpc.ClientCodec {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &clientCodec{
+		node:    n,
+		target:  target,
+		ctx:     ctx,
+		cancel:  cancel,
+		results: make(chan clientResult),
+	}
+}
+
+// NewClient returns a net/rpc client that calls the methods of the Node at target through n
+func NewClient(n *Node, target gerte.GERTc) *rpc.Client {
+	return rpc.NewClientWithCodec(NewClientCodec(n, target))
+}
+
+// WriteRequest starts the call of r with the JSON encoded body in the background
+func (c *clientCodec) WriteRequest(r *rpc.Request, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	seq, method := r.Seq, r.ServiceMethod
+	go func() {
+		res, err := c.node.Call(c.ctx, c.target, method, data)
+		select {
+		case c.results <- clientResult{seq: seq, method: method, body: res, err: err}:
+		case <-c.ctx.Done():
+		}
+	}()
+	return nil
+}
+
+// ReadResponseHeader waits for the next finished call, failed calls are reported through r.Error
+func (c *clientCodec) ReadResponseHeader(r *rpc.Response) error {
+	select {
+	case res := <-c.results:
+		r.Seq = res.seq
+		r.ServiceMethod = res.method
+		r.Error = ""
+		c.body = res.body
+		if res.err != nil {
+			r.Error = res.err.Error()
+			c.body = nil
+		}
+		return nil
+	case <-c.ctx.Done():
+		return io.EOF
+	}
+}
+
+// ReadResponseBody decodes the body of the last response into x
+func (c *clientCodec) ReadResponseBody(x interface{}) error {
+	if x == nil || c.body == nil {
+		return nil
+	}
+	return json.Unmarshal(c.body, x)
+}
+
+// Close cancels all running calls
+func (c *clientCodec) Close() error {
+	c.cancel()
+	return nil
+}
+
+// NewServerCodec returns a codec for the net/rpc server that serves the requests sent to n.
+// It registers itself with HandleDefault, so methods with a handler of their own are still served by the Node.
+// Request and response bodies are encoded as JSON and have to fit into a single packet.
+func NewServerCodec(n *Node) rpc.ServerCodec {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &serverCodec{
+		ctx:      ctx,
+		cancel:   cancel,
+		requests: make(chan serverRequest),
+		pending:  make(map[uint64]chan serverReply),
+	}
+	n.HandleDefault(c.handle)
+	return c
+}
+
+// handle passes a request to the net/rpc server and waits for its response
+func (c *serverCodec) handle(ctx context.Context, req *Request) ([]byte, error) {
+	reply := make(chan serverReply, 1)
+	select {
+	case c.requests <- serverRequest{method: req.Method, body: req.Body, reply: reply}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.ctx.Done():
+		return nil, rpc.ErrShutdown
+	}
+	select {
+	case res := <-reply:
+		return res.body, res.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.ctx.Done():
+		return nil, rpc.ErrShutdown
+	}
+}
+
+// ReadRequestHeader waits for the next request
+func (c *serverCodec) ReadRequestHeader(r *rpc.Request) error {
+	select {
+	case req := <-c.requests:
+		c.mu.Lock()
+		c.seq++
+		c.pending[c.seq] = req.reply
+		r.Seq = c.seq
+		c.mu.Unlock()
+		r.ServiceMethod = req.method
+		c.body = req.body
+		return nil
+	case <-c.ctx.Done():
+		return io.EOF
+	}
+}
+
+// ReadRequestBody decodes the body of the last request into x
+func (c *serverCodec) ReadRequestBody(x interface{}) error {
+	if x == nil {
+		return nil
+	}
+	return json.Unmarshal(c.body, x)
+}
+
+// WriteResponse sends the JSON encoded body, or the error of r, to the caller
+func (c *serverCodec) WriteResponse(r *rpc.Response, body interface{}) error {
+	c.mu.Lock()
+	reply, ok := c.pending[r.Seq]
+	delete(c.pending, r.Seq)
+	c.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	if r.Error != "" {
+		reply <- serverReply{err: RemoteError(r.Error)}
+		return nil
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		reply <- serverReply{err: err}
+		return err
+	}
+	reply <- serverReply{body: data}
+	return nil
+}
+
+// Close stops reading requests, the Node is not closed
+func (c *serverCodec) Close() error {
+	c.cancel()
+	return nil
+}
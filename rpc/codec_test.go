@@ -0,0 +1,81 @@
+package rpc
+
+import (
+	"errors"
+	"net/rpc"
+	"testing"
+)
+
+type (
+	Args struct {
+		A, B int
+	}
+
+	Arith int
+)
+
+func (*Arith) Add(args Args, reply *int) error {
+	*reply = args.A + args.B
+	return nil
+}
+
+func (*Arith) Div(args Args, reply *int) error {
+	if args.B == 0 {
+		return errors.New("divide by zero")
+	}
+	*reply = args.A / args.B
+	return nil
+}
+
+func TestCodec(t *testing.T) {
+	a, b := nodePair(0)
+	defer a.Close()
+	defer b.Close()
+
+	srv := rpc.NewServer()
+	if err := srv.Register(new(Arith)); err != nil {
+		t.Fatalf("error on register: %+v", err)
+	}
+	codec := NewServerCodec(b)
+	go srv.ServeCodec(codec)
+	client := NewClient(a, b.Addr())
+	defer client.Close()
+
+	t.Run("CodecCall", func(t *testing.T) {
+		var reply int
+		if err := client.Call("Arith.Add", Args{A: 7, B: 8}, &reply); err != nil {
+			t.Fatalf("error on call: %+v", err)
+		}
+		if reply != 15 {
+			t.Errorf("got %v, want %v", reply, 15)
+		}
+	})
+	t.Run("CodecConcurrent", func(t *testing.T) {
+		calls := make([]*rpc.Call, 10)
+		for i := range calls {
+			calls[i] = client.Go("Arith.Add", Args{A: i, B: i}, new(int), nil)
+		}
+		for i, call := range calls {
+			<-call.Done
+			if call.Error != nil {
+				t.Fatalf("error on call %v: %+v", i, call.Error)
+			}
+			if got := *call.Reply.(*int); got != 2*i {
+				t.Errorf("call %v: got %v, want %v", i, got, 2*i)
+			}
+		}
+	})
+	t.Run("CodecError", func(t *testing.T) {
+		var reply int
+		err := client.Call("Arith.Div", Args{A: 1}, &reply)
+		if err == nil || err.Error() != "divide by zero" {
+			t.Errorf("got %+v, want %v", err, "divide by zero")
+		}
+	})
+	t.Run("CodecUnknownMethod", func(t *testing.T) {
+		var reply int
+		if err := client.Call("Arith.Mul", Args{}, &reply); err == nil {
+			t.Error("call of unknown method succeeded")
+		}
+	})
+}
@@ -0,0 +1,72 @@
+package rpc
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// kind is the type of an envelope
+type kind byte
+
+const (
+	// kindRequest asks the remote Node to call a method
+	kindRequest kind = iota
+	// kindResponse carries the result of a method
+	kindResponse
+	// kindError carries the error message of a failed method
+	kindError
+)
+
+// headerSize is the size of the envelope header without the method name.
+// It holds the kind, the 2 byte correlation ID and the length of the method name.
+const headerSize = 4
+
+// maxData is the amount of data a single packet can carry
+const maxData = 255
+
+// envelope wraps the body of a request or response with its correlation ID and method name
+type envelope struct {
+	kind   kind
+	id     uint16
+	method string
+	body   []byte
+}
+
+// toBytes converts an envelope to bytes for sending.
+// It returns the bytes and any encountered errors.
+func (env envelope) toBytes() ([]byte, error) {
+	if len(env.method) > 255 {
+		return nil, fmt.Errorf("method name cannot exceed 255 bytes")
+	}
+	size := headerSize + len(env.method) + len(env.body)
+	if size > maxData {
+		return nil, fmt.Errorf("%w: %v>%v", ErrTooLarge, size, maxData)
+	}
+	b := make([]byte, headerSize, size)
+	b[0] = byte(env.kind)
+	binary.BigEndian.PutUint16(b[1:], env.id)
+	b[3] = byte(len(env.method))
+	b = append(b, env.method...)
+	return append(b, env.body...), nil
+}
+
+// envelopeFromBytes parses bytes to an envelope
+func envelopeFromBytes(data []byte) (envelope, error) {
+	if len(data) < headerSize {
+		return envelope{}, fmt.Errorf("envelope too short: %v<%v", len(data), headerSize)
+	}
+	env := envelope{
+		kind: kind(data[0]),
+		id:   binary.BigEndian.Uint16(data[1:]),
+	}
+	if env.kind > kindError {
+		return envelope{}, fmt.Errorf("invalid envelope kind %v", data[0])
+	}
+	end := headerSize + int(data[3])
+	if len(data) < end {
+		return envelope{}, fmt.Errorf("method name exceeds envelope: %v<%v", len(data), end)
+	}
+	env.method = string(data[headerSize:end])
+	env.body = data[end:]
+	return env, nil
+}
@@ -0,0 +1,37 @@
+package rpc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEnvelope(t *testing.T) {
+	env := envelope{kind: kindRequest, id: 0x1234, method: "echo", body: []byte("hello")}
+	data, err := env.toBytes()
+	if err != nil {
+		t.Fatalf("error on convert envelope: %+v", err)
+	}
+	want := append([]byte{0, 0x12, 0x34, 4}, "echohello"...)
+	if !reflect.DeepEqual(data, want) {
+		t.Errorf("got %v, want %v", data, want)
+	}
+	got, err := envelopeFromBytes(data)
+	if err != nil {
+		t.Fatalf("error on parse envelope: %+v", err)
+	}
+	if !reflect.DeepEqual(got, env) {
+		t.Errorf("got %+v, want %+v", got, env)
+	}
+
+	for name, data := range map[string][]byte{
+		"Short":  {0, 0, 0},
+		"Kind":   {9, 0, 0, 0},
+		"Method": {0, 0, 0, 5, 'a'},
+	} {
+		t.Run("EnvelopeInvalid"+name, func(t *testing.T) {
+			if _, err := envelopeFromBytes(data); err == nil {
+				t.Errorf("parsed invalid envelope %v", data)
+			}
+		})
+	}
+}
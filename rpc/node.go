@@ -0,0 +1,374 @@
+// Package rpc provides request/response calls between GERTi hosts on top of GERTe packets.
+// Every packet carries an envelope with a correlation ID and the method name, so responses are matched to their calls
+// and requests are dispatched to the handler registered for their method.
+// The envelope and body have to fit into a single packet.
+package rpc
+
+import (
+	"bytes"
+	"context"
+	crand "crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/OmegaRogue/gerte-go"
+)
+
+const (
+	// DefaultRetryInterval is the time a call waits for a response before the request is sent again
+	DefaultRetryInterval = time.Second
+	// DefaultRetries is the number of times a request is sent again before the call fails
+	DefaultRetries = 3
+	// DefaultDedupWindow is the time a Node remembers handled requests, so retried requests aren't handled twice
+	DefaultDedupWindow = 30 * time.Second
+)
+
+var (
+	// ErrTooLarge is returned when the envelope and body don't fit into a single packet
+	ErrTooLarge = errors.New("rpc: message too large")
+	// ErrTimeout is returned when no response arrived after all retries
+	ErrTimeout = errors.New("rpc: call timed out")
+	// ErrClosed is returned when using a closed Node
+	ErrClosed = errors.New("rpc: node closed")
+)
+
+// RemoteError is the error message returned by the handler of the remote Node
+type RemoteError string
+
+// Error returns the error message
+func (e RemoteError) Error() string {
+	return string(e)
+}
+
+type (
+	// Request is a request received by a Node
+	Request struct {
+		// From is the GERTc of the caller
+		From gerte.GERTc
+		// Method is the name of the called method
+		Method string
+		// Body is the request body sent by the caller
+		Body []byte
+	}
+
+	// Handler handles a request.
+	// It returns the response body and any encountered errors, the error message is sent to the caller as a RemoteError.
+	// The context is canceled when the Node is closed.
+	Handler func(ctx context.Context, req *Request) ([]byte, error)
+
+	// Node sends calls and serves the handlers registered for its methods over the receive loop of a single Api.
+	// The exported fields must not be changed while calls are running.
+	Node struct {
+		// RetryInterval is the time a call waits for a response before the request is sent again
+		RetryInterval time.Duration
+		// Retries is the number of times a request is sent again before the call fails
+		Retries int
+		// DedupWindow is the time handled requests are remembered, retried requests within it get the same response
+		DedupWindow time.Duration
+
+		local    gerte.GERTc
+		transmit func(ctx context.Context, pkt gerte.Packet) (bool, error)
+		api      *gerte.Api
+
+		mu       sync.Mutex
+		handlers map[string]Handler
+		fallback Handler
+		calls    map[uint16]*call
+		nextID   uint16
+		served   map[requestKey]*served
+		// handled are the served requests in the order they were handled, so expire only looks at the oldest ones
+		handled []*served
+
+		closeOnce sync.Once
+		closed    chan struct{}
+	}
+
+	// call is a call waiting for its response
+	call struct {
+		target gerte.GERTc
+		reply  chan envelope
+	}
+
+	// requestKey identifies a request by its sender and correlation ID
+	requestKey struct {
+		from gerte.GERTc
+		id   uint16
+	}
+
+	// served is a request that is handled or was handled recently, at is the time it was handled
+	served struct {
+		key      requestKey
+		at       time.Time
+		method   string
+		body     []byte
+		done     bool
+		response []byte
+	}
+)
+
+// NewNode is the constructor for Node, it takes over the receive loop of a registered api.
+// It returns the Node and any encountered errors.
+// The Node uses the registered address of api together with the GERTi address local as its address.
+func NewNode(api *gerte.Api, local gerte.GertAddress) (*Node, error) {
+	addr, _ := api.Registration()
+	n := newNode(gerte.GERTc{GERTe: addr, GERTi: local}, api.TransmitContext)
+	n.api = api
+	if err := api.Receive(n.deliver); err != nil {
+		return nil, fmt.Errorf("error on start receive loop: %w", err)
+	}
+	return n, nil
+}
+
+// newNode creates a Node sending its packets with transmit, packets have to be passed to deliver
+func newNode(local gerte.GERTc, transmit func(ctx context.Context, pkt gerte.Packet) (bool, error)) *Node {
+	return &Node{
+		RetryInterval: DefaultRetryInterval,
+		Retries:       DefaultRetries,
+		DedupWindow:   DefaultDedupWindow,
+		local:         local,
+		transmit:      transmit,
+		handlers:      make(map[string]Handler),
+		calls:         make(map[uint16]*call),
+		nextID:        randomID(),
+		served:        make(map[requestKey]*served),
+		closed:        make(chan struct{}),
+	}
+}
+
+// Handle registers the handler for method, replacing any previous one
+func (n *Node) Handle(method string, h Handler) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.handlers[method] = h
+}
+
+// HandleDefault registers the handler for all methods without a handler of their own
+func (n *Node) HandleDefault(h Handler) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.fallback = h
+}
+
+// Call calls method on the Node at target and waits for the response.
+// It returns the response body and any encountered errors, a RemoteError if the remote handler failed.
+// The request is sent again every RetryInterval until a response arrives, up to Retries times.
+func (n *Node) Call(ctx context.Context, target gerte.GERTc, method string, req []byte) ([]byte, error) {
+	n.mu.Lock()
+	if isClosed(n.closed) {
+		n.mu.Unlock()
+		return nil, ErrClosed
+	}
+	id := n.nextID
+	for {
+		if _, ok := n.calls[id]; !ok {
+			break
+		}
+		id++
+	}
+	n.nextID = id + 1
+	c := &call{
+		target: target,
+		reply:  make(chan envelope, 1),
+	}
+	n.calls[id] = c
+	n.mu.Unlock()
+	defer func() {
+		n.mu.Lock()
+		delete(n.calls, id)
+		n.mu.Unlock()
+	}()
+
+	data, err := envelope{kind: kindRequest, id: id, method: method, body: req}.toBytes()
+	if err != nil {
+		return nil, err
+	}
+	pkt := gerte.Packet{
+		Source: n.local,
+		Target: target,
+		Data:   data,
+	}
+	var done <-chan struct{}
+	if n.api != nil {
+		done = n.api.Done()
+	}
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	<-timer.C
+	for attempt := 0; attempt <= n.Retries; attempt++ {
+		if _, err := n.transmit(ctx, pkt); err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, fmt.Errorf("error on transmit request: %w", err)
+		}
+		timer.Reset(n.RetryInterval)
+		select {
+		case env := <-c.reply:
+			if env.kind == kindError {
+				return nil, RemoteError(env.body)
+			}
+			return env.body, nil
+		case <-timer.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-n.closed:
+			return nil, ErrClosed
+		case <-done:
+			return nil, ErrClosed
+		}
+	}
+	return nil, ErrTimeout
+}
+
+// Close stops the Node, running calls fail with ErrClosed.
+// The Api is not shut down.
+func (n *Node) Close() error {
+	n.closeOnce.Do(func() {
+		n.mu.Lock()
+		close(n.closed)
+		n.mu.Unlock()
+	})
+	return nil
+}
+
+// Addr returns the GERTc of the Node
+func (n *Node) Addr() gerte.GERTc {
+	return n.local
+}
+
+// deliver handles a packet received from the relay, it is called from the receive loop and never blocks
+func (n *Node) deliver(pkt gerte.Packet) {
+	if pkt.Target.GERTi != n.local.GERTi {
+		return
+	}
+	env, err := envelopeFromBytes(pkt.Data)
+	if err != nil {
+		return
+	}
+	if env.kind != kindRequest {
+		n.mu.Lock()
+		c, ok := n.calls[env.id]
+		n.mu.Unlock()
+		if ok && c.target == pkt.Source {
+			select {
+			case c.reply <- env:
+			default:
+			}
+		}
+		return
+	}
+
+	key := requestKey{from: pkt.Source, id: env.id}
+	now := time.Now()
+	n.mu.Lock()
+	if isClosed(n.closed) {
+		n.mu.Unlock()
+		return
+	}
+	n.expire(now)
+	if s, ok := n.served[key]; ok && s.method == env.method && bytes.Equal(s.body, env.body) {
+		// a retried request, answer it once the first one was handled
+		response, done := s.response, s.done
+		n.mu.Unlock()
+		if done {
+			go n.send(pkt.Source, response)
+		}
+		return
+	}
+	// a different request reusing the ID, like one of a restarted caller, replaces the remembered one
+	s := &served{key: key, method: env.method, body: env.body}
+	n.served[key] = s
+	h, ok := n.handlers[env.method]
+	if !ok {
+		h = n.fallback
+	}
+	n.mu.Unlock()
+	go n.serve(pkt.Source, env, h, s)
+}
+
+// serve runs the handler of a request and sends its response
+func (n *Node) serve(from gerte.GERTc, env envelope, h Handler, s *served) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-n.closed:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	res := envelope{kind: kindResponse, id: env.id}
+	var err error
+	if h == nil {
+		err = fmt.Errorf("rpc: can't find method %v", env.method)
+	} else {
+		res.body, err = h(ctx, &Request{From: from, Method: env.method, Body: env.body})
+	}
+	if err == nil && headerSize+len(res.body) > maxData {
+		err = fmt.Errorf("%w: %v>%v", ErrTooLarge, headerSize+len(res.body), maxData)
+	}
+	if err != nil {
+		res.kind = kindError
+		res.body = []byte(err.Error())
+		if len(res.body) > maxData-headerSize {
+			res.body = res.body[:maxData-headerSize]
+		}
+	}
+	data, _ := res.toBytes()
+
+	n.mu.Lock()
+	s.done = true
+	s.at = time.Now()
+	s.response = data
+	n.handled = append(n.handled, s)
+	n.mu.Unlock()
+	n.send(from, data)
+}
+
+// send transmits an encoded response, lost responses are recovered by the caller retrying the request
+func (n *Node) send(to gerte.GERTc, data []byte) {
+	ctx, cancel := context.WithTimeout(context.Background(), n.RetryInterval)
+	defer cancel()
+	_, _ = n.transmit(ctx, gerte.Packet{
+		Source: n.local,
+		Target: to,
+		Data:   data,
+	})
+}
+
+// expire forgets requests handled longer than DedupWindow ago, n.mu must be held
+func (n *Node) expire(now time.Time) {
+	for len(n.handled) > 0 && now.Sub(n.handled[0].at) > n.DedupWindow {
+		s := n.handled[0]
+		n.handled[0] = nil
+		n.handled = n.handled[1:]
+		// the request may have been replaced by a different one reusing its ID
+		if n.served[s.key] == s {
+			delete(n.served, s.key)
+		}
+	}
+}
+
+// randomID returns a random first correlation ID,
+// so a restarted caller doesn't reuse the IDs of the requests the remote Node still remembers
+func randomID() uint16 {
+	var b [2]byte
+	if _, err := crand.Read(b[:]); err != nil {
+		return uint16(time.Now().UnixNano())
+	}
+	return binary.BigEndian.Uint16(b[:])
+}
+
+// isClosed returns whether the channel c is closed
+func isClosed(c chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
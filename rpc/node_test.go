@@ -0,0 +1,257 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/OmegaRogue/gerte-go"
+	"github.com/OmegaRogue/gerte-go/geds"
+)
+
+// link connects two nodes in memory, dropping the first drop packets
+type link struct {
+	mu   sync.Mutex
+	drop int
+	sent int
+}
+
+func (l *link) transmit(to func() *Node) func(ctx context.Context, pkt gerte.Packet) (bool, error) {
+	return func(ctx context.Context, pkt gerte.Packet) (bool, error) {
+		l.mu.Lock()
+		l.sent++
+		drop := l.sent <= l.drop
+		l.mu.Unlock()
+		if !drop {
+			go to().deliver(pkt)
+		}
+		return true, nil
+	}
+}
+
+func nodePair(drop int) (*Node, *Node) {
+	l := &link{drop: drop}
+	var a, b *Node
+	a = newNode(gerte.GERTc{GERTe: gerte.GertAddress{Upper: 1, Lower: 1}}, l.transmit(func() *Node { return b }))
+	b = newNode(gerte.GERTc{GERTe: gerte.GertAddress{Upper: 2, Lower: 2}}, l.transmit(func() *Node { return a }))
+	for _, n := range []*Node{a, b} {
+		n.RetryInterval = 20 * time.Millisecond
+	}
+	return a, b
+}
+
+func TestNode_Call(t *testing.T) {
+	a, b := nodePair(0)
+	defer a.Close()
+	defer b.Close()
+	b.Handle("echo", func(ctx context.Context, req *Request) ([]byte, error) {
+		if req.From != a.Addr() {
+			return nil, fmt.Errorf("unexpected caller %v", req.From)
+		}
+		return req.Body, nil
+	})
+	b.Handle("fail", func(ctx context.Context, req *Request) ([]byte, error) {
+		return nil, errors.New("failed")
+	})
+	ctx := context.Background()
+
+	t.Run("CallSuccessful", func(t *testing.T) {
+		res, err := a.Call(ctx, b.Addr(), "echo", []byte("hello"))
+		if err != nil {
+			t.Fatalf("error on call: %+v", err)
+		}
+		if string(res) != "hello" {
+			t.Errorf("got %q, want %q", res, "hello")
+		}
+	})
+	t.Run("CallRemoteError", func(t *testing.T) {
+		_, err := a.Call(ctx, b.Addr(), "fail", nil)
+		if err != RemoteError("failed") {
+			t.Errorf("got %+v, want %+v", err, RemoteError("failed"))
+		}
+	})
+	t.Run("CallUnknownMethod", func(t *testing.T) {
+		_, err := a.Call(ctx, b.Addr(), "missing", nil)
+		var remote RemoteError
+		if !errors.As(err, &remote) {
+			t.Errorf("expected remote error, got %+v", err)
+		}
+	})
+	t.Run("CallTooLarge", func(t *testing.T) {
+		_, err := a.Call(ctx, b.Addr(), "echo", make([]byte, 255))
+		if !errors.Is(err, ErrTooLarge) {
+			t.Errorf("got %+v, want %+v", err, ErrTooLarge)
+		}
+	})
+	t.Run("CallTimeout", func(t *testing.T) {
+		_, err := a.Call(ctx, gerte.GERTc{GERTe: gerte.GertAddress{Upper: 3, Lower: 3}}, "echo", nil)
+		if err != ErrTimeout {
+			t.Errorf("got %+v, want %+v", err, ErrTimeout)
+		}
+	})
+	t.Run("CallContext", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		_, err := a.Call(ctx, gerte.GERTc{GERTe: gerte.GertAddress{Upper: 3, Lower: 3}}, "echo", nil)
+		if err != context.DeadlineExceeded {
+			t.Errorf("got %+v, want %+v", err, context.DeadlineExceeded)
+		}
+	})
+	t.Run("CallClosed", func(t *testing.T) {
+		c, _ := nodePair(0)
+		c.Close()
+		if _, err := c.Call(ctx, b.Addr(), "echo", nil); err != ErrClosed {
+			t.Errorf("got %+v, want %+v", err, ErrClosed)
+		}
+	})
+}
+
+func TestNode_Retry(t *testing.T) {
+	// the first request and the first response are lost
+	a, b := nodePair(2)
+	defer a.Close()
+	defer b.Close()
+	var mu sync.Mutex
+	calls := 0
+	b.Handle("count", func(ctx context.Context, req *Request) ([]byte, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		return []byte{byte(calls)}, nil
+	})
+
+	res, err := a.Call(context.Background(), b.Addr(), "count", nil)
+	if err != nil {
+		t.Fatalf("error on call: %+v", err)
+	}
+	if !bytes.Equal(res, []byte{1}) {
+		t.Errorf("got %v, want %v", res, []byte{1})
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if calls != 1 {
+		t.Errorf("retried request was handled %v times", calls)
+	}
+}
+
+func TestNode_DedupWindow(t *testing.T) {
+	a, b := nodePair(0)
+	defer a.Close()
+	defer b.Close()
+	b.DedupWindow = 50 * time.Millisecond
+	b.Handle("echo", func(ctx context.Context, req *Request) ([]byte, error) {
+		return req.Body, nil
+	})
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		if _, err := a.Call(ctx, b.Addr(), "echo", []byte{byte(i)}); err != nil {
+			t.Fatalf("error on call: %+v", err)
+		}
+	}
+	time.Sleep(2 * b.DedupWindow)
+	if _, err := a.Call(ctx, b.Addr(), "echo", nil); err != nil {
+		t.Fatalf("error on call: %+v", err)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.served) != 1 || len(b.handled) != 1 {
+		t.Errorf("%v requests are remembered, %v in handling order, want 1", len(b.served), len(b.handled))
+	}
+}
+
+func TestNode_CallerRestart(t *testing.T) {
+	l := &link{}
+	var caller *Node
+	callerAddr := gerte.GERTc{GERTe: gerte.GertAddress{Upper: 1, Lower: 1}}
+	b := newNode(gerte.GERTc{GERTe: gerte.GertAddress{Upper: 2, Lower: 2}}, l.transmit(func() *Node { return caller }))
+	defer b.Close()
+	b.Handle("echo", func(ctx context.Context, req *Request) ([]byte, error) {
+		return req.Body, nil
+	})
+	b.Handle("other", func(ctx context.Context, req *Request) ([]byte, error) {
+		return []byte("other"), nil
+	})
+	restart := func(id uint16) *Node {
+		n := newNode(callerAddr, l.transmit(func() *Node { return b }))
+		n.RetryInterval = 20 * time.Millisecond
+		n.nextID = id
+		caller = n
+		return n
+	}
+
+	first := restart(7)
+	if res, err := first.Call(context.Background(), b.Addr(), "echo", []byte("first")); err != nil || string(res) != "first" {
+		t.Fatalf("got %q %+v, want %q", res, err, "first")
+	}
+	first.Close()
+
+	// the restarted callers reuse the correlation ID of the remembered request
+	calls := []struct {
+		method, body, want string
+	}{
+		{"echo", "second", "second"},
+		{"other", "", "other"},
+	}
+	for _, c := range calls {
+		n := restart(7)
+		res, err := n.Call(context.Background(), b.Addr(), c.method, []byte(c.body))
+		n.Close()
+		if err != nil || string(res) != c.want {
+			t.Errorf("%v got %q %+v, want %q", c.method, res, err, c.want)
+		}
+	}
+	if a, b := newNode(callerAddr, nil), newNode(callerAddr, nil); a.nextID == b.nextID && a.nextID == newNode(callerAddr, nil).nextID {
+		t.Error("new nodes start with the same correlation ID")
+	}
+}
+
+func TestNewNode(t *testing.T) {
+	ver := gerte.Version{Major: 1, Minor: 1}
+	key := "aaaaaaaaaaaaaaaaaaaa"
+	addrA := gerte.GertAddress{Upper: 1123, Lower: 1456}
+	addrB := gerte.GertAddress{Upper: 2345, Lower: 1456}
+	srv := geds.NewServer(ver, geds.Resolutions{addrA: key, addrB: key})
+	defer srv.Close()
+
+	connect := func(addr gerte.GertAddress) *gerte.Api {
+		server, client := net.Pipe()
+		go srv.ServeConn(server)
+		api := gerte.NewApi(ver)
+		if err := api.Startup(client); err != nil {
+			t.Fatalf("error on startup: %+v", err)
+		}
+		if _, err := api.Register(addr, key); err != nil {
+			t.Fatalf("error on register: %+v", err)
+		}
+		return api
+	}
+	apiA := connect(addrA)
+	defer apiA.Shutdown()
+	apiB := connect(addrB)
+	defer apiB.Shutdown()
+
+	a, err := NewNode(apiA, gerte.GertAddress{})
+	if err != nil {
+		t.Fatalf("error on create node: %+v", err)
+	}
+	b, err := NewNode(apiB, gerte.GertAddress{Upper: 1, Lower: 1})
+	if err != nil {
+		t.Fatalf("error on create node: %+v", err)
+	}
+	b.Handle("upper", func(ctx context.Context, req *Request) ([]byte, error) {
+		return bytes.ToUpper(req.Body), nil
+	})
+
+	res, err := a.Call(context.Background(), b.Addr(), "upper", []byte("hello"))
+	if err != nil {
+		t.Fatalf("error on call: %+v", err)
+	}
+	if string(res) != "HELLO" {
+		t.Errorf("got %q, want %q", res, "HELLO")
+	}
+}
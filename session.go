@@ -0,0 +1,206 @@
+package gerte
+
+import (
+	"errors"
+	"fmt"
+)
+
+type (
+	// SessionState indicates the state of the session of an Api with its relay
+	SessionState byte
+
+	// SessionChange describes a transition of the session state, it is passed to the hooks added with OnSessionChange
+	SessionChange struct {
+		From SessionState
+		To   SessionState
+		// Err is the error that caused a transition to SessionFailed
+		Err error
+	}
+
+	// sessionHook is a hook added with OnSessionChange
+	sessionHook struct {
+		fn func(SessionChange)
+	}
+
+	// sessionOp is an operation checked against the session state by checkState
+	sessionOp byte
+)
+
+const (
+	// SessionClosed indicates that there is no connection, either before Startup or after the session was closed
+	SessionClosed SessionState = iota
+	// SessionConnecting indicates that the connection was handed to Startup and the version is being negotiated
+	SessionConnecting
+	// SessionConnected indicates that the version was negotiated, the gateway can register
+	SessionConnected
+	// SessionAssigned indicates that the gateway registered an address, it can send data
+	SessionAssigned
+	// SessionFailed indicates that the relay refused the connection or that the connection broke
+	SessionFailed
+)
+
+const (
+	opStartup sessionOp = iota
+	opRegister
+	opQueryState
+	opTransmit
+	opShutdown
+)
+
+var (
+	// ErrNotConnected is returned for operations that need a connection while there is none
+	ErrNotConnected = errors.New("not connected")
+	// ErrAlreadyConnected is returned by Startup if the Api already has a connection
+	ErrAlreadyConnected = errors.New("already connected")
+	// ErrNotRegistered is returned when sending data before registering an address
+	ErrNotRegistered = errors.New("not registered")
+	// ErrAlreadyRegistered is returned by Register if the Api already registered an address
+	ErrAlreadyRegistered = errors.New("already registered")
+	// ErrClosedByRelay is returned when the relay closed the connection
+	ErrClosedByRelay = errors.New("connection closed by relay")
+)
+
+// String prints a SessionState to a Human-readable string
+func (state SessionState) String() string {
+	switch state {
+	case SessionClosed:
+		return "CLOSED"
+	case SessionConnecting:
+		return "CONNECTING"
+	case SessionConnected:
+		return "CONNECTED"
+	case SessionAssigned:
+		return "ASSIGNED"
+	case SessionFailed:
+		return "FAILED"
+	}
+	return "nil"
+}
+
+// open returns whether the session has a connection in state
+func (state SessionState) open() bool {
+	return state == SessionConnecting || state == SessionConnected || state == SessionAssigned
+}
+
+// SessionState returns the current state of the session
+func (api *Api) SessionState() SessionState {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	return api.state
+}
+
+// OnSessionChange adds a hook that is called on every transition of the session state, including the relay closing the connection.
+// It returns a function removing the hook again.
+// Hooks are called one at a time in the order of the transitions, from the goroutine that caused the transition once the Api has released its locks.
+// Like the handler of Receive, a hook may be called from the receive loop, so it must not wait for a reply from the relay.
+func (api *Api) OnSessionChange(fn func(SessionChange)) func() {
+	hook := &sessionHook{fn: fn}
+	api.mu.Lock()
+	api.hooks = append(api.hooks, hook)
+	api.mu.Unlock()
+	return func() {
+		api.mu.Lock()
+		defer api.mu.Unlock()
+		for i, h := range api.hooks {
+			if h == hook {
+				api.hooks = append(api.hooks[:i:i], api.hooks[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// String prints a sessionOp to the name used in errors
+func (op sessionOp) String() string {
+	switch op {
+	case opStartup:
+		return "startup"
+	case opRegister:
+		return "register"
+	case opQueryState:
+		return "query state"
+	case opTransmit:
+		return "transmit"
+	case opShutdown:
+		return "shutdown"
+	default:
+		return fmt.Sprintf("operation %d", byte(op))
+	}
+}
+
+// checkState returns an error if op is not allowed in the current session state, api.mu must be held
+func (api *Api) checkState(op sessionOp) error {
+	var err error
+	switch op {
+	case opStartup:
+		if api.state.open() {
+			err = ErrAlreadyConnected
+		}
+	case opRegister:
+		switch api.state {
+		case SessionConnected:
+		case SessionAssigned:
+			err = ErrAlreadyRegistered
+		default:
+			err = ErrNotConnected
+		}
+	case opQueryState:
+		if api.state != SessionConnected && api.state != SessionAssigned {
+			err = ErrNotConnected
+		}
+	case opTransmit:
+		switch api.state {
+		case SessionAssigned:
+		case SessionConnected:
+			err = ErrNotRegistered
+		default:
+			err = ErrNotConnected
+		}
+	default:
+		if !api.state.open() {
+			err = ErrNotConnected
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("cannot %v in session state %v: %w", op, api.state, err)
+	}
+	return nil
+}
+
+// setState changes the session state and queues the transition for the hooks, api.mu must be held.
+// The hooks are called by the next call to notify.
+func (api *Api) setState(to SessionState, cause error) {
+	if api.state == to {
+		return
+	}
+	if to != SessionFailed {
+		cause = nil
+	}
+	api.changes = append(api.changes, SessionChange{From: api.state, To: to, Err: cause})
+	api.state = to
+	api.cause = cause
+	api.Registered = to == SessionAssigned
+}
+
+// notify calls the hooks for the queued transitions, api.mu and api.sendMu must not be held.
+// If another goroutine is already calling the hooks, it calls them for the new transitions as well.
+func (api *Api) notify() {
+	api.mu.Lock()
+	if api.notifying {
+		api.mu.Unlock()
+		return
+	}
+	api.notifying = true
+	for len(api.changes) > 0 {
+		change := api.changes[0]
+		api.changes = api.changes[1:]
+		hooks := api.hooks
+		api.mu.Unlock()
+		for _, hook := range hooks {
+			hook.fn(change)
+		}
+		api.mu.Lock()
+	}
+	api.notifying = false
+	api.mu.Unlock()
+}
@@ -0,0 +1,177 @@
+package gerte
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// scriptedRelay answers every command read from server with the next reply, until the replies run out
+func scriptedRelay(t *testing.T, server net.Conn, replies ...[]byte) *sync.WaitGroup {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		dat := make([]byte, 1024)
+		for _, reply := range replies {
+			if _, err := server.Read(dat); err != nil {
+				t.Errorf("server errored on read: %+v", err)
+				return
+			}
+			if _, err := server.Write(reply); err != nil {
+				t.Errorf("server errored on write: %+v", err)
+				return
+			}
+		}
+	}()
+	return &wg
+}
+
+// recordSession records the transitions of the session of api
+func recordSession(api *Api) func() []SessionChange {
+	var mu sync.Mutex
+	var changes []SessionChange
+	api.OnSessionChange(func(change SessionChange) {
+		mu.Lock()
+		defer mu.Unlock()
+		changes = append(changes, change)
+	})
+	return func() []SessionChange {
+		mu.Lock()
+		defer mu.Unlock()
+		return changes
+	}
+}
+
+func TestApi_SessionState(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	connected := append([]byte{byte(CommandState), byte(StateConnected)}, Version{Major: 1, Minor: 1}.ToBytes()...)
+	wg := scriptedRelay(t, server,
+		connected,
+		[]byte{byte(CommandState), byte(StateAssigned)},
+		[]byte{byte(CommandState), byte(StateSent)},
+		[]byte{byte(CommandState), byte(StateClosed)},
+	)
+
+	api := NewApi(Version{Major: 1, Minor: 1})
+	changes := recordSession(api)
+	addr := GertAddress{Upper: 1, Lower: 1}
+	pkt := Packet{Data: []byte("hello world!")}
+
+	if _, err := api.Transmit(pkt); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("transmit before startup: got %+v, want %+v", err, ErrNotConnected)
+	}
+	if err := api.Startup(client); err != nil {
+		t.Fatalf("error on startup: %+v", err)
+	}
+	if err := api.Startup(client); !errors.Is(err, ErrAlreadyConnected) {
+		t.Errorf("second startup: got %+v, want %+v", err, ErrAlreadyConnected)
+	}
+	if _, err := api.Transmit(pkt); !errors.Is(err, ErrNotRegistered) || !strings.Contains(err.Error(), "cannot transmit in session state") {
+		t.Errorf("transmit before register: got %+v, want %+v", err, ErrNotRegistered)
+	}
+	if _, err := api.Register(addr, "aaaaaaaaaaaaaaaaaaaa"); err != nil {
+		t.Fatalf("error on register: %+v", err)
+	}
+	if _, err := api.Register(addr, "aaaaaaaaaaaaaaaaaaaa"); !errors.Is(err, ErrAlreadyRegistered) {
+		t.Errorf("second register: got %+v, want %+v", err, ErrAlreadyRegistered)
+	}
+	if api.SessionState() != SessionAssigned {
+		t.Errorf("got state %v, want %v", api.SessionState(), SessionAssigned)
+	}
+	if ok, err := api.Transmit(pkt); !ok || err != nil {
+		t.Errorf("error on transmit: %v %+v", ok, err)
+	}
+	if err := api.Shutdown(); err != nil {
+		t.Errorf("error on shutdown: %+v", err)
+	}
+	if err := api.Shutdown(); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("second shutdown: got %+v, want %+v", err, ErrNotConnected)
+	}
+	wg.Wait()
+
+	want := []SessionChange{
+		{From: SessionClosed, To: SessionConnecting},
+		{From: SessionConnecting, To: SessionConnected},
+		{From: SessionConnected, To: SessionAssigned},
+		{From: SessionAssigned, To: SessionClosed},
+	}
+	if got := changes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got transitions %v, want %v", got, want)
+	}
+	if _, registered := api.Registration(); registered {
+		t.Error("api still registered after shutdown")
+	}
+}
+
+func TestApi_SessionTransitions(t *testing.T) {
+	t.Run("StartupRefused", func(t *testing.T) {
+		server, client := net.Pipe()
+		defer server.Close()
+		wg := scriptedRelay(t, server, []byte{byte(CommandState), byte(StateFailure), byte(ErrorVersion)})
+		api := NewApi(Version{Major: 1, Minor: 1})
+		changes := recordSession(api)
+		if err := api.Startup(client); err == nil {
+			t.Error("startup succeeded although the relay refused it")
+		}
+		wg.Wait()
+		got := changes()
+		if len(got) != 2 || got[1].To != SessionFailed || got[1].Err == nil {
+			t.Errorf("unexpected transitions %v", got)
+		}
+	})
+	t.Run("ClosedByRelay", func(t *testing.T) {
+		server, client := net.Pipe()
+		defer server.Close()
+		var api Api
+		attach(&api, client, SessionAssigned)
+		changes := recordSession(&api)
+		go server.Write([]byte{byte(CommandClose)})
+		cmd, err := api.Parse()
+		if err != nil || cmd.Command != CommandClose {
+			t.Fatalf("got %v %+v, want close command", cmd, err)
+		}
+		want := []SessionChange{{From: SessionAssigned, To: SessionClosed}}
+		if got := changes(); !reflect.DeepEqual(got, want) {
+			t.Errorf("got transitions %v, want %v", got, want)
+		}
+	})
+	t.Run("ConnectionLost", func(t *testing.T) {
+		server, client := net.Pipe()
+		var api Api
+		attach(&api, client, SessionAssigned)
+		changes := recordSession(&api)
+		if err := api.Receive(func(Packet) {}); err != nil {
+			t.Fatalf("error on receive: %+v", err)
+		}
+		server.Close()
+		<-api.Done()
+		got := changes()
+		if len(got) != 1 || got[0].To != SessionFailed || got[0].Err == nil {
+			t.Errorf("unexpected transitions %v", got)
+		}
+		if _, err := api.Transmit(Packet{}); !errors.Is(err, ErrNotConnected) {
+			t.Errorf("got %+v, want %+v", err, ErrNotConnected)
+		}
+	})
+	t.Run("RemoveHook", func(t *testing.T) {
+		server, client := net.Pipe()
+		defer server.Close()
+		var api Api
+		attach(&api, client, SessionConnected)
+		called := false
+		remove := api.OnSessionChange(func(SessionChange) {
+			called = true
+		})
+		remove()
+		_ = api.closeSocket(SessionClosed, nil)
+		api.notify()
+		if called {
+			t.Error("removed hook was called")
+		}
+	})
+}
@@ -57,18 +57,24 @@ func (state GertStatus) GoString() string {
 }
 
 // StatusFromBytes parses bytes to a GERT Status
+// It returns the Status and any encountered errors.
 func StatusFromBytes(data []byte) (Status, error) {
-
+	if err := checkLength(data, 1, "status"); err != nil {
+		return Status{}, err
+	}
 	switch data[0] {
 	case byte(StateFailure):
+		if err := checkLength(data, 2, "failure status"); err != nil {
+			return Status{}, err
+		}
 		return Status{
 			Status: StateFailure,
 			Size:   2,
 			Error:  GertError(data[1]),
 		}, nil
 	case byte(StateConnected):
-		if len(data) < 3 {
-			return Status{}, fmt.Errorf("data too short: %v<3", len(data))
+		if err := checkLength(data, 3, "connected status"); err != nil {
+			return Status{}, err
 		}
 		return Status{
 			Status: StateConnected,
@@ -97,29 +103,17 @@ func StatusFromBytes(data []byte) (Status, error) {
 	return Status{}, fmt.Errorf("state didn't match any known state: %v", data[0])
 }
 
+// parseError returns the error of a "Failed" Status, the GertError.
+// A "Failed" Status carries no version, so StartupContext adds the rejected one to ErrorVersion itself.
 func (status Status) parseError() error {
-	switch status.Error {
-	case ErrorVersion:
-		return fmt.Errorf("incompatible version during negotiation: %v", status.Version)
-	case ErrorBadKey:
-		return fmt.Errorf("key did not match that used for the requested address. Requested address may not exist")
-	case ErrorAlreadyRegistered:
-		return fmt.Errorf("registration has already been performed successfully")
-	case ErrorNotRegistered:
-		return fmt.Errorf("gateway cannot send data before claiming an address")
-	case ErrorNoRoute:
-		return fmt.Errorf("data failed to send because remote gateway could not be found")
-	case ErrorAddressTaken:
-		return fmt.Errorf("address request has already been claimed")
-	}
-	return fmt.Errorf("no valid error")
+	return status.Error
 }
 
 // String prints a GERT Status to a Human-readable string
 func (status Status) String() string {
 	switch status.Status {
 	case StateFailure:
-		return fmt.Sprintf("%v %v", status.Status, status.Error)
+		return fmt.Sprintf("%v %v", status.Status, status.Error.String())
 	case StateConnected:
 		return fmt.Sprintf("%v %v", status.Status, status.Version)
 	case StateAssigned:
@@ -0,0 +1,429 @@
+package stream
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/OmegaRogue/gerte-go/internal/deadline"
+)
+
+type (
+	// Conn is a reliable, ordered stream connection to a remote GERTc, it implements net.Conn
+	Conn struct {
+		ep  *Endpoint
+		key connKey
+
+		mu          sync.Mutex
+		notify      chan struct{}
+		established bool
+		closed      bool
+		err         error
+
+		// sending side
+		sndNext    uint32
+		unacked    []*outSegment
+		peerWindow int
+		finSent    bool
+
+		// receiving side
+		rcvNext   uint32
+		ooo       map[uint32]segment
+		readQueue [][]byte
+		eof       bool
+
+		readDeadline  *deadline.Deadline
+		writeDeadline *deadline.Deadline
+	}
+
+	// outSegment is a sent segment waiting to be acknowledged
+	outSegment struct {
+		seg     segment
+		sent    time.Time
+		retries int
+	}
+)
+
+var _ net.Conn = (*Conn)(nil)
+
+func newConn(ep *Endpoint, key connKey) *Conn {
+	return &Conn{
+		ep:            ep,
+		key:           key,
+		notify:        make(chan struct{}),
+		peerWindow:    1,
+		ooo:           make(map[uint32]segment),
+		readDeadline:  deadline.New(),
+		writeDeadline: deadline.New(),
+	}
+}
+
+// Read reads stream data into p.
+// It returns the number of bytes read and any encountered errors, io.EOF once the remote side closed the stream.
+func (c *Conn) Read(p []byte) (int, error) {
+	c.mu.Lock()
+	for {
+		if len(c.readQueue) > 0 {
+			wasFull := c.window() == 0
+			n := copy(p, c.readQueue[0])
+			if n < len(c.readQueue[0]) {
+				c.readQueue[0] = c.readQueue[0][n:]
+			} else {
+				c.readQueue = c.readQueue[1:]
+			}
+			reopened := wasFull && c.window() > 0
+			c.mu.Unlock()
+			if reopened {
+				// tell the sender about the free space instead of waiting for its next probe
+				c.sendAck()
+			}
+			return n, nil
+		}
+		if c.eof {
+			c.mu.Unlock()
+			return 0, io.EOF
+		}
+		if err := c.stateError(); err != nil {
+			c.mu.Unlock()
+			return 0, c.opError("read", err)
+		}
+		wait := c.notify
+		c.mu.Unlock()
+		select {
+		case <-wait:
+		case <-c.readDeadline.Wait():
+			return 0, c.opError("read", deadline.ErrTimeout)
+		}
+		c.mu.Lock()
+	}
+}
+
+// Write sends p as a sequence of segments, waiting for the receive window of the remote side.
+// It returns the number of bytes queued for sending and any encountered errors.
+// Data is transmitted in the background and retransmitted until it is acknowledged.
+func (c *Conn) Write(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		end := written + MaxSegmentData
+		if end > len(p) {
+			end = len(p)
+		}
+		c.mu.Lock()
+		for !c.established || len(c.unacked) >= c.sendLimit() {
+			if err := c.stateError(); err != nil {
+				c.mu.Unlock()
+				return written, c.opError("write", err)
+			}
+			wait := c.notify
+			c.mu.Unlock()
+			select {
+			case <-wait:
+			case <-c.writeDeadline.Wait():
+				return written, c.opError("write", deadline.ErrTimeout)
+			}
+			c.mu.Lock()
+		}
+		if err := c.stateError(); err != nil {
+			c.mu.Unlock()
+			return written, c.opError("write", err)
+		}
+		seg := c.queue(segment{data: append([]byte{}, p[written:end]...)})
+		c.mu.Unlock()
+		c.ep.send(c.key, seg, true)
+		written = end
+	}
+	return written, nil
+}
+
+// Close closes the sending direction with a FIN and discards unread data.
+// Queued data is still delivered in the background; the connection is forgotten once both sides closed.
+func (c *Conn) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return c.opError("close", ErrClosed)
+	}
+	c.closed = true
+	c.readQueue = nil
+	var fin segment
+	send := c.err == nil && !c.finSent
+	if send {
+		fin = c.queue(segment{flags: flagFIN})
+		c.finSent = true
+	}
+	c.broadcast()
+	finished := c.finished()
+	c.mu.Unlock()
+
+	if send {
+		c.ep.send(c.key, fin, true)
+	}
+	if finished {
+		c.ep.remove(c)
+	}
+	return nil
+}
+
+// LocalAddr returns the GERTc of the local Endpoint
+func (c *Conn) LocalAddr() net.Addr {
+	return c.ep.local
+}
+
+// RemoteAddr returns the GERTc of the remote side
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.key.remote
+}
+
+// SetDeadline sets the read and write deadlines
+func (c *Conn) SetDeadline(t time.Time) error {
+	c.readDeadline.Set(t)
+	c.writeDeadline.Set(t)
+	return nil
+}
+
+// SetReadDeadline sets the deadline for Read, the zero time disables it
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	c.readDeadline.Set(t)
+	return nil
+}
+
+// SetWriteDeadline sets the deadline for Write, the zero time disables it
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	c.writeDeadline.Set(t)
+	return nil
+}
+
+// open sends the SYN of this side, for the accepting side the connection is established afterwards
+func (c *Conn) open() {
+	c.mu.Lock()
+	syn := c.queue(segment{flags: flagSYN})
+	if !c.key.initiator {
+		c.established = true
+		c.broadcast()
+	}
+	c.mu.Unlock()
+	c.ep.send(c.key, syn, false)
+}
+
+// waitEstablished waits for the handshake of a dialed connection
+func (c *Conn) waitEstablished(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for !c.established {
+		if c.err != nil {
+			return c.err
+		}
+		wait := c.notify
+		c.mu.Unlock()
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			c.mu.Lock()
+			return ctx.Err()
+		}
+		c.mu.Lock()
+	}
+	return nil
+}
+
+// handle processes a received segment.
+// It returns whether the segment has to be acknowledged.
+func (c *Conn) handle(seg segment) bool {
+	c.mu.Lock()
+	defer func() {
+		finished := c.finished()
+		c.mu.Unlock()
+		if finished {
+			c.ep.remove(c)
+		}
+	}()
+	if c.err != nil {
+		return false
+	}
+	if seg.has(flagRST) {
+		if c.finSent && c.eof {
+			// both sides closed, only the final ACK got lost
+			c.unacked = nil
+		} else {
+			c.fail(ErrReset)
+		}
+		return false
+	}
+
+	c.peerWindow = int(seg.window)
+	if seg.has(flagACK) {
+		n := 0
+		for n < len(c.unacked) && c.unacked[n].seg.seq < seg.ack {
+			n++
+		}
+		c.unacked = c.unacked[n:]
+		// the remote side is alive, restart the backoff of the remaining segments
+		for _, out := range c.unacked {
+			out.retries = 0
+		}
+	}
+
+	needAck := false
+	if seg.occupiesSeq() {
+		needAck = true
+		if seg.seq >= c.rcvNext && seg.seq-c.rcvNext < uint32(c.window()) {
+			if _, ok := c.ooo[seg.seq]; !ok {
+				c.ooo[seg.seq] = seg
+			}
+			for {
+				next, ok := c.ooo[c.rcvNext]
+				if !ok {
+					break
+				}
+				delete(c.ooo, c.rcvNext)
+				c.rcvNext++
+				if len(next.data) > 0 && !c.closed {
+					c.readQueue = append(c.readQueue, next.data)
+				}
+				if next.has(flagFIN) {
+					c.eof = true
+				}
+			}
+		}
+	}
+
+	if c.key.initiator && !c.established && c.rcvNext > 0 && (len(c.unacked) == 0 || c.unacked[0].seg.seq > 0) {
+		c.established = true
+	}
+	c.broadcast()
+	return needAck
+}
+
+// retransmit resends every segment whose retransmission timeout passed
+func (c *Conn) retransmit(now time.Time) {
+	c.mu.Lock()
+	var resend []segment
+	for _, out := range c.unacked {
+		timeout := c.ep.RTO << uint(out.retries)
+		if now.Sub(out.sent) < timeout {
+			continue
+		}
+		if out.retries >= c.ep.MaxRetries {
+			c.fail(ErrTimeout)
+			resend = nil
+			break
+		}
+		out.retries++
+		out.sent = now
+		resend = append(resend, c.stamp(out.seg))
+	}
+	finished := c.finished()
+	c.mu.Unlock()
+
+	for _, seg := range resend {
+		c.ep.send(c.key, seg, false)
+	}
+	if finished {
+		c.ep.remove(c)
+	}
+}
+
+// abort fails the connection with err and resets the remote side
+func (c *Conn) abort(err error) {
+	c.mu.Lock()
+	c.fail(err)
+	c.mu.Unlock()
+	c.ep.send(c.key, segment{flags: flagRST, id: c.key.id}, false)
+	c.ep.remove(c)
+}
+
+// sendAck sends a pure ACK with the current receive state
+func (c *Conn) sendAck() {
+	c.mu.Lock()
+	ack := c.stamp(segment{seq: c.sndNext})
+	c.mu.Unlock()
+	c.ep.send(c.key, ack, false)
+}
+
+// queue assigns the next sequence number to seg and keeps it for retransmission, c.mu must be held.
+// It returns the segment ready for sending.
+func (c *Conn) queue(seg segment) segment {
+	seg.seq = c.sndNext
+	c.sndNext++
+	c.unacked = append(c.unacked, &outSegment{
+		seg:  seg,
+		sent: time.Now(),
+	})
+	return c.stamp(seg)
+}
+
+// stamp fills in the connection ID and the current receive state, c.mu must be held
+func (c *Conn) stamp(seg segment) segment {
+	seg.id = c.key.id
+	seg.window = uint16(c.window())
+	if c.rcvNext > 0 {
+		seg.flags |= flagACK
+		seg.ack = c.rcvNext
+	}
+	return seg
+}
+
+// window returns the number of segments that can still be buffered, c.mu must be held
+func (c *Conn) window() int {
+	w := c.ep.Window - len(c.readQueue) - len(c.ooo)
+	if w < 0 {
+		return 0
+	}
+	return w
+}
+
+// sendLimit returns the number of unacknowledged segments allowed in flight, c.mu must be held.
+// At least one segment is always allowed, so its retransmissions probe a closed window.
+func (c *Conn) sendLimit() int {
+	limit := c.peerWindow
+	if limit > c.ep.Window {
+		limit = c.ep.Window
+	}
+	if limit < 1 {
+		return 1
+	}
+	return limit
+}
+
+// stateError returns the error that prevents further use of the connection, c.mu must be held
+func (c *Conn) stateError() error {
+	if c.err != nil {
+		return c.err
+	}
+	if c.closed {
+		return ErrClosed
+	}
+	return nil
+}
+
+// fail records a fatal error and wakes up all waiting operations, c.mu must be held
+func (c *Conn) fail(err error) {
+	if c.err == nil {
+		c.err = err
+	}
+	c.unacked = nil
+	c.broadcast()
+}
+
+// finished returns whether the connection can be forgotten, c.mu must be held
+func (c *Conn) finished() bool {
+	return c.err != nil || (c.finSent && c.eof && len(c.unacked) == 0)
+}
+
+// broadcast wakes up all operations waiting for a state change, c.mu must be held
+func (c *Conn) broadcast() {
+	close(c.notify)
+	c.notify = make(chan struct{})
+}
+
+func (c *Conn) opError(op string, err error) error {
+	return &net.OpError{
+		Op:     op,
+		Net:    "gert-stream",
+		Source: c.ep.local,
+		Addr:   c.key.remote,
+		Err:    err,
+	}
+}
@@ -0,0 +1,242 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/OmegaRogue/gerte-go"
+	"github.com/OmegaRogue/gerte-go/geds"
+)
+
+// lossyLink connects two endpoints in memory, dropping and delaying packets at random
+type lossyLink struct {
+	mu   sync.Mutex
+	rand *rand.Rand
+	loss float64
+}
+
+func (l *lossyLink) transmit(to func() *Endpoint) func(ctx context.Context, pkt gerte.Packet) (bool, error) {
+	return func(ctx context.Context, pkt gerte.Packet) (bool, error) {
+		l.mu.Lock()
+		drop := l.rand.Float64() < l.loss
+		delay := time.Duration(l.rand.Intn(3)) * time.Millisecond
+		l.mu.Unlock()
+		if !drop {
+			time.AfterFunc(delay, func() {
+				to().deliver(pkt)
+			})
+		}
+		return true, nil
+	}
+}
+
+func endpointPair(loss float64) (*Endpoint, *Endpoint) {
+	link := &lossyLink{rand: rand.New(rand.NewSource(1)), loss: loss}
+	var a, b *Endpoint
+	a = newEndpoint(gerte.GERTc{GERTe: gerte.GertAddress{Upper: 1, Lower: 1}}, link.transmit(func() *Endpoint { return b }))
+	b = newEndpoint(gerte.GERTc{GERTe: gerte.GertAddress{Upper: 2, Lower: 2}}, link.transmit(func() *Endpoint { return a }))
+	for _, ep := range []*Endpoint{a, b} {
+		ep.RTO = 20 * time.Millisecond
+		ep.MaxRetries = 20
+	}
+	return a, b
+}
+
+// echo accepts a single connection and writes back everything it reads
+func echo(t *testing.T, ep *Endpoint) {
+	go func() {
+		c, err := ep.Accept()
+		if err != nil {
+			t.Errorf("error on accept: %+v", err)
+			return
+		}
+		if _, err := io.Copy(c, c); err != nil {
+			t.Logf("echo stopped: %+v", err)
+		}
+		c.Close()
+	}()
+}
+
+func TestConn_Echo(t *testing.T) {
+	for name, loss := range map[string]float64{"Lossless": 0, "Lossy": 0.2} {
+		t.Run(name, func(t *testing.T) {
+			a, b := endpointPair(loss)
+			defer a.Close()
+			defer b.Close()
+			echo(t, b)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			c, err := a.Dial(ctx, b.local)
+			if err != nil {
+				t.Fatalf("error on dial: %+v", err)
+			}
+			data := make([]byte, 20000)
+			rand.New(rand.NewSource(2)).Read(data)
+			go func() {
+				if _, err := c.Write(data); err != nil {
+					t.Errorf("error on write: %+v", err)
+				}
+				// close the write side by closing the connection once everything was echoed
+			}()
+			received := make([]byte, len(data))
+			if _, err := io.ReadFull(c, received); err != nil {
+				t.Fatalf("error on read: %+v", err)
+			}
+			if !bytes.Equal(data, received) {
+				t.Error("echoed data doesn't match")
+			}
+			if err := c.Close(); err != nil {
+				t.Errorf("error on close: %+v", err)
+			}
+		})
+	}
+}
+
+func TestConn_EOF(t *testing.T) {
+	a, b := endpointPair(0)
+	defer a.Close()
+	defer b.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, err := b.Accept()
+		if err != nil {
+			t.Errorf("error on accept: %+v", err)
+		}
+		accepted <- c
+	}()
+	c, err := a.Dial(context.Background(), b.local)
+	if err != nil {
+		t.Fatalf("error on dial: %+v", err)
+	}
+	if _, err := c.Write([]byte("hello world!")); err != nil {
+		t.Fatalf("error on write: %+v", err)
+	}
+	c.Close()
+
+	remote := <-accepted
+	data, err := ioutil.ReadAll(remote)
+	if err != nil {
+		t.Fatalf("error on read: %+v", err)
+	}
+	if string(data) != "hello world!" {
+		t.Errorf("unexpected data: %q", data)
+	}
+	remote.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		a.mu.Lock()
+		b.mu.Lock()
+		n := len(a.conns) + len(b.conns)
+		b.mu.Unlock()
+		a.mu.Unlock()
+		if n == 0 {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Error("closed connections were not forgotten")
+}
+
+func TestConn_Reset(t *testing.T) {
+	a, b := endpointPair(0)
+	defer a.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	b.Close()
+	if _, err := a.Dial(ctx, b.local); err == nil {
+		t.Error("dial to closed endpoint succeeded")
+	}
+}
+
+func TestConn_ReadDeadline(t *testing.T) {
+	a, b := endpointPair(0)
+	defer a.Close()
+	defer b.Close()
+	echo(t, b)
+
+	c, err := a.Dial(context.Background(), b.local)
+	if err != nil {
+		t.Fatalf("error on dial: %+v", err)
+	}
+	defer c.Close()
+	c.SetReadDeadline(time.Now().Add(20 * time.Millisecond))
+	_, err = c.Read(make([]byte, 1))
+	if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+		t.Errorf("expected timeout, got %+v", err)
+	}
+}
+
+func TestDial(t *testing.T) {
+	addrA := gerte.GertAddress{Upper: 1123, Lower: 1456}
+	addrB := gerte.GertAddress{Upper: 2345, Lower: 1456}
+	key := "aaaaaaaaaaaaaaaaaaaa"
+	ver := gerte.Version{Major: 1, Minor: 1}
+	srv := geds.NewServer(ver, geds.Resolutions{addrA: key, addrB: key})
+	defer srv.Close()
+
+	connect := func(addr gerte.GertAddress) *gerte.Api {
+		server, client := net.Pipe()
+		go srv.ServeConn(server)
+		api := gerte.NewApi(ver)
+		if err := api.Startup(client); err != nil {
+			t.Fatalf("error on startup: %+v", err)
+		}
+		if _, err := api.Register(addr, key); err != nil {
+			t.Fatalf("error on register: %+v", err)
+		}
+		return api
+	}
+	apiA := connect(addrA)
+	apiB := connect(addrB)
+
+	l, err := Listen(apiB)
+	if err != nil {
+		t.Fatalf("error on listen: %+v", err)
+	}
+	defer l.Close()
+	echo(t, l)
+
+	c, err := Dial(apiA, gerte.GERTc{GERTe: addrB})
+	if err != nil {
+		t.Fatalf("error on dial: %+v", err)
+	}
+	defer c.Close()
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	if _, err := c.Write(data); err != nil {
+		t.Fatalf("error on write: %+v", err)
+	}
+	received := make([]byte, len(data))
+	if _, err := io.ReadFull(c, received); err != nil {
+		t.Fatalf("error on read: %+v", err)
+	}
+	if !bytes.Equal(data, received) {
+		t.Error("echoed data doesn't match")
+	}
+
+	// the Endpoint created by Dial is dropped with the Api
+	if err := apiA.Shutdown(); err != nil {
+		t.Fatalf("error on shutdown: %+v", err)
+	}
+	for start := time.Now(); ; time.Sleep(time.Millisecond) {
+		endpoints.Lock()
+		_, ok := endpoints.m[apiA]
+		endpoints.Unlock()
+		if !ok {
+			break
+		}
+		if time.Since(start) > time.Second {
+			t.Fatal("endpoint of a stopped api was kept")
+		}
+	}
+}
@@ -0,0 +1,380 @@
+// Package stream provides reliable, ordered stream connections between two GERTi hosts on top of GERTe packets.
+// Every packet carries a small segment header with sequence numbers, acknowledgements and a receive window;
+// lost segments are retransmitted, so the connections can be used like TCP connections through the net.Conn interface.
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/OmegaRogue/gerte-go"
+)
+
+const (
+	// DefaultRTO is the initial retransmission timeout
+	DefaultRTO = 500 * time.Millisecond
+	// DefaultMaxRetries is the number of retransmissions of a segment before the connection is given up
+	DefaultMaxRetries = 8
+	// DefaultWindow is the number of segments a connection buffers for the reader
+	DefaultWindow = 32
+
+	// acceptBacklog is the number of connections waiting for Accept before new ones are refused
+	acceptBacklog = 16
+	// outboundBuffer is the number of segments waiting to be transmitted
+	outboundBuffer = 256
+)
+
+var (
+	// ErrClosed is returned when using a closed Endpoint or Conn
+	ErrClosed = errors.New("stream: use of closed connection")
+	// ErrReset is returned when the remote side aborted the connection
+	ErrReset = errors.New("stream: connection reset by peer")
+	// ErrTimeout is returned when a segment was not acknowledged after MaxRetries retransmissions
+	ErrTimeout = errors.New("stream: connection timed out")
+)
+
+type (
+	// Endpoint multiplexes stream connections over the receive loop of a single Api.
+	// It dials new connections and accepts connections from remote peers, implementing net.Listener.
+	// The exported fields must not be changed after the first call to Dial or Accept.
+	Endpoint struct {
+		// RTO is the initial retransmission timeout, it doubles with every retransmission of a segment
+		RTO time.Duration
+		// MaxRetries is the number of retransmissions before a connection fails
+		MaxRetries int
+		// Window is the number of segments every connection buffers for its reader
+		Window int
+
+		local    gerte.GERTc
+		transmit func(ctx context.Context, pkt gerte.Packet) (bool, error)
+		api      *gerte.Api
+
+		mu     sync.Mutex
+		conns  map[connKey]*Conn
+		nextID uint16
+
+		accept    chan *Conn
+		out       chan gerte.Packet
+		closed    chan struct{}
+		startOnce sync.Once
+		closeOnce sync.Once
+		wg        sync.WaitGroup
+	}
+
+	// connKey identifies a connection of an Endpoint
+	connKey struct {
+		remote    gerte.GERTc
+		id        uint16
+		initiator bool
+	}
+)
+
+var endpoints = struct {
+	sync.Mutex
+	m map[*gerte.Api]*Endpoint
+}{}
+
+// NewEndpoint is the constructor for Endpoint, it takes over the receive loop of a registered api.
+// It returns the Endpoint and any encountered errors.
+// The Endpoint uses the registered address of api together with the GERTi address local as its address,
+// it is closed when the receive loop of api stops.
+func NewEndpoint(api *gerte.Api, local gerte.GertAddress) (*Endpoint, error) {
+	addr, _ := api.Registration()
+	ep := newEndpoint(gerte.GERTc{GERTe: addr, GERTi: local}, api.TransmitContext)
+	ep.api = api
+	if err := api.Receive(ep.deliver); err != nil {
+		ep.shutdown()
+		return nil, fmt.Errorf("error on start receive loop: %w", err)
+	}
+	go ep.closeOnDone(api.Done())
+	return ep, nil
+}
+
+// closeOnDone closes the Endpoint once done is closed, so an Endpoint of a stopped Api doesn't stay in endpoints
+func (ep *Endpoint) closeOnDone(done <-chan struct{}) {
+	select {
+	case <-done:
+		ep.Close()
+	case <-ep.closed:
+	}
+}
+
+// newEndpoint creates an Endpoint sending its packets with transmit, packets have to be passed to deliver
+func newEndpoint(local gerte.GERTc, transmit func(ctx context.Context, pkt gerte.Packet) (bool, error)) *Endpoint {
+	ep := &Endpoint{
+		RTO:        DefaultRTO,
+		MaxRetries: DefaultMaxRetries,
+		Window:     DefaultWindow,
+		local:      local,
+		transmit:   transmit,
+		conns:      make(map[connKey]*Conn),
+		accept:     make(chan *Conn, acceptBacklog),
+		out:        make(chan gerte.Packet, outboundBuffer),
+		closed:     make(chan struct{}),
+	}
+	return ep
+}
+
+// start runs the goroutines sending and retransmitting segments
+func (ep *Endpoint) start() {
+	ep.startOnce.Do(func() {
+		ep.mu.Lock()
+		defer ep.mu.Unlock()
+		if isClosed(ep.closed) {
+			return
+		}
+		ep.wg.Add(2)
+		go ep.sendLoop()
+		go ep.timerLoop()
+	})
+}
+
+// Dial opens a stream connection to the Endpoint of api, creating it with the GERTi address 0000.0000 if necessary.
+// It returns the established connection and any encountered errors.
+func Dial(api *gerte.Api, target gerte.GERTc) (net.Conn, error) {
+	ep, err := Listen(api)
+	if err != nil {
+		return nil, err
+	}
+	return ep.Dial(context.Background(), target)
+}
+
+// Listen returns the Endpoint of api, creating it with the GERTi address 0000.0000 if necessary.
+// It returns the Endpoint and any encountered errors.
+// The Endpoint is kept until it is closed or the receive loop of api stops.
+func Listen(api *gerte.Api) (*Endpoint, error) {
+	endpoints.Lock()
+	defer endpoints.Unlock()
+	if ep, ok := endpoints.m[api]; ok {
+		return ep, nil
+	}
+	ep, err := NewEndpoint(api, gerte.GertAddress{})
+	if err != nil {
+		return nil, err
+	}
+	if endpoints.m == nil {
+		endpoints.m = make(map[*gerte.Api]*Endpoint)
+	}
+	endpoints.m[api] = ep
+	return ep, nil
+}
+
+// Dial opens a stream connection to target and waits for the handshake to complete.
+// It returns the established connection and any encountered errors.
+func (ep *Endpoint) Dial(ctx context.Context, target gerte.GERTc) (*Conn, error) {
+	ep.start()
+	ep.mu.Lock()
+	if isClosed(ep.closed) {
+		ep.mu.Unlock()
+		return nil, ErrClosed
+	}
+	key := connKey{remote: target, initiator: true}
+	for {
+		key.id = ep.nextID
+		ep.nextID++
+		if _, ok := ep.conns[key]; !ok {
+			break
+		}
+	}
+	c := newConn(ep, key)
+	ep.conns[key] = c
+	ep.mu.Unlock()
+
+	c.open()
+	if err := c.waitEstablished(ctx); err != nil {
+		c.abort(err)
+		return nil, err
+	}
+	return c, nil
+}
+
+// Accept waits for the next connection opened by a remote peer.
+// It returns the connection and any encountered errors.
+func (ep *Endpoint) Accept() (net.Conn, error) {
+	ep.start()
+	var done <-chan struct{}
+	if ep.api != nil {
+		done = ep.api.Done()
+	}
+	select {
+	case c := <-ep.accept:
+		return c, nil
+	case <-ep.closed:
+		return nil, ErrClosed
+	case <-done:
+		return nil, ErrClosed
+	}
+}
+
+// Close resets all connections of the Endpoint and stops accepting new ones.
+// The Api is not shut down.
+func (ep *Endpoint) Close() error {
+	ep.shutdown()
+	endpoints.Lock()
+	if endpoints.m[ep.api] == ep {
+		delete(endpoints.m, ep.api)
+	}
+	endpoints.Unlock()
+	return nil
+}
+
+// shutdown resets all connections and stops the goroutines of the Endpoint
+func (ep *Endpoint) shutdown() {
+	ep.closeOnce.Do(func() {
+		ep.mu.Lock()
+		conns := make([]*Conn, 0, len(ep.conns))
+		for _, c := range ep.conns {
+			conns = append(conns, c)
+		}
+		ep.mu.Unlock()
+		for _, c := range conns {
+			c.abort(ErrClosed)
+		}
+		ep.mu.Lock()
+		close(ep.closed)
+		ep.mu.Unlock()
+		ep.wg.Wait()
+	})
+}
+
+// Addr returns the GERTc of the Endpoint
+func (ep *Endpoint) Addr() net.Addr {
+	return ep.local
+}
+
+// deliver handles a packet received from the relay, it is called from the receive loop and never blocks
+func (ep *Endpoint) deliver(pkt gerte.Packet) {
+	if pkt.Target.GERTi != ep.local.GERTi {
+		return
+	}
+	seg, err := segmentFromBytes(pkt.Data)
+	if err != nil {
+		return
+	}
+	ep.start()
+	key := connKey{
+		remote:    pkt.Source,
+		id:        seg.id,
+		initiator: !seg.has(flagInitiator),
+	}
+	ep.mu.Lock()
+	c, ok := ep.conns[key]
+	created := false
+	if !ok && seg.has(flagSYN) && !key.initiator && !isClosed(ep.closed) {
+		c = newConn(ep, key)
+		select {
+		case ep.accept <- c:
+			ep.conns[key] = c
+			ok, created = true, true
+		default:
+		}
+	}
+	ep.mu.Unlock()
+
+	if !ok {
+		if !seg.has(flagRST) {
+			ep.send(key, segment{flags: flagRST, id: seg.id}, false)
+		}
+		return
+	}
+	needAck := c.handle(seg)
+	if created {
+		// the SYN of the accepting side acknowledges the SYN of the dialer
+		c.open()
+	} else if needAck {
+		c.sendAck()
+	}
+}
+
+// send queues a segment for transmission to the remote side of key.
+// If wait is false the segment is dropped when the queue is full, retransmission takes care of it.
+func (ep *Endpoint) send(key connKey, seg segment, wait bool) {
+	if key.initiator {
+		seg.flags |= flagInitiator
+	}
+	pkt := gerte.Packet{
+		Source: ep.local,
+		Target: key.remote,
+		Data:   seg.toBytes(),
+	}
+	if !wait {
+		select {
+		case ep.out <- pkt:
+		default:
+		}
+		return
+	}
+	select {
+	case ep.out <- pkt:
+	case <-ep.closed:
+	}
+}
+
+// sendLoop transmits queued segments, transmission errors are treated like lost segments
+func (ep *Endpoint) sendLoop() {
+	defer ep.wg.Done()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-ep.closed
+		cancel()
+	}()
+	for {
+		select {
+		case pkt := <-ep.out:
+			_, _ = ep.transmit(ctx, pkt)
+		case <-ep.closed:
+			return
+		}
+	}
+}
+
+// timerLoop retransmits unacknowledged segments
+func (ep *Endpoint) timerLoop() {
+	defer ep.wg.Done()
+	tick := ep.RTO / 4
+	if tick < time.Millisecond {
+		tick = time.Millisecond
+	}
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			ep.mu.Lock()
+			conns := make([]*Conn, 0, len(ep.conns))
+			for _, c := range ep.conns {
+				conns = append(conns, c)
+			}
+			ep.mu.Unlock()
+			for _, c := range conns {
+				c.retransmit(now)
+			}
+		case <-ep.closed:
+			return
+		}
+	}
+}
+
+// remove forgets a finished connection
+func (ep *Endpoint) remove(c *Conn) {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+	if ep.conns[c.key] == c {
+		delete(ep.conns, c.key)
+	}
+}
+
+// isClosed returns whether the channel c is closed
+func isClosed(c chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,71 @@
+package stream
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	// flagSYN opens a stream, it occupies sequence number 0
+	flagSYN byte = 1 << iota
+	// flagACK indicates that the ack field is valid
+	flagACK
+	// flagFIN closes the sending direction of a stream, it occupies a sequence number
+	flagFIN
+	// flagRST aborts a stream
+	flagRST
+	// flagInitiator is set on every segment sent by the side that dialed the stream
+	flagInitiator
+)
+
+// headerSize is the size of the segment header in front of the data
+const headerSize = 13
+
+// MaxSegmentData is the amount of stream data carried by a single packet
+const MaxSegmentData = 255 - headerSize
+
+// segment is the header and data of a single stream packet.
+// Sequence numbers count segments, not bytes: SYN, FIN and every data segment occupy one sequence number.
+type segment struct {
+	flags  byte
+	id     uint16
+	seq    uint32
+	ack    uint32
+	window uint16
+	data   []byte
+}
+
+// toBytes converts a segment to bytes for sending
+func (seg segment) toBytes() []byte {
+	b := make([]byte, headerSize, headerSize+len(seg.data))
+	b[0] = seg.flags
+	binary.BigEndian.PutUint16(b[1:], seg.id)
+	binary.BigEndian.PutUint32(b[3:], seg.seq)
+	binary.BigEndian.PutUint32(b[7:], seg.ack)
+	binary.BigEndian.PutUint16(b[11:], seg.window)
+	return append(b, seg.data...)
+}
+
+// segmentFromBytes parses bytes to a segment
+func segmentFromBytes(data []byte) (segment, error) {
+	if len(data) < headerSize {
+		return segment{}, fmt.Errorf("segment too short: %v<%v", len(data), headerSize)
+	}
+	return segment{
+		flags:  data[0],
+		id:     binary.BigEndian.Uint16(data[1:]),
+		seq:    binary.BigEndian.Uint32(data[3:]),
+		ack:    binary.BigEndian.Uint32(data[7:]),
+		window: binary.BigEndian.Uint16(data[11:]),
+		data:   data[headerSize:],
+	}, nil
+}
+
+// occupiesSeq returns whether the segment has to be acknowledged, pure ACKs and RSTs don't
+func (seg segment) occupiesSeq() bool {
+	return seg.flags&(flagSYN|flagFIN) != 0 || len(seg.data) > 0
+}
+
+func (seg segment) has(flag byte) bool {
+	return seg.flags&flag != 0
+}
@@ -0,0 +1,28 @@
+package stream
+
+import "testing"
+
+func TestSegmentFromToBytes(t *testing.T) {
+	seg := segment{
+		flags:  flagSYN | flagACK | flagInitiator,
+		id:     4242,
+		seq:    1 << 30,
+		ack:    12345,
+		window: 32,
+		data:   []byte("test"),
+	}
+	seg2, err := segmentFromBytes(seg.toBytes())
+	if err != nil {
+		t.Fatalf("error on parse segment: %+v", err)
+	}
+	if seg.flags != seg2.flags || seg.id != seg2.id || seg.seq != seg2.seq ||
+		seg.ack != seg2.ack || seg.window != seg2.window || string(seg.data) != string(seg2.data) {
+		t.Errorf("segments don't match:\n%+v\n%+v", seg, seg2)
+	}
+	if len(seg.toBytes()) > 255 || headerSize+MaxSegmentData != 255 {
+		t.Error("segment doesn't fit into a packet")
+	}
+	if _, err := segmentFromBytes(make([]byte, headerSize-1)); err == nil {
+		t.Error("short segment was parsed")
+	}
+}
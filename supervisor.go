@@ -0,0 +1,318 @@
+package gerte
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+type (
+	// ConnState indicates the state of the relay connection managed by a Supervisor
+	ConnState int
+
+	// ConnEvent is passed to Supervisor.OnEvent whenever the connection state changes
+	ConnEvent struct {
+		State ConnState
+		// Attempt is the number of failed attempts since the last successful registration
+		Attempt int
+		// Err is the error that caused a ConnDisconnected event
+		Err error
+	}
+
+	// Backoff configures the delay between reconnect attempts.
+	// The delay starts at Initial, is multiplied by Multiplier after every failed attempt and never exceeds Max.
+	// Jitter randomizes every delay by up to the given fraction in both directions.
+	// Initial, Max and Multiplier fall back to the values of DefaultBackoff if they are zero,
+	// a Multiplier below 1 is treated as 1 so the delay never shrinks.
+	Backoff struct {
+		Initial    time.Duration
+		Max        time.Duration
+		Multiplier float64
+		Jitter     float64
+	}
+
+	// Supervisor keeps an Api connected and registered.
+	// It dials the relay, negotiates the version, registers Address with Key and starts the receive loop.
+	// When the connection drops it reconnects, waiting between attempts according to Backoff.
+	Supervisor struct {
+		// Dial opens a new connection to the relay
+		Dial func(ctx context.Context) (net.Conn, error)
+		// Version is the version requested during negotiation, ExtendedVersion falls back to DefaultVersion, see Api.Negotiate
+		Version Version
+		// Policy makes the negotiation fall back to older versions the relay supports, see Api.Negotiate
+		Policy VersionPolicy
+		// Address and Key are used to register every new connection
+		Address GertAddress
+		Key     string
+		// Backoff configures the delay between reconnect attempts, DefaultBackoff is used if it is zero
+		Backoff Backoff
+		// Handler receives the inbound packets of every connection, they are dropped if it is nil
+		Handler func(Packet)
+		// OnEvent is called from Run on every connection state change, if it is not nil
+		OnEvent func(ConnEvent)
+		// KeepaliveInterval enables Api.Keepalive on every connection if it is positive,
+		// so a dead relay is detected and reconnected without waiting for a failed Transmit
+		KeepaliveInterval time.Duration
+		// KeepaliveTimeout is the time the relay has to answer a keepalive, KeepaliveInterval if not set
+		KeepaliveTimeout time.Duration
+
+		mu    sync.Mutex
+		api   *Api
+		state ConnState
+		ready chan struct{}
+	}
+)
+
+const (
+	// ConnDisconnected indicates that there is no relay connection, either before the first attempt or after it dropped
+	ConnDisconnected ConnState = iota
+	// ConnConnecting indicates that the relay is being dialed
+	ConnConnecting
+	// ConnConnected indicates that the version negotiation succeeded
+	ConnConnected
+	// ConnRegistered indicates that the address was registered, the Api can be used
+	ConnRegistered
+	// ConnStopped indicates that Run returned
+	ConnStopped
+)
+
+// DefaultBackoff is the Backoff used by a Supervisor without one
+var DefaultBackoff = Backoff{
+	Initial:    500 * time.Millisecond,
+	Max:        30 * time.Second,
+	Multiplier: 2,
+	Jitter:     0.2,
+}
+
+// String prints a ConnState to a Human-readable string
+func (state ConnState) String() string {
+	switch state {
+	case ConnDisconnected:
+		return "DISCONNECTED"
+	case ConnConnecting:
+		return "CONNECTING"
+	case ConnConnected:
+		return "CONNECTED"
+	case ConnRegistered:
+		return "REGISTERED"
+	case ConnStopped:
+		return "STOPPED"
+	}
+	return "nil"
+}
+
+// Delay returns the time to wait before the given reconnect attempt, starting at 0
+func (b Backoff) Delay(attempt int) time.Duration {
+	if b.Initial <= 0 {
+		b.Initial = DefaultBackoff.Initial
+	}
+	if b.Max <= 0 {
+		b.Max = DefaultBackoff.Max
+	}
+	if b.Multiplier == 0 {
+		b.Multiplier = DefaultBackoff.Multiplier
+	}
+	if b.Multiplier < 1 {
+		b.Multiplier = 1
+	}
+	d := float64(b.Initial) * math.Pow(b.Multiplier, float64(attempt))
+	if d > float64(b.Max) {
+		d = float64(b.Max)
+	}
+	if b.Jitter > 0 {
+		d += d * b.Jitter * (2*rand.Float64() - 1)
+	}
+	if d < 0 {
+		return 0
+	}
+	return time.Duration(d)
+}
+
+// NewSupervisor is the constructor for Supervisor, it assigns the dial function, Version, Address and Key
+func NewSupervisor(dial func(ctx context.Context) (net.Conn, error), ver Version, addr GertAddress, key string) *Supervisor {
+	return &Supervisor{
+		Dial:    dial,
+		Version: ver,
+		Address: addr,
+		Key:     key,
+	}
+}
+
+// Run connects to the relay and keeps reconnecting until ctx ends.
+// It always returns a non-nil error, ctx.Err() once ctx ends.
+// If the relay rejects the connection with an error that isn't temporary, like ErrBadKey or ErrVersion,
+// reconnecting can't succeed, so Run stops and returns it, see IsTemporary.
+// Failed dials and dropped connections are always retried.
+// The Api of the current connection is shut down before Run returns.
+func (s *Supervisor) Run(ctx context.Context) error {
+	backoff := s.Backoff
+	if backoff == (Backoff{}) {
+		backoff = DefaultBackoff
+	}
+	attempt := 0
+	for {
+		api, err := s.connect(ctx, attempt)
+		if err == nil {
+			attempt = 0
+			select {
+			case <-api.Done():
+				err = api.Err()
+				if err == nil {
+					err = fmt.Errorf("connection closed")
+				}
+			case <-ctx.Done():
+				s.setApi(nil, ConnStopped, attempt, nil)
+				shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+				_ = api.ShutdownContext(shutdownCtx)
+				cancel()
+				return ctx.Err()
+			}
+		}
+		if ctx.Err() != nil {
+			s.setApi(nil, ConnStopped, attempt, nil)
+			return ctx.Err()
+		}
+		if rejected(err) {
+			s.setApi(nil, ConnStopped, attempt, err)
+			return err
+		}
+		s.setApi(nil, ConnDisconnected, attempt, err)
+
+		timer := time.NewTimer(backoff.Delay(attempt))
+		attempt++
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			s.setApi(nil, ConnStopped, attempt, nil)
+			return ctx.Err()
+		}
+	}
+}
+
+// connect dials the relay, negotiates, registers and starts the receive loop.
+// It returns the registered Api and any encountered errors.
+func (s *Supervisor) connect(ctx context.Context, attempt int) (*Api, error) {
+	s.setApi(nil, ConnConnecting, attempt, nil)
+	api := NewApi(s.Version)
+	api.Policy = s.Policy
+	if err := api.Negotiate(ctx, s.Dial); err != nil {
+		return nil, fmt.Errorf("error on startup: %w", err)
+	}
+	s.setApi(nil, ConnConnected, attempt, nil)
+	// fail closes the session of api, so it ends in SessionFailed like any other failed connection
+	fail := func(err error) (*Api, error) {
+		_ = api.closeSocket(SessionFailed, err)
+		api.notify()
+		return nil, err
+	}
+	if _, err := api.RegisterContext(ctx, s.Address, s.Key); err != nil {
+		return fail(fmt.Errorf("error on register: %w", err))
+	}
+	handler := s.Handler
+	if handler == nil {
+		handler = func(Packet) {}
+	}
+	if err := api.Receive(handler); err != nil {
+		return fail(fmt.Errorf("error on start receive loop: %w", err))
+	}
+	if s.KeepaliveInterval > 0 {
+		if _, err := api.Keepalive(s.KeepaliveInterval, s.KeepaliveTimeout, nil); err != nil {
+			return fail(fmt.Errorf("error on start keepalive: %w", err))
+		}
+	}
+	s.setApi(api, ConnRegistered, attempt, nil)
+	return api, nil
+}
+
+// rejected returns whether err is a permanent error the relay answered with
+func rejected(err error) bool {
+	var gertErr GertError
+	return errors.As(err, &gertErr) && !IsTemporary(err)
+}
+
+// setApi records the current Api and state and reports the change to OnEvent
+func (s *Supervisor) setApi(api *Api, state ConnState, attempt int, err error) {
+	s.mu.Lock()
+	s.api = api
+	s.state = state
+	if s.ready == nil {
+		s.ready = make(chan struct{})
+	}
+	if state == ConnRegistered {
+		if !isClosed(s.ready) {
+			close(s.ready)
+		}
+	} else if isClosed(s.ready) {
+		s.ready = make(chan struct{})
+	}
+	s.mu.Unlock()
+	if s.OnEvent != nil {
+		s.OnEvent(ConnEvent{
+			State:   state,
+			Attempt: attempt,
+			Err:     err,
+		})
+	}
+}
+
+// State returns the current connection state
+func (s *Supervisor) State() ConnState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// Api returns the Api of the current connection.
+// It returns nil if the Supervisor is not registered.
+func (s *Supervisor) Api() *Api {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.api
+}
+
+// WaitApi waits until the Supervisor is registered.
+// It returns the Api of the current connection and any encountered errors.
+func (s *Supervisor) WaitApi(ctx context.Context) (*Api, error) {
+	for {
+		s.mu.Lock()
+		if s.ready == nil {
+			s.ready = make(chan struct{})
+		}
+		api, ready := s.api, s.ready
+		s.mu.Unlock()
+		if api != nil {
+			return api, nil
+		}
+		select {
+		case <-ready:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Transmit sends a packet over the current connection, waiting for the Supervisor to be registered first.
+// It returns a bool whether the operation was successful and any encountered errors.
+func (s *Supervisor) Transmit(ctx context.Context, pkt Packet) (bool, error) {
+	api, err := s.WaitApi(ctx)
+	if err != nil {
+		return false, err
+	}
+	return api.TransmitContext(ctx, pkt)
+}
+
+// isClosed returns whether the channel c is closed
+func isClosed(c chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
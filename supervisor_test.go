@@ -0,0 +1,171 @@
+package gerte_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/OmegaRogue/gerte-go"
+	"github.com/OmegaRogue/gerte-go/geds"
+)
+
+func TestBackoff_Delay(t *testing.T) {
+	b := gerte.Backoff{
+		Initial:    100 * time.Millisecond,
+		Max:        time.Second,
+		Multiplier: 2,
+	}
+	expected := []time.Duration{100, 200, 400, 800, 1000, 1000}
+	for attempt, d := range expected {
+		if delay := b.Delay(attempt); delay != d*time.Millisecond {
+			t.Errorf("attempt %v: expected %v, got %v", attempt, d*time.Millisecond, delay)
+		}
+	}
+	b.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if delay := b.Delay(0); delay < 50*time.Millisecond || delay > 150*time.Millisecond {
+			t.Errorf("jittered delay out of range: %v", delay)
+		}
+	}
+
+	// missing fields use DefaultBackoff, a multiplier below 1 doesn't shrink the delay
+	partial := []gerte.Backoff{{Initial: time.Second}, {Initial: time.Second, Multiplier: 0.5}}
+	for _, b := range partial {
+		for attempt := 0; attempt < 10; attempt++ {
+			if delay := b.Delay(attempt); delay < time.Second || delay > gerte.DefaultBackoff.Max {
+				t.Errorf("%+v attempt %v: delay %v out of range", b, attempt, delay)
+			}
+		}
+	}
+	if delay := (gerte.Backoff{}).Delay(0); delay != gerte.DefaultBackoff.Initial {
+		t.Errorf("expected %v, got %v", gerte.DefaultBackoff.Initial, delay)
+	}
+}
+
+func TestSupervisor_Reconnect(t *testing.T) {
+	addr := gerte.GertAddress{Upper: 1123, Lower: 1456}
+	key := "aaaaaaaaaaaaaaaaaaaa"
+	srv := geds.NewServer(gerte.Version{Major: 1, Minor: 1}, geds.Resolutions{addr: key})
+
+	var mu sync.Mutex
+	var relaySide net.Conn
+	dial := func(ctx context.Context) (net.Conn, error) {
+		server, client := net.Pipe()
+		mu.Lock()
+		relaySide = server
+		mu.Unlock()
+		go srv.ServeConn(server)
+		return client, nil
+	}
+
+	registered := make(chan struct{}, 8)
+	sup := gerte.NewSupervisor(dial, gerte.Version{Major: 1, Minor: 1}, addr, key)
+	sup.Backoff = gerte.Backoff{Initial: time.Millisecond, Max: 10 * time.Millisecond, Multiplier: 2}
+	sup.OnEvent = func(ev gerte.ConnEvent) {
+		t.Logf("supervisor state: %v (attempt %v, error %v)", ev.State, ev.Attempt, ev.Err)
+		if ev.State == gerte.ConnRegistered {
+			registered <- struct{}{}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- sup.Run(ctx)
+	}()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-registered:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("supervisor didn't register (connection %v)", i)
+		}
+		waitCtx, waitCancel := context.WithTimeout(context.Background(), time.Second)
+		api, err := sup.WaitApi(waitCtx)
+		waitCancel()
+		if err != nil || api == nil {
+			t.Fatalf("supervisor has no api: %+v", err)
+		}
+		if sup.State() != gerte.ConnRegistered {
+			t.Errorf("unexpected state: %v", sup.State())
+		}
+		// drop the connection from the relay side
+		mu.Lock()
+		relaySide.Close()
+		mu.Unlock()
+	}
+
+	cancel()
+	select {
+	case err := <-stopped:
+		if err != context.Canceled {
+			t.Errorf("unexpected error from run: %+v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("supervisor didn't stop")
+	}
+	if sup.State() != gerte.ConnStopped {
+		t.Errorf("unexpected state after stop: %v", sup.State())
+	}
+	srv.Close()
+}
+
+func TestSupervisor_Rejected(t *testing.T) {
+	addr := gerte.GertAddress{Upper: 1123, Lower: 1456}
+	srv := geds.NewServer(gerte.Version{Major: 1, Minor: 1}, geds.Resolutions{addr: "aaaaaaaaaaaaaaaaaaaa"})
+	defer srv.Close()
+	dials := 0
+	dial := func(ctx context.Context) (net.Conn, error) {
+		dials++
+		server, client := net.Pipe()
+		go srv.ServeConn(server)
+		return client, nil
+	}
+
+	sup := gerte.NewSupervisor(dial, gerte.Version{Major: 1, Minor: 1}, addr, "bbbbbbbbbbbbbbbbbbbb")
+	sup.Backoff = gerte.Backoff{Initial: time.Millisecond, Max: 10 * time.Millisecond, Multiplier: 2}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := sup.Run(ctx); !errors.Is(err, gerte.ErrBadKey) {
+		t.Errorf("got %+v, want %+v", err, gerte.ErrBadKey)
+	}
+	if dials != 1 || sup.State() != gerte.ConnStopped {
+		t.Errorf("got %v dials in state %v, want 1 in %v", dials, sup.State(), gerte.ConnStopped)
+	}
+}
+
+func TestSupervisor_Fallback(t *testing.T) {
+	addr := gerte.GertAddress{Upper: 1123, Lower: 1456}
+	key := "aaaaaaaaaaaaaaaaaaaa"
+	srv := geds.NewServer(gerte.DefaultVersion, geds.Resolutions{addr: key})
+	defer srv.Close()
+	dial := func(ctx context.Context) (net.Conn, error) {
+		server, client := net.Pipe()
+		go srv.ServeConn(server)
+		return client, nil
+	}
+
+	sup := gerte.NewSupervisor(dial, gerte.ExtendedVersion, addr, key)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- sup.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+
+	waitCtx, waitCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer waitCancel()
+	api, err := sup.WaitApi(waitCtx)
+	if err != nil {
+		t.Fatalf("supervisor didn't register: %+v", err)
+	}
+	if api.Version != gerte.DefaultVersion || api.MaxData() != gerte.MaxData {
+		t.Errorf("negotiated %v with %v bytes of data, want %v", api.Version, api.MaxData(), gerte.DefaultVersion)
+	}
+}
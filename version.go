@@ -14,12 +14,16 @@ func (ver Version) ToBytes() []byte {
 	return []byte{ver.Major, ver.Minor}
 }
 
-// VersionFromBytes parses bytes to a GERT Version
-func VersionFromBytes(b []byte) Version {
+// VersionFromBytes parses bytes to a GERT Version.
+// It returns the Version and any encountered errors, b has to hold at least 2 bytes.
+func VersionFromBytes(b []byte) (Version, error) {
+	if err := checkLength(b, 2, "version"); err != nil {
+		return Version{}, err
+	}
 	return Version{
 		Major: b[0],
 		Minor: b[1],
-	}
+	}, nil
 }
 
 // String prints a GERT Version to a Human-readable string
@@ -31,3 +35,36 @@ func (ver Version) String() string {
 func (ver Version) GoString() string {
 	return fmt.Sprintf("[%v]", ver)
 }
+
+// Compare compares two versions by Major, Minor and Patch.
+// It returns -1 if ver is older than other, 1 if it is newer and 0 if they are equal.
+// The Patch is never sent, so versions read from the wire always have Patch 0.
+func (ver Version) Compare(other Version) int {
+	switch {
+	case ver.Major != other.Major:
+		return compareByte(ver.Major, other.Major)
+	case ver.Minor != other.Minor:
+		return compareByte(ver.Minor, other.Minor)
+	}
+	return compareByte(ver.Patch, other.Patch)
+}
+
+// Less returns whether ver is older than other
+func (ver Version) Less(other Version) bool {
+	return ver.Compare(other) < 0
+}
+
+// wire returns the Version as it is sent, without the Patch
+func (ver Version) wire() Version {
+	return Version{Major: ver.Major, Minor: ver.Minor}
+}
+
+func compareByte(a, b byte) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
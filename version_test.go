@@ -1,6 +1,10 @@
 package gerte
 
-import "testing"
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
 
 func TestVersionFromToBytes(t *testing.T) {
 	version := Version{
@@ -9,9 +13,84 @@ func TestVersionFromToBytes(t *testing.T) {
 		Patch: 0,
 	}
 	ver := version.ToBytes()
-	version2 := VersionFromBytes(ver)
+	version2, err := VersionFromBytes(ver)
+	if err != nil {
+		t.Errorf("error on parse version: %+v", err)
+	}
 
 	if version != version2 {
 		t.Error("versions don't match")
 	}
 }
+
+func TestVersion_Compare(t *testing.T) {
+	tests := []struct {
+		a, b Version
+		want int
+	}{
+		{Version{Major: 1, Minor: 1}, Version{Major: 1, Minor: 1}, 0},
+		{Version{Major: 1, Minor: 0}, Version{Major: 1, Minor: 1}, -1},
+		{Version{Major: 2, Minor: 0}, Version{Major: 1, Minor: 9}, 1},
+		{Version{Major: 1, Minor: 1, Patch: 1}, Version{Major: 1, Minor: 1}, 1},
+	}
+	for _, tt := range tests {
+		if got := tt.a.Compare(tt.b); got != tt.want {
+			t.Errorf("%v.Compare(%v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+		if got := tt.a.Less(tt.b); got != (tt.want < 0) {
+			t.Errorf("%v.Less(%v) = %v", tt.a, tt.b, got)
+		}
+	}
+}
+
+func TestVersionPolicy(t *testing.T) {
+	policy := VersionPolicy{Min: Version{Major: 1, Minor: 0}, Max: Version{Major: 1, Minor: 1, Patch: 5}}
+	if !policy.Accepts(Version{Major: 1, Minor: 1}) || !policy.Accepts(Version{Major: 1, Minor: 0}) {
+		t.Error("policy rejected a version in range")
+	}
+	if policy.Accepts(Version{Major: 1, Minor: 2}) || policy.Accepts(Version{Major: 0, Minor: 9}) {
+		t.Error("policy accepted a version out of range")
+	}
+	want := []Version{{Major: 1, Minor: 1}, {Major: 1, Minor: 0}}
+	if got := policy.Versions(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got versions %v, want %v", got, want)
+	}
+
+	t.Run("Accepts", func(t *testing.T) {
+		requested := Version{Major: 1, Minor: 1}
+		// without a policy only the requested version is accepted, the Patch is never sent
+		if err := (VersionPolicy{}).accepts(requested, Version{Major: 1, Minor: 1, Patch: 3}); err != nil {
+			t.Errorf("requested version was rejected: %+v", err)
+		}
+		if err := (VersionPolicy{}).accepts(requested, Version{Major: 1, Minor: 0}); !errors.Is(err, ErrVersion) {
+			t.Errorf("got %+v for an older version without a policy, want %+v", err, ErrVersion)
+		}
+		if err := policy.accepts(requested, Version{Major: 1, Minor: 0}); err != nil {
+			t.Errorf("older version in the policy was rejected: %+v", err)
+		}
+		if err := (VersionPolicy{}).accepts(requested, Version{Major: 1, Minor: 2}); !errors.Is(err, ErrVersion) {
+			t.Errorf("got %+v, want %+v", err, ErrVersion)
+		}
+		if err := policy.accepts(Version{Major: 2, Minor: 0}, Version{Major: 2, Minor: 0}); !errors.Is(err, ErrVersion) {
+			t.Errorf("got %+v, want %+v", err, ErrVersion)
+		}
+	})
+}
+
+func TestRegisterCodec(t *testing.T) {
+	ver := Version{Major: 1, Minor: 9}
+	if _, err := CodecFor(ver); !errors.Is(err, ErrVersion) {
+		t.Fatalf("got %+v, want %+v", err, ErrVersion)
+	}
+	RegisterCodec(Version{Major: 1, Minor: 9, Patch: 3}, DefaultCodec)
+	if codec, err := CodecFor(ver); err != nil || codec != DefaultCodec {
+		t.Errorf("got %v %+v, want the registered codec", codec, err)
+	}
+	if got := SupportedVersions(); got[0] != ver {
+		t.Errorf("got %v first, want %v", got[0], ver)
+	}
+	RegisterCodec(ver, nil)
+	if _, err := CodecFor(ver); err == nil {
+		t.Error("codec was not removed")
+	}
+}